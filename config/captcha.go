@@ -0,0 +1,52 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// CaptchaConfig enables a captcha on registration and on logins to an
+// account after repeated failures. Tokens are checked with a siteverify
+// endpoint, the API shared by reCAPTCHA, hCaptcha and Turnstile.
+type CaptchaConfig struct {
+	// Secret is the server-side key of the captcha provider. Without one
+	// no captcha is asked for.
+	Secret string `yaml:"secret"`
+	// VerifyURL is the provider's siteverify endpoint, e.g.
+	// https://challenges.cloudflare.com/turnstile/v0/siteverify.
+	VerifyURL string `yaml:"verify_url"`
+	// LoginFailures is how many failed logins to an account within
+	// FailureWindow make the next ones need a captcha. Zero never asks for
+	// one on login.
+	LoginFailures int           `yaml:"login_failures"`
+	FailureWindow time.Duration `yaml:"failure_window"`
+	// Timeout bounds each verification request.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Enabled reports whether captchas are checked.
+func (c CaptchaConfig) Enabled() bool {
+	return c.Secret != ""
+}
+
+func (c CaptchaConfig) validate() []error {
+	if !c.Enabled() {
+		return nil
+	}
+	var errs []error
+	if u, err := url.Parse(c.VerifyURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("CAPTCHA_VERIFY_URL must be an http(s) URL when CAPTCHA_SECRET is set, got %q", c.VerifyURL))
+	}
+	if c.LoginFailures < 0 {
+		errs = append(errs, errors.New("CAPTCHA_LOGIN_FAILURES must not be negative"))
+	}
+	if c.FailureWindow <= 0 {
+		errs = append(errs, errors.New("CAPTCHA_FAILURE_WINDOW must be positive"))
+	}
+	if c.Timeout <= 0 {
+		errs = append(errs, errors.New("CAPTCHA_TIMEOUT must be positive"))
+	}
+	return errs
+}
@@ -1,99 +1,556 @@
 package config
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"io/fs"
 	"os"
 	"time"
 
-	"strconv"
-
 	"github.com/dotenv-org/godotenvvault"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
 )
 
 type Config struct {
-	Port     int
-	Postgres PostgresConfig
-	JWTkey   string
+	Port              int            `yaml:"port"`
+	Postgres          PostgresConfig `yaml:"postgres"`
+	JWTkey            string         `yaml:"jwt_secret"`
+	JWT               JWTConfig      `yaml:"jwt"`
+	Token             TokenConfig    `yaml:"token"`
+	Cookie            CookieConfig   `yaml:"cookie"`
+	EmailStripPlusTag bool           `yaml:"email_strip_plus_tag"`
+
+	// MetricsPort serves Prometheus metrics apart from the public API. Zero
+	// disables the endpoint.
+	MetricsPort int `yaml:"metrics_port"`
+
+	Tracing TracingConfig `yaml:"tracing"`
+	Log     LogConfig     `yaml:"log"`
+
+	// AuditLogFile, when set, receives a JSON line per audit event in
+	// addition to the audit_event table.
+	AuditLogFile string `yaml:"audit_log_file"`
+
+	Webhook WebhookConfig `yaml:"webhook"`
+
+	Redis     RedisConfig     `yaml:"redis"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Cache     CacheConfig     `yaml:"cache"`
+
+	HTTP     HTTPConfig     `yaml:"http"`
+	TLS      TLSConfig      `yaml:"tls"`
+	Internal InternalConfig `yaml:"internal"`
+	Tenancy  TenancyConfig  `yaml:"tenancy"`
+
+	Registration RegistrationConfig `yaml:"registration"`
+	Captcha      CaptchaConfig      `yaml:"captcha"`
+	MagicLink    MagicLinkConfig    `yaml:"magic_link"`
+	SMTP         SMTPConfig         `yaml:"smtp"`
+
+	// TrustedProxies are the addresses or CIDRs of reverse proxies and load
+	// balancers whose X-Forwarded-For header is believed. Without any, the
+	// client IP is the peer address of the connection.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+
+	// ShutdownTimeout bounds draining requests and closing resources on
+	// SIGTERM. ShutdownDelay is how long readiness reports unhealthy before
+	// draining starts, giving load balancers time to stop routing here.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	ShutdownDelay   time.Duration `yaml:"shutdown_delay"`
+}
+
+// JWTConfig sets the iss and aud claims of issued tokens, which validation
+// then requires.
+type JWTConfig struct {
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+	// Leeway tolerates clock skew between servers when checking exp, nbf
+	// and iat.
+	Leeway time.Duration `yaml:"leeway"`
+}
+
+// CookieConfig controls cookie sessions, where browser clients get their
+// tokens in HttpOnly cookies instead of the response body.
+type CookieConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Domain is the cookies' Domain attribute. Empty limits them to the
+	// API's host.
+	Domain string `yaml:"domain"`
+	// Secure should only be turned off for local development over HTTP.
+	Secure bool `yaml:"secure"`
+	// SameSite is strict, lax or none.
+	SameSite string `yaml:"same_site"`
+}
+
+var cookieSameSite = []string{"strict", "lax", "none"}
+
+// maxJWTLeeway keeps a misconfigured leeway from extending token lifetimes
+// noticeably.
+const maxJWTLeeway = 5 * time.Minute
+
+type TracingConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	ServiceName string `yaml:"service_name"`
+	// OTLPEndpoint is the OTLP/HTTP traces URL, e.g.
+	// http://collector:4318/v1/traces. When empty the standard
+	// OTEL_EXPORTER_OTLP_* variables apply.
+	OTLPEndpoint string  `yaml:"otlp_endpoint"`
+	SampleRatio  float64 `yaml:"sample_ratio"`
+}
+
+type LogConfig struct {
+	// Level is one of debug, info, warn or error.
+	Level string `yaml:"level"`
+	// Format is json, for log collectors, or text, for reading locally.
+	Format string `yaml:"format"`
+}
+
+var (
+	logLevels  = []string{"debug", "info", "warn", "error"}
+	logFormats = []string{"json", "text"}
+)
+
+type RedisConfig struct {
+	// URL is a redis:// or rediss:// URL, e.g. redis://:password@redis:6379/0.
+	URL string `yaml:"url"`
+}
+
+// CacheConfig selects where users looked up by ID and revoked token IDs are
+// kept.
+type CacheConfig struct {
+	// Store is memory, an LRU per instance, or redis, shared by all
+	// instances and by operator commands.
+	Store string `yaml:"store"`
+	// Size bounds the entries of each memory store.
+	Size int `yaml:"size"`
+	// UserTTL is how long a user is served from the cache. Zero disables
+	// user caching.
+	UserTTL time.Duration `yaml:"user_ttl"`
+}
+
+var cacheStores = []string{"memory", "redis"}
+
+// WebhookConfig tunes the webhook delivery worker. Failed deliveries are
+// retried after BackoffBase, doubling up to BackoffMax, until MaxAttempts.
+type WebhookConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval"`
+	BatchSize    int           `yaml:"batch_size"`
+	Timeout      time.Duration `yaml:"timeout"`
+	MaxAttempts  int           `yaml:"max_attempts"`
+	BackoffBase  time.Duration `yaml:"backoff_base"`
+	BackoffMax   time.Duration `yaml:"backoff_max"`
+	// AllowPrivateNetworks lets subscriptions point at loopback, private and
+	// link-local addresses, which are refused by default so webhooks can't
+	// reach internal services or cloud metadata.
+	AllowPrivateNetworks bool `yaml:"allow_private_networks"`
 }
 
 type PostgresConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
+	// URL is a full connection string (DATABASE_URL). When set it replaces
+	// the individual connection fields and TLS settings below.
+	URL      string `yaml:"url"`
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	DBName   string `yaml:"db_name"`
+	TimeZone string `yaml:"time_zone"`
+
+	SSLMode     string `yaml:"ssl_mode"`
+	SSLRootCert string `yaml:"ssl_root_cert"`
+	SSLCert     string `yaml:"ssl_cert"`
+	SSLKey      string `yaml:"ssl_key"`
+
+	// ReplicaURLs are connection strings of read replicas. Read-only queries
+	// are spread across them; writes and transactions stay on the primary.
+	ReplicaURLs []string `yaml:"replica_urls"`
+
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+
+	// QueryTimeout caps each repository query on top of the request's own
+	// deadline. Zero disables it.
+	QueryTimeout time.Duration `yaml:"query_timeout"`
+
+	// ConnectAttempts bounds how often the initial connection is tried,
+	// waiting ConnectBackoff after the first failure and doubling each time.
+	ConnectAttempts int           `yaml:"connect_attempts"`
+	ConnectBackoff  time.Duration `yaml:"connect_backoff"`
 }
 
+// minJWTKeyLength is the shortest accepted HS256 secret, matching the hash size.
+const minJWTKeyLength = 32
+
+const redacted = "[REDACTED]"
+
+// Default returns the configuration used before any file or environment
+// variable is applied.
+func Default() *Config {
+	return &Config{
+		Port:            8080,
+		MetricsPort:     9090,
+		ShutdownTimeout: 15 * time.Second,
+		JWT: JWTConfig{
+			Issuer:   "go-simple-auth",
+			Audience: "go-simple-auth",
+			Leeway:   30 * time.Second,
+		},
+		Cookie: CookieConfig{
+			Secure:   true,
+			SameSite: "strict",
+		},
+		Token: TokenConfig{
+			TokenLifetimes: TokenLifetimes{
+				Access:     15 * time.Minute,
+				Refresh:    24 * time.Hour,
+				RememberMe: 30 * 24 * time.Hour,
+				MaxSession: 30 * 24 * time.Hour,
+			},
+		},
+		Tracing: TracingConfig{
+			ServiceName: "go-simple-auth",
+			SampleRatio: 1,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Webhook: WebhookConfig{
+			PollInterval: 5 * time.Second,
+			BatchSize:    20,
+			Timeout:      10 * time.Second,
+			MaxAttempts:  10,
+			BackoffBase:  30 * time.Second,
+			BackoffMax:   6 * time.Hour,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Store:   "memory",
+			Register: RouteLimits{
+				IP: RateLimit{Requests: 10, Period: time.Hour},
+			},
+			Login: RouteLimits{
+				IP:      RateLimit{Requests: 20, Period: time.Minute},
+				Account: RateLimit{Requests: 5, Period: time.Minute},
+			},
+			Refresh: RateLimit{Requests: 60, Period: time.Minute},
+			Logout:  RateLimit{Requests: 60, Period: time.Minute},
+			MagicLink: RouteLimits{
+				IP:      RateLimit{Requests: 10, Period: time.Hour},
+				Account: RateLimit{Requests: 3, Period: 15 * time.Minute},
+			},
+			MagicLinkVerify: RateLimit{Requests: 20, Period: time.Minute},
+		},
+		HTTP: HTTPConfig{
+			HSTSMaxAge:            365 * 24 * time.Hour,
+			ContentSecurityPolicy: "default-src 'none'; frame-ancestors 'none'",
+			FrameOptions:          "DENY",
+			BodyLimit:             "1M",
+			ReadTimeout:           15 * time.Second,
+			WriteTimeout:          30 * time.Second,
+			IdleTimeout:           2 * time.Minute,
+			RequestTimeout:        20 * time.Second,
+		},
+		TLS: TLSConfig{
+			ReloadInterval: time.Minute,
+		},
+		Tenancy: TenancyConfig{
+			Mode:       "single",
+			Header:     "X-Organization",
+			Uniqueness: "global",
+		},
+		Registration: RegistrationConfig{
+			Mode:          "open",
+			InvitationTTL: 72 * time.Hour,
+		},
+		Captcha: CaptchaConfig{
+			LoginFailures: 5,
+			FailureWindow: 15 * time.Minute,
+			Timeout:       5 * time.Second,
+		},
+		MagicLink: MagicLinkConfig{
+			TTL: 15 * time.Minute,
+		},
+		SMTP: SMTPConfig{
+			Timeout: 10 * time.Second,
+		},
+		Cache: CacheConfig{
+			Store:   "memory",
+			Size:    10000,
+			UserTTL: 30 * time.Second,
+		},
+		Postgres: PostgresConfig{
+			Host:            "localhost",
+			Port:            "5432",
+			TimeZone:        "Asia/Shanghai",
+			SSLMode:         "disable",
+			MaxIdleConns:    10,
+			MaxOpenConns:    100,
+			ConnMaxLifetime: time.Hour,
+			QueryTimeout:    5 * time.Second,
+			ConnectAttempts: 5,
+			ConnectBackoff:  time.Second,
+		},
+	}
+}
+
+// LoadConfig builds the configuration from, in increasing precedence:
+// defaults, the YAML file named by CONFIG_FILE, and environment variables
+// (including a .env or .env.vault file when present). Any variable marked as
+// a secret can also be read from the file named by <NAME>_FILE. The result is
+// validated before it is returned.
 func LoadConfig() (*Config, error) {
 	err := godotenvvault.Load()
-	if err != nil {
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return nil, err
 	}
 
-	config := &Config{
-		Postgres: PostgresConfig{
-			Host:     os.Getenv("POSTGRES_HOST"),
-			Port:     os.Getenv("POSTGRES_PORT"),
-			User:     os.Getenv("POSTGRES_USER"),
-			Password: os.Getenv("POSTGRES_PASSWORD"),
-			DBName:   os.Getenv("POSTGRES_DB"),
-		},
-		JWTkey: os.Getenv("JWT_SECRET"),
-		Port: func() int {
-			port, err := strconv.Atoi(os.Getenv("PORT"))
-			if err != nil {
-				return 0 // Default to 0 or handle the error as needed
-			}
-			return port
-		}(),
+	config := Default()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := loadFile(path, config); err != nil {
+			return nil, err
+		}
 	}
-	return config, nil
-}
 
-func ConnectPostgres(cfg PostgresConfig) (*gorm.DB, error) {
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=Asia/Shanghai",
-		cfg.Host, cfg.User, cfg.Password, cfg.DBName, cfg.Port)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Printf("Failed to connect to Postgres: %v", err)
+	env := &envLoader{}
+	env.int(&config.Port, "PORT")
+	env.int(&config.MetricsPort, "METRICS_PORT")
+	env.string(&config.Postgres.Host, "POSTGRES_HOST")
+	env.string(&config.Postgres.Port, "POSTGRES_PORT")
+	env.string(&config.Postgres.User, "POSTGRES_USER")
+	env.secret(&config.Postgres.Password, "POSTGRES_PASSWORD")
+	env.string(&config.Postgres.DBName, "POSTGRES_DB")
+	env.secret(&config.Postgres.URL, "DATABASE_URL")
+	env.string(&config.Postgres.TimeZone, "POSTGRES_TIMEZONE")
+	env.string(&config.Postgres.SSLMode, "POSTGRES_SSLMODE")
+	env.string(&config.Postgres.SSLRootCert, "POSTGRES_SSLROOTCERT")
+	env.string(&config.Postgres.SSLCert, "POSTGRES_SSLCERT")
+	env.string(&config.Postgres.SSLKey, "POSTGRES_SSLKEY")
+	env.secretList(&config.Postgres.ReplicaURLs, "POSTGRES_REPLICA_URLS")
+	env.int(&config.Postgres.MaxIdleConns, "POSTGRES_MAX_IDLE_CONNS")
+	env.int(&config.Postgres.MaxOpenConns, "POSTGRES_MAX_OPEN_CONNS")
+	env.duration(&config.Postgres.ConnMaxLifetime, "POSTGRES_CONN_MAX_LIFETIME")
+	env.duration(&config.Postgres.ConnMaxIdleTime, "POSTGRES_CONN_MAX_IDLE_TIME")
+	env.duration(&config.Postgres.QueryTimeout, "POSTGRES_QUERY_TIMEOUT")
+	env.int(&config.Postgres.ConnectAttempts, "POSTGRES_CONNECT_ATTEMPTS")
+	env.duration(&config.Postgres.ConnectBackoff, "POSTGRES_CONNECT_BACKOFF")
+	env.secret(&config.JWTkey, "JWT_SECRET")
+	env.string(&config.JWT.Issuer, "JWT_ISSUER")
+	env.string(&config.JWT.Audience, "JWT_AUDIENCE")
+	env.duration(&config.JWT.Leeway, "JWT_LEEWAY")
+	env.duration(&config.Token.Access, "TOKEN_ACCESS_TTL")
+	env.duration(&config.Token.Refresh, "TOKEN_REFRESH_TTL")
+	env.duration(&config.Token.RememberMe, "TOKEN_REMEMBER_ME_TTL")
+	env.duration(&config.Token.MaxSession, "TOKEN_MAX_SESSION")
+	env.bool(&config.Cookie.Enabled, "COOKIE_SESSIONS_ENABLED")
+	env.string(&config.Cookie.Domain, "COOKIE_DOMAIN")
+	env.bool(&config.Cookie.Secure, "COOKIE_SECURE")
+	env.string(&config.Cookie.SameSite, "COOKIE_SAMESITE")
+	env.bool(&config.EmailStripPlusTag, "EMAIL_STRIP_PLUS_TAG")
+	env.duration(&config.ShutdownTimeout, "SHUTDOWN_TIMEOUT")
+	env.bool(&config.Tracing.Enabled, "TRACING_ENABLED")
+	env.string(&config.Tracing.ServiceName, "OTEL_SERVICE_NAME")
+	env.string(&config.Tracing.OTLPEndpoint, "TRACING_OTLP_ENDPOINT")
+	env.float(&config.Tracing.SampleRatio, "TRACING_SAMPLE_RATIO")
+	env.duration(&config.ShutdownDelay, "SHUTDOWN_DELAY")
+	env.string(&config.Log.Level, "LOG_LEVEL")
+	env.string(&config.Log.Format, "LOG_FORMAT")
+	env.string(&config.AuditLogFile, "AUDIT_LOG_FILE")
+	env.duration(&config.Webhook.PollInterval, "WEBHOOK_POLL_INTERVAL")
+	env.int(&config.Webhook.BatchSize, "WEBHOOK_BATCH_SIZE")
+	env.duration(&config.Webhook.Timeout, "WEBHOOK_TIMEOUT")
+	env.int(&config.Webhook.MaxAttempts, "WEBHOOK_MAX_ATTEMPTS")
+	env.duration(&config.Webhook.BackoffBase, "WEBHOOK_BACKOFF_BASE")
+	env.duration(&config.Webhook.BackoffMax, "WEBHOOK_BACKOFF_MAX")
+	env.bool(&config.Webhook.AllowPrivateNetworks, "WEBHOOK_ALLOW_PRIVATE_NETWORKS")
+	env.secret(&config.Redis.URL, "REDIS_URL")
+	env.bool(&config.RateLimit.Enabled, "RATE_LIMIT_ENABLED")
+	env.string(&config.RateLimit.Store, "RATE_LIMIT_STORE")
+	env.rateLimit(&config.RateLimit.Register.IP, "RATE_LIMIT_REGISTER_IP")
+	env.rateLimit(&config.RateLimit.Login.IP, "RATE_LIMIT_LOGIN_IP")
+	env.rateLimit(&config.RateLimit.Login.Account, "RATE_LIMIT_LOGIN_ACCOUNT")
+	env.rateLimit(&config.RateLimit.Register.Account, "RATE_LIMIT_REGISTER_ACCOUNT")
+	env.rateLimit(&config.RateLimit.Refresh, "RATE_LIMIT_REFRESH_IP")
+	env.rateLimit(&config.RateLimit.Logout, "RATE_LIMIT_LOGOUT_IP")
+	env.rateLimit(&config.RateLimit.MagicLink.IP, "RATE_LIMIT_MAGIC_LINK_IP")
+	env.rateLimit(&config.RateLimit.MagicLink.Account, "RATE_LIMIT_MAGIC_LINK_ACCOUNT")
+	env.rateLimit(&config.RateLimit.MagicLinkVerify, "RATE_LIMIT_MAGIC_LINK_VERIFY_IP")
+	env.list(&config.TrustedProxies, "TRUSTED_PROXIES")
+	env.list(&config.HTTP.CORS.AllowOrigins, "CORS_ALLOW_ORIGINS")
+	env.bool(&config.HTTP.CORS.AllowCredentials, "CORS_ALLOW_CREDENTIALS")
+	env.duration(&config.HTTP.CORS.MaxAge, "CORS_MAX_AGE")
+	env.duration(&config.HTTP.HSTSMaxAge, "HSTS_MAX_AGE")
+	env.string(&config.HTTP.ContentSecurityPolicy, "CONTENT_SECURITY_POLICY")
+	env.string(&config.HTTP.FrameOptions, "FRAME_OPTIONS")
+	env.string(&config.HTTP.BodyLimit, "BODY_LIMIT")
+	env.duration(&config.HTTP.ReadTimeout, "HTTP_READ_TIMEOUT")
+	env.duration(&config.HTTP.WriteTimeout, "HTTP_WRITE_TIMEOUT")
+	env.duration(&config.HTTP.IdleTimeout, "HTTP_IDLE_TIMEOUT")
+	env.duration(&config.HTTP.RequestTimeout, "REQUEST_TIMEOUT")
+	env.string(&config.TLS.CertFile, "TLS_CERT_FILE")
+	env.string(&config.TLS.KeyFile, "TLS_KEY_FILE")
+	env.duration(&config.TLS.ReloadInterval, "TLS_RELOAD_INTERVAL")
+	env.bool(&config.TLS.SelfSigned, "TLS_SELF_SIGNED")
+	env.int(&config.Internal.Port, "INTERNAL_PORT")
+	env.string(&config.Internal.ClientCAFile, "INTERNAL_CLIENT_CA_FILE")
+	env.list(&config.Internal.AllowedClients, "INTERNAL_ALLOWED_CLIENTS")
+	env.string(&config.Tenancy.Mode, "TENANCY_MODE")
+	env.string(&config.Tenancy.Header, "TENANCY_HEADER")
+	env.string(&config.Tenancy.BaseDomain, "TENANCY_BASE_DOMAIN")
+	env.string(&config.Tenancy.Uniqueness, "TENANCY_UNIQUENESS")
+	env.string(&config.Registration.Mode, "REGISTRATION_MODE")
+	env.duration(&config.Registration.InvitationTTL, "INVITATION_TTL")
+	env.string(&config.Registration.InvitationURL, "INVITATION_URL")
+	env.list(&config.Registration.AllowedDomains, "REGISTRATION_ALLOWED_DOMAINS")
+	env.list(&config.Registration.BlockedDomains, "REGISTRATION_BLOCKED_DOMAINS")
+	env.bool(&config.Registration.BlockDisposable, "REGISTRATION_BLOCK_DISPOSABLE")
+	env.secret(&config.Captcha.Secret, "CAPTCHA_SECRET")
+	env.string(&config.Captcha.VerifyURL, "CAPTCHA_VERIFY_URL")
+	env.int(&config.Captcha.LoginFailures, "CAPTCHA_LOGIN_FAILURES")
+	env.duration(&config.Captcha.FailureWindow, "CAPTCHA_FAILURE_WINDOW")
+	env.duration(&config.Captcha.Timeout, "CAPTCHA_TIMEOUT")
+	env.bool(&config.MagicLink.Enabled, "MAGIC_LINK_ENABLED")
+	env.duration(&config.MagicLink.TTL, "MAGIC_LINK_TTL")
+	env.string(&config.MagicLink.URL, "MAGIC_LINK_URL")
+	env.string(&config.SMTP.Addr, "SMTP_ADDR")
+	env.string(&config.SMTP.Username, "SMTP_USERNAME")
+	env.secret(&config.SMTP.Password, "SMTP_PASSWORD")
+	env.string(&config.SMTP.From, "SMTP_FROM")
+	env.duration(&config.SMTP.Timeout, "SMTP_TIMEOUT")
+	env.string(&config.Cache.Store, "CACHE_STORE")
+	env.int(&config.Cache.Size, "CACHE_SIZE")
+	env.duration(&config.Cache.UserTTL, "CACHE_USER_TTL")
+	if err := env.err(); err != nil {
 		return nil, err
 	}
 
-	sqlDB, err := db.DB()
-	if err != nil {
-		log.Printf("Failed to get sql.DB from gorm DB: %v", err)
+	if err := config.Validate(); err != nil {
 		return nil, err
 	}
+	return config, nil
+}
 
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(100)
-	sqlDB.SetConnMaxLifetime(time.Hour)
+// Validate reports every missing or invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT must be between 1 and 65535, got %d", c.Port))
+	}
+	if c.MetricsPort < 0 || c.MetricsPort > 65535 {
+		errs = append(errs, fmt.Errorf("METRICS_PORT must be between 0 and 65535, got %d", c.MetricsPort))
+	} else if c.MetricsPort == c.Port {
+		errs = append(errs, errors.New("METRICS_PORT must differ from PORT"))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1, got %g", c.Tracing.SampleRatio))
+	}
+	if !contains(logLevels, c.Log.Level) {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL must be one of %v, got %q", logLevels, c.Log.Level))
+	}
+	if !contains(logFormats, c.Log.Format) {
+		errs = append(errs, fmt.Errorf("LOG_FORMAT must be one of %v, got %q", logFormats, c.Log.Format))
+	}
+	errs = append(errs, c.Token.validate()...)
+	if !contains(cookieSameSite, c.Cookie.SameSite) {
+		errs = append(errs, fmt.Errorf("COOKIE_SAMESITE must be one of %v, got %q", cookieSameSite, c.Cookie.SameSite))
+	} else if c.Cookie.SameSite == "none" && !c.Cookie.Secure {
+		errs = append(errs, errors.New("COOKIE_SAMESITE=none requires COOKIE_SECURE"))
+	}
+	errs = append(errs, c.Webhook.validate()...)
+	errs = append(errs, c.validateRateLimit()...)
+	errs = append(errs, c.validateCache()...)
+	errs = append(errs, c.HTTP.validate()...)
+	errs = append(errs, c.validateTLS()...)
+	errs = append(errs, c.Tenancy.validate()...)
+	errs = append(errs, c.Registration.validate()...)
+	errs = append(errs, c.Captcha.validate()...)
+	errs = append(errs, c.MagicLink.validate()...)
+	errs = append(errs, c.validateSMTP()...)
+	errs = append(errs, validateTrustedProxies(c.TrustedProxies)...)
+	errs = append(errs, c.Postgres.validate()...)
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT must be positive"))
+	}
+	if c.JWTkey == "" {
+		errs = append(errs, errors.New("JWT_SECRET is required"))
+	} else if len(c.JWTkey) < minJWTKeyLength {
+		errs = append(errs, fmt.Errorf("JWT_SECRET must be at least %d characters", minJWTKeyLength))
+	}
+	if c.JWT.Issuer == "" {
+		errs = append(errs, errors.New("JWT_ISSUER is required"))
+	}
+	if c.JWT.Audience == "" {
+		errs = append(errs, errors.New("JWT_AUDIENCE is required"))
+	}
+	if c.JWT.Leeway < 0 || c.JWT.Leeway > maxJWTLeeway {
+		errs = append(errs, fmt.Errorf("JWT_LEEWAY must be between 0 and %s, got %s", maxJWTLeeway, c.JWT.Leeway))
+	}
+	return errors.Join(errs...)
+}
 
-	err = sqlDB.Ping()
-	if err != nil {
-		log.Printf("Failed to ping Postgres: %v", err)
-		return nil, err
+func (c WebhookConfig) validate() []error {
+	var errs []error
+	if c.PollInterval <= 0 {
+		errs = append(errs, errors.New("WEBHOOK_POLL_INTERVAL must be positive"))
 	}
+	if c.BatchSize < 1 {
+		errs = append(errs, errors.New("WEBHOOK_BATCH_SIZE must be at least 1"))
+	}
+	if c.Timeout <= 0 {
+		errs = append(errs, errors.New("WEBHOOK_TIMEOUT must be positive"))
+	}
+	if c.MaxAttempts < 1 {
+		errs = append(errs, errors.New("WEBHOOK_MAX_ATTEMPTS must be at least 1"))
+	}
+	if c.BackoffBase <= 0 || c.BackoffMax < c.BackoffBase {
+		errs = append(errs, errors.New("WEBHOOK_BACKOFF_BASE must be positive and at most WEBHOOK_BACKOFF_MAX"))
+	}
+	return errs
+}
 
-	log.Println("Successfully connected to Postgres")
-	return db, nil
+// UsesRedis reports whether any enabled feature keeps its state in Redis.
+func (c *Config) UsesRedis() bool {
+	return (c.RateLimit.Enabled && c.RateLimit.Store == "redis") || c.Cache.Store == "redis"
+}
+
+func (c *Config) validateCache() []error {
+	var errs []error
+	if !contains(cacheStores, c.Cache.Store) {
+		errs = append(errs, fmt.Errorf("CACHE_STORE must be one of %v, got %q", cacheStores, c.Cache.Store))
+	} else if c.Cache.Store == "redis" && c.Redis.URL == "" {
+		errs = append(errs, errors.New("REDIS_URL is required when CACHE_STORE is redis"))
+	}
+	if c.Cache.Size < 1 {
+		errs = append(errs, errors.New("CACHE_SIZE must be at least 1"))
+	}
+	if c.Cache.UserTTL < 0 {
+		errs = append(errs, errors.New("CACHE_USER_TTL must not be negative"))
+	}
+	return errs
 }
 
-func DBHealthCheck(db *gorm.DB) error {
-	sqlDB, err := db.DB()
-	if err != nil {
-		return err
+// Redacted returns a copy of the configuration with secrets masked, safe to
+// print or log.
+func (c Config) Redacted() Config {
+	c.Postgres.Password = redact(c.Postgres.Password)
+	c.Postgres.URL = redact(c.Postgres.URL)
+	replicas := make([]string, len(c.Postgres.ReplicaURLs))
+	for i, u := range c.Postgres.ReplicaURLs {
+		replicas[i] = redact(u)
 	}
-	return sqlDB.Ping()
+	c.Postgres.ReplicaURLs = replicas
+	c.JWTkey = redact(c.JWTkey)
+	c.Redis.URL = redact(c.Redis.URL)
+	c.Captcha.Secret = redact(c.Captcha.Secret)
+	c.SMTP.Password = redact(c.SMTP.Password)
+	return c
 }
 
-func CloseDB(db *gorm.DB) error {
-	sqlDB, err := db.DB()
-	if err != nil {
-		return err
+func redact(secret string) string {
+	if secret == "" {
+		return ""
 	}
-	return sqlDB.Close()
+	return redacted
 }
@@ -0,0 +1,441 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testJWTKey = "0123456789abcdef0123456789abcdef"
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	testCases := []struct {
+		name        string
+		env         func(t *testing.T) map[string]string
+		check       func(t *testing.T, cfg *Config)
+		expectedErr string
+	}{
+		{
+			name: "Defaults With Required Env",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"POSTGRES_USER": "app",
+					"POSTGRES_DB":   "auth",
+					"JWT_SECRET":    testJWTKey,
+				}
+			},
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, 8080, cfg.Port)
+				assert.Equal(t, "localhost", cfg.Postgres.Host)
+				assert.Equal(t, "5432", cfg.Postgres.Port)
+			},
+		},
+		{
+			name: "Env Overrides File",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"CONFIG_FILE": writeFile(t, "config.yaml", "port: 9000\npostgres:\n  host: db\n  user: app\n  db_name: auth\n"),
+					"PORT":        "9500",
+					"JWT_SECRET":  testJWTKey,
+				}
+			},
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, 9500, cfg.Port)
+				assert.Equal(t, "db", cfg.Postgres.Host)
+			},
+		},
+		{
+			name: "Secret From File",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"POSTGRES_USER":          "app",
+					"POSTGRES_DB":            "auth",
+					"POSTGRES_PASSWORD_FILE": writeFile(t, "pg", "hunter2\n"),
+					"JWT_SECRET_FILE":        writeFile(t, "jwt", testJWTKey+"\n"),
+				}
+			},
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, "hunter2", cfg.Postgres.Password)
+				assert.Equal(t, testJWTKey, cfg.JWTkey)
+			},
+		},
+		{
+			name: "Secret And File Both Set",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"JWT_SECRET":      testJWTKey,
+					"JWT_SECRET_FILE": writeFile(t, "jwt", testJWTKey),
+				}
+			},
+			expectedErr: "only one of JWT_SECRET and JWT_SECRET_FILE may be set",
+		},
+		{
+			name: "Invalid Port",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{"PORT": "eighty"}
+			},
+			expectedErr: `PORT must be an integer, got "eighty"`,
+		},
+		{
+			name: "Unknown File Key",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{"CONFIG_FILE": writeFile(t, "config.yaml", "prot: 9000\n")}
+			},
+			expectedErr: "field prot not found",
+		},
+		{
+			name: "Weak JWT Secret",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"POSTGRES_USER": "app",
+					"POSTGRES_DB":   "auth",
+					"JWT_SECRET":    "short",
+				}
+			},
+			expectedErr: "JWT_SECRET must be at least 32 characters",
+		},
+		{
+			name: "Excessive JWT Leeway",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"POSTGRES_USER": "app",
+					"POSTGRES_DB":   "auth",
+					"JWT_SECRET":    testJWTKey,
+					"JWT_LEEWAY":    "1h",
+				}
+			},
+			expectedErr: "JWT_LEEWAY must be between 0 and 5m0s, got 1h0m0s",
+		},
+		{
+			name: "Token Lifetimes From File",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"CONFIG_FILE":      writeFile(t, "config.yaml", "token:\n  access: 10m\n  clients:\n    mobile:\n      refresh: 168h\n  roles:\n    admin:\n      max_session: 12h\n"),
+					"POSTGRES_USER":    "app",
+					"POSTGRES_DB":      "auth",
+					"JWT_SECRET":       testJWTKey,
+					"TOKEN_ACCESS_TTL": "5m",
+				}
+			},
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, 5*time.Minute, cfg.Token.Access)
+				assert.Equal(t, 24*time.Hour, cfg.Token.Refresh)
+				assert.Equal(t, TokenLifetimes{Refresh: 168 * time.Hour}, cfg.Token.Clients["mobile"])
+				assert.Equal(t, TokenLifetimes{MaxSession: 12 * time.Hour}, cfg.Token.Roles[RoleAdmin])
+			},
+		},
+		{
+			name: "Unknown Token Role",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"CONFIG_FILE":   writeFile(t, "config.yaml", "token:\n  roles:\n    owner:\n      access: 1m\n"),
+					"POSTGRES_USER": "app",
+					"POSTGRES_DB":   "auth",
+					"JWT_SECRET":    testJWTKey,
+				}
+			},
+			expectedErr: `token role must be one of [admin user], got "owner"`,
+		},
+		{
+			name: "Invalid Log Level",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"POSTGRES_USER": "app",
+					"POSTGRES_DB":   "auth",
+					"JWT_SECRET":    testJWTKey,
+					"LOG_LEVEL":     "verbose",
+				}
+			},
+			expectedErr: `LOG_LEVEL must be one of [debug info warn error], got "verbose"`,
+		},
+		{
+			name: "Rate Limit Override",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"POSTGRES_USER":            "app",
+					"POSTGRES_DB":              "auth",
+					"JWT_SECRET":               testJWTKey,
+					"RATE_LIMIT_LOGIN_ACCOUNT": "3/30s",
+					"RATE_LIMIT_REGISTER_IP":   "0",
+				}
+			},
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, RateLimit{Requests: 3, Period: 30 * time.Second}, cfg.RateLimit.Login.Account)
+				assert.False(t, cfg.RateLimit.Register.IP.Enabled())
+				assert.Equal(t, RateLimit{Requests: 20, Period: time.Minute}, cfg.RateLimit.Login.IP)
+			},
+		},
+		{
+			name: "Invalid Rate Limit",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"POSTGRES_USER":       "app",
+					"POSTGRES_DB":         "auth",
+					"JWT_SECRET":          testJWTKey,
+					"RATE_LIMIT_LOGIN_IP": "abc",
+				}
+			},
+			expectedErr: `RATE_LIMIT_LOGIN_IP: rate limit must look like 5/1m, got "abc"`,
+		},
+		{
+			name: "Invalid Cache Store",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"POSTGRES_USER": "app",
+					"POSTGRES_DB":   "auth",
+					"JWT_SECRET":    testJWTKey,
+					"CACHE_STORE":   "memcached",
+				}
+			},
+			expectedErr: `CACHE_STORE must be one of [memory redis], got "memcached"`,
+		},
+		{
+			name: "Redis Store Without URL",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"POSTGRES_USER":    "app",
+					"POSTGRES_DB":      "auth",
+					"JWT_SECRET":       testJWTKey,
+					"RATE_LIMIT_STORE": "redis",
+				}
+			},
+			expectedErr: "REDIS_URL",
+		},
+		{
+			name: "SameSite None Without Secure",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"POSTGRES_USER":   "app",
+					"POSTGRES_DB":     "auth",
+					"JWT_SECRET":      testJWTKey,
+					"COOKIE_SAMESITE": "none",
+					"COOKIE_SECURE":   "false",
+				}
+			},
+			expectedErr: "COOKIE_SAMESITE=none requires COOKIE_SECURE",
+		},
+		{
+			name: "Wildcard CORS Origin With Credentials",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"POSTGRES_USER":          "app",
+					"POSTGRES_DB":            "auth",
+					"JWT_SECRET":             testJWTKey,
+					"CORS_ALLOW_ORIGINS":     "*",
+					"CORS_ALLOW_CREDENTIALS": "true",
+				}
+			},
+			expectedErr: "CORS_ALLOW_ORIGINS can't be * with CORS_ALLOW_CREDENTIALS",
+		},
+		{
+			name: "Invalid Body Limit",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"POSTGRES_USER": "app",
+					"POSTGRES_DB":   "auth",
+					"JWT_SECRET":    testJWTKey,
+					"BODY_LIMIT":    "lots",
+				}
+			},
+			expectedErr: `BODY_LIMIT must be a size such as 1M, got "lots"`,
+		},
+		{
+			name: "Internal Port Without TLS",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"POSTGRES_USER":            "app",
+					"POSTGRES_DB":              "auth",
+					"JWT_SECRET":               testJWTKey,
+					"INTERNAL_PORT":            "8443",
+					"INTERNAL_CLIENT_CA_FILE":  "ca.pem",
+					"INTERNAL_ALLOWED_CLIENTS": "billing",
+				}
+			},
+			expectedErr: "INTERNAL_PORT requires TLS_CERT_FILE or TLS_SELF_SIGNED",
+		},
+		{
+			name: "Subdomain Tenancy Without Base Domain",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"POSTGRES_USER": "app",
+					"POSTGRES_DB":   "auth",
+					"JWT_SECRET":    testJWTKey,
+					"TENANCY_MODE":  "subdomain",
+				}
+			},
+			expectedErr: "TENANCY_BASE_DOMAIN must be a domain",
+		},
+		{
+			name: "Invalid Invitation URL",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"POSTGRES_USER":  "app",
+					"POSTGRES_DB":    "auth",
+					"JWT_SECRET":     testJWTKey,
+					"INVITATION_URL": "app.example.com/invite",
+				}
+			},
+			expectedErr: `INVITATION_URL must be an http(s) URL, got "app.example.com/invite"`,
+		},
+		{
+			name: "Registration Controls",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"POSTGRES_USER":                 "app",
+					"POSTGRES_DB":                   "auth",
+					"JWT_SECRET":                    testJWTKey,
+					"REGISTRATION_MODE":             "closed",
+					"REGISTRATION_ALLOWED_DOMAINS":  "acme.com, acme.io",
+					"REGISTRATION_BLOCK_DISPOSABLE": "true",
+					"CAPTCHA_SECRET":                "captcha-secret",
+					"CAPTCHA_VERIFY_URL":            "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+					"CAPTCHA_LOGIN_FAILURES":        "3",
+				}
+			},
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, "closed", cfg.Registration.Mode)
+				assert.Equal(t, []string{"acme.com", "acme.io"}, cfg.Registration.AllowedDomains)
+				assert.True(t, cfg.Registration.BlockDisposable)
+				assert.True(t, cfg.Captcha.Enabled())
+				assert.Equal(t, 3, cfg.Captcha.LoginFailures)
+				assert.Equal(t, 15*time.Minute, cfg.Captcha.FailureWindow)
+			},
+		},
+		{
+			name: "Captcha Without Verify URL",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"POSTGRES_USER":  "app",
+					"POSTGRES_DB":    "auth",
+					"JWT_SECRET":     testJWTKey,
+					"CAPTCHA_SECRET": "captcha-secret",
+				}
+			},
+			expectedErr: "CAPTCHA_VERIFY_URL must be an http(s) URL when CAPTCHA_SECRET is set",
+		},
+		{
+			name: "Magic Link Without URL",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"POSTGRES_USER":      "app",
+					"POSTGRES_DB":        "auth",
+					"JWT_SECRET":         testJWTKey,
+					"MAGIC_LINK_ENABLED": "true",
+					"MAGIC_LINK_TTL":     "2h",
+				}
+			},
+			expectedErr: "MAGIC_LINK_TTL must be between 0 and 1h0m0s, got 2h0m0s\nMAGIC_LINK_URL must be an http(s) URL when MAGIC_LINK_ENABLED is set\nSMTP_ADDR must be a host:port when MAGIC_LINK_ENABLED is set, got \"\"\nSMTP_FROM must be an email address when MAGIC_LINK_ENABLED is set, got \"\"",
+		},
+		{
+			name: "Magic Link",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"POSTGRES_USER":      "app",
+					"POSTGRES_DB":        "auth",
+					"JWT_SECRET":         testJWTKey,
+					"MAGIC_LINK_ENABLED": "true",
+					"MAGIC_LINK_URL":     "https://app.example.com/magic",
+					"SMTP_ADDR":          "smtp.example.com:587",
+					"SMTP_USERNAME":      "auth",
+					"SMTP_PASSWORD":      "smtp-password",
+					"SMTP_FROM":          "Example <login@example.com>",
+				}
+			},
+			check: func(t *testing.T, cfg *Config) {
+				assert.True(t, cfg.MagicLink.Enabled)
+				assert.Equal(t, "smtp.example.com:587", cfg.SMTP.Addr)
+				assert.Equal(t, "smtp-password", cfg.SMTP.Password)
+				assert.Equal(t, 10*time.Second, cfg.SMTP.Timeout)
+			},
+		},
+		{
+			name: "Invalid Blocked Domain",
+			env: func(t *testing.T) map[string]string {
+				return map[string]string{
+					"POSTGRES_USER":                "app",
+					"POSTGRES_DB":                  "auth",
+					"JWT_SECRET":                   testJWTKey,
+					"REGISTRATION_BLOCKED_DOMAINS": "spam@example.com",
+				}
+			},
+			expectedErr: `REGISTRATION_BLOCKED_DOMAINS must list domains such as example.com, got "spam@example.com"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env(t) {
+				t.Setenv(k, v)
+			}
+
+			cfg, err := LoadConfig()
+
+			if tc.expectedErr != "" {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				tc.check(t, cfg)
+			}
+		})
+	}
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	cfg := Config{JWTkey: testJWTKey, Postgres: PostgresConfig{User: "app", Password: "hunter2"}}
+
+	out := cfg.Redacted()
+
+	assert.Equal(t, redacted, out.JWTkey)
+	assert.Equal(t, redacted, out.Postgres.Password)
+	assert.Equal(t, "app", out.Postgres.User)
+	assert.Equal(t, testJWTKey, cfg.JWTkey)
+}
+
+func TestPostgresConfig_DSN(t *testing.T) {
+	cfg := Default().Postgres
+	cfg.User = "app"
+	cfg.Password = "it's secret"
+	cfg.DBName = "auth"
+	cfg.SSLMode = "verify-full"
+	cfg.SSLRootCert = "/certs/ca.pem"
+
+	assert.Equal(t,
+		`host=localhost user=app password='it\'s secret' dbname=auth port=5432 sslmode=verify-full TimeZone=Asia/Shanghai sslrootcert=/certs/ca.pem`,
+		cfg.DSN())
+
+	cfg.URL = "postgres://app@db/auth?sslmode=require"
+	assert.Equal(t, cfg.URL, cfg.DSN())
+}
+
+func TestTokenConfig_Lifetimes(t *testing.T) {
+	cfg := TokenConfig{
+		TokenLifetimes: TokenLifetimes{Access: 15 * time.Minute, Refresh: 24 * time.Hour, RememberMe: 720 * time.Hour, MaxSession: 720 * time.Hour},
+		Clients:        map[string]TokenLifetimes{"mobile": {Refresh: 168 * time.Hour, MaxSession: 2160 * time.Hour}},
+		Roles:          map[string]TokenLifetimes{RoleAdmin: {Access: 5 * time.Minute, MaxSession: 12 * time.Hour}},
+	}
+
+	l, ok := cfg.Lifetimes("", RoleUser)
+	assert.True(t, ok)
+	assert.Equal(t, cfg.TokenLifetimes, l)
+
+	l, ok = cfg.Lifetimes("mobile", RoleUser)
+	assert.True(t, ok)
+	assert.Equal(t, TokenLifetimes{Access: 15 * time.Minute, Refresh: 168 * time.Hour, RememberMe: 720 * time.Hour, MaxSession: 2160 * time.Hour}, l)
+
+	l, ok = cfg.Lifetimes("mobile", RoleAdmin)
+	assert.True(t, ok)
+	assert.Equal(t, TokenLifetimes{Access: 5 * time.Minute, Refresh: 168 * time.Hour, RememberMe: 720 * time.Hour, MaxSession: 12 * time.Hour}, l)
+
+	_, ok = cfg.Lifetimes("desktop", RoleUser)
+	assert.False(t, ok)
+}
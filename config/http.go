@@ -0,0 +1,91 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/labstack/gommon/bytes"
+)
+
+// HTTPConfig hardens the public API server.
+type HTTPConfig struct {
+	CORS CORSConfig `yaml:"cors"`
+
+	// HSTSMaxAge is sent in Strict-Transport-Security. Zero omits the header,
+	// which should only be done when the API isn't served over HTTPS.
+	HSTSMaxAge time.Duration `yaml:"hsts_max_age"`
+	// ContentSecurityPolicy is sent with every response. The API only serves
+	// JSON, so the default forbids loading anything.
+	ContentSecurityPolicy string `yaml:"content_security_policy"`
+	// FrameOptions is the X-Frame-Options header, DENY or SAMEORIGIN.
+	FrameOptions string `yaml:"frame_options"`
+
+	// BodyLimit caps request bodies, e.g. 1M; larger ones are rejected
+	// with 413.
+	BodyLimit string `yaml:"body_limit"`
+
+	// ReadTimeout and WriteTimeout bound reading a whole request and writing
+	// its response, IdleTimeout how long a keep-alive connection is kept
+	// open between requests.
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	// RequestTimeout is the deadline of each request's context, after which
+	// database calls are cancelled and the request fails with 503. Zero
+	// disables it.
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+}
+
+// CORSConfig lets browser apps on other origins call the API. Without
+// AllowOrigins no CORS headers are sent and browsers block such calls.
+type CORSConfig struct {
+	// AllowOrigins are origins such as https://app.example.com, or * for
+	// any.
+	AllowOrigins []string `yaml:"allow_origins"`
+	// AllowCredentials lets browsers send cookies, as cookie sessions need.
+	// It can't be combined with *.
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+}
+
+var frameOptions = []string{"DENY", "SAMEORIGIN"}
+
+func (c HTTPConfig) validate() []error {
+	var errs []error
+	for _, origin := range c.CORS.AllowOrigins {
+		if origin == "*" {
+			if c.CORS.AllowCredentials {
+				errs = append(errs, errors.New("CORS_ALLOW_ORIGINS can't be * with CORS_ALLOW_CREDENTIALS"))
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			errs = append(errs, fmt.Errorf("CORS_ALLOW_ORIGINS entry %q must look like https://app.example.com", origin))
+		}
+	}
+	if c.CORS.MaxAge < 0 {
+		errs = append(errs, errors.New("CORS_MAX_AGE must not be negative"))
+	}
+	if c.HSTSMaxAge < 0 {
+		errs = append(errs, errors.New("HSTS_MAX_AGE must not be negative"))
+	}
+	if !contains(frameOptions, c.FrameOptions) {
+		errs = append(errs, fmt.Errorf("FRAME_OPTIONS must be one of %v, got %q", frameOptions, c.FrameOptions))
+	}
+	if n, err := bytes.Parse(c.BodyLimit); err != nil || n <= 0 {
+		errs = append(errs, fmt.Errorf("BODY_LIMIT must be a size such as 1M, got %q", c.BodyLimit))
+	}
+	if c.ReadTimeout <= 0 || c.WriteTimeout <= 0 || c.IdleTimeout <= 0 {
+		errs = append(errs, errors.New("HTTP_READ_TIMEOUT, HTTP_WRITE_TIMEOUT and HTTP_IDLE_TIMEOUT must be positive"))
+	}
+	if c.RequestTimeout < 0 {
+		errs = append(errs, errors.New("REQUEST_TIMEOUT must not be negative"))
+	} else if c.RequestTimeout >= c.WriteTimeout && c.WriteTimeout > 0 {
+		// otherwise the connection is cut before the timeout response is written
+		errs = append(errs, errors.New("REQUEST_TIMEOUT must be shorter than HTTP_WRITE_TIMEOUT"))
+	}
+	return errs
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// maxMagicLinkTTL bounds MagicLinkConfig.TTL: a link logs in whoever opens
+// it, so it must not outlive the email it was sent in by much.
+const maxMagicLinkTTL = time.Hour
+
+// MagicLinkConfig enables passwordless login with single-use links, which
+// are emailed to users through the SMTP server of SMTPConfig.
+type MagicLinkConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// TTL is how long a link can be used for.
+	TTL time.Duration `yaml:"ttl"`
+	// URL is the page of the frontend logging in with links, which carry
+	// the token as the token query parameter.
+	URL string `yaml:"url"`
+}
+
+func (c MagicLinkConfig) validate() []error {
+	if !c.Enabled {
+		return nil
+	}
+	var errs []error
+	if c.TTL <= 0 || c.TTL > maxMagicLinkTTL {
+		errs = append(errs, fmt.Errorf("MAGIC_LINK_TTL must be between 0 and %s, got %s", maxMagicLinkTTL, c.TTL))
+	}
+	if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		errs = append(errs, errors.New("MAGIC_LINK_URL must be an http(s) URL when MAGIC_LINK_ENABLED is set"))
+	}
+	return errs
+}
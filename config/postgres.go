@@ -0,0 +1,176 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+const maxConnectBackoff = 30 * time.Second
+
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+func (cfg PostgresConfig) validate() []error {
+	var errs []error
+	if cfg.URL == "" {
+		if cfg.Host == "" {
+			errs = append(errs, errors.New("POSTGRES_HOST is required"))
+		}
+		if cfg.User == "" {
+			errs = append(errs, errors.New("POSTGRES_USER is required"))
+		}
+		if cfg.DBName == "" {
+			errs = append(errs, errors.New("POSTGRES_DB is required"))
+		}
+		if !contains(sslModes, cfg.SSLMode) {
+			errs = append(errs, fmt.Errorf("POSTGRES_SSLMODE must be one of %s, got %q", strings.Join(sslModes, ", "), cfg.SSLMode))
+		}
+		if (cfg.SSLCert == "") != (cfg.SSLKey == "") {
+			errs = append(errs, errors.New("POSTGRES_SSLCERT and POSTGRES_SSLKEY must be set together"))
+		}
+	}
+	if cfg.MaxOpenConns < 1 {
+		errs = append(errs, errors.New("POSTGRES_MAX_OPEN_CONNS must be positive"))
+	}
+	if cfg.MaxIdleConns < 0 || cfg.MaxIdleConns > cfg.MaxOpenConns {
+		errs = append(errs, errors.New("POSTGRES_MAX_IDLE_CONNS must be between 0 and POSTGRES_MAX_OPEN_CONNS"))
+	}
+	if cfg.ConnectAttempts < 1 {
+		errs = append(errs, errors.New("POSTGRES_CONNECT_ATTEMPTS must be at least 1"))
+	}
+	return errs
+}
+
+// DSN returns the connection string for the primary database.
+func (cfg PostgresConfig) DSN() string {
+	if cfg.URL != "" {
+		return cfg.URL
+	}
+
+	params := []string{
+		"host=" + dsnValue(cfg.Host),
+		"user=" + dsnValue(cfg.User),
+		"password=" + dsnValue(cfg.Password),
+		"dbname=" + dsnValue(cfg.DBName),
+		"port=" + dsnValue(cfg.Port),
+		"sslmode=" + dsnValue(cfg.SSLMode),
+		"TimeZone=" + dsnValue(cfg.TimeZone),
+	}
+	if cfg.SSLRootCert != "" {
+		params = append(params, "sslrootcert="+dsnValue(cfg.SSLRootCert))
+	}
+	if cfg.SSLCert != "" {
+		params = append(params, "sslcert="+dsnValue(cfg.SSLCert), "sslkey="+dsnValue(cfg.SSLKey))
+	}
+	return strings.Join(params, " ")
+}
+
+// dsnValue quotes a keyword/value DSN value so passwords and paths with
+// spaces or quotes survive parsing.
+func dsnValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
+// ConnectPostgres opens the connection pool, retrying with exponential
+// backoff so the service survives starting before the database is ready.
+func ConnectPostgres(cfg PostgresConfig) (*gorm.DB, error) {
+	backoff := cfg.ConnectBackoff
+	for attempt := 1; ; attempt++ {
+		db, err := connectPostgres(cfg)
+		if err == nil {
+			slog.Info("connected to Postgres")
+			return db, nil
+		}
+		if attempt >= cfg.ConnectAttempts {
+			return nil, err
+		}
+
+		slog.Warn("failed to connect to Postgres, retrying",
+			slog.Int("attempt", attempt), slog.Int("max_attempts", cfg.ConnectAttempts),
+			slog.Duration("backoff", backoff), slog.Any("error", err))
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+}
+
+func connectPostgres(cfg PostgresConfig) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{})
+	if err != nil {
+		if db != nil {
+			CloseDB(db)
+		}
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	if len(cfg.ReplicaURLs) > 0 {
+		replicas := make([]gorm.Dialector, len(cfg.ReplicaURLs))
+		for i, u := range cfg.ReplicaURLs {
+			replicas[i] = postgres.Open(u)
+		}
+		resolver := dbresolver.Register(dbresolver.Config{
+			Replicas: replicas,
+			Policy:   dbresolver.RandomPolicy{},
+		}).
+			SetMaxIdleConns(cfg.MaxIdleConns).
+			SetMaxOpenConns(cfg.MaxOpenConns).
+			SetConnMaxLifetime(cfg.ConnMaxLifetime).
+			SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+		if err := db.Use(resolver); err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("register read replicas: %w", err)
+		}
+	}
+
+	err = sqlDB.Ping()
+	if err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func DBHealthCheck(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Ping()
+}
+
+func CloseDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit allows Requests per Period, written "<requests>/<period>" such
+// as "5/1m". The zero value, written "" or "0", disables the limit.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+func ParseRateLimit(s string) (RateLimit, error) {
+	if s == "" || s == "0" {
+		return RateLimit{}, nil
+	}
+	n, period, ok := strings.Cut(s, "/")
+	requests, err := strconv.Atoi(n)
+	if !ok || err != nil || requests < 1 {
+		return RateLimit{}, fmt.Errorf("rate limit must look like 5/1m, got %q", s)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d < time.Second {
+		return RateLimit{}, fmt.Errorf("rate limit must look like 5/1m with a period of at least 1s, got %q", s)
+	}
+	return RateLimit{Requests: requests, Period: d}, nil
+}
+
+func (l RateLimit) Enabled() bool {
+	return l.Requests > 0
+}
+
+func (l RateLimit) String() string {
+	if !l.Enabled() {
+		return "0"
+	}
+	return strconv.Itoa(l.Requests) + "/" + l.Period.String()
+}
+
+func (l RateLimit) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *RateLimit) UnmarshalText(text []byte) error {
+	parsed, err := ParseRateLimit(string(text))
+	if err != nil {
+		return err
+	}
+	*l = parsed
+	return nil
+}
+
+// RateLimitConfig throttles the public user endpoints. Registration, login
+// and magic link requests are limited per client IP and per email in the
+// request body; either may be disabled. Refresh, logout and magic link
+// verification are limited per IP only, as the account is not known until
+// the token has been verified.
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Store is memory, counting per instance, or redis, shared by all
+	// instances through Redis.URL.
+	Store           string      `yaml:"store"`
+	Register        RouteLimits `yaml:"register"`
+	Login           RouteLimits `yaml:"login"`
+	Refresh         RateLimit   `yaml:"refresh"`
+	Logout          RateLimit   `yaml:"logout"`
+	MagicLink       RouteLimits `yaml:"magic_link"`
+	MagicLinkVerify RateLimit   `yaml:"magic_link_verify"`
+}
+
+type RouteLimits struct {
+	IP      RateLimit `yaml:"ip"`
+	Account RateLimit `yaml:"account"`
+}
+
+var rateLimitStores = []string{"memory", "redis"}
+
+func (c *Config) validateRateLimit() []error {
+	var errs []error
+	if !c.RateLimit.Enabled {
+		return nil
+	}
+	if !contains(rateLimitStores, c.RateLimit.Store) {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_STORE must be one of %v, got %q", rateLimitStores, c.RateLimit.Store))
+	} else if c.RateLimit.Store == "redis" && c.Redis.URL == "" {
+		errs = append(errs, fmt.Errorf("REDIS_URL is required when RATE_LIMIT_STORE is redis"))
+	}
+	return errs
+}
+
+func validateTrustedProxies(proxies []string) []error {
+	var errs []error
+	for _, p := range proxies {
+		if _, err := netip.ParsePrefix(p); err != nil {
+			if _, err := netip.ParseAddr(p); err != nil {
+				errs = append(errs, fmt.Errorf("TRUSTED_PROXIES entry %q is not an IP address or CIDR", p))
+			}
+		}
+	}
+	return errs
+}
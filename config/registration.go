@@ -0,0 +1,64 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RegistrationConfig decides who may create an account.
+type RegistrationConfig struct {
+	// Mode is open, where anyone may register with POST /user,
+	// invite_only, where accounts are only created by accepting an
+	// invitation, or closed, where no accounts are created and invitations
+	// only add existing users to organizations. Operator commands may create
+	// users in any mode.
+	Mode string `yaml:"mode"`
+	// InvitationTTL is how long an invitation can be accepted for, counted
+	// again from each resend.
+	InvitationTTL time.Duration `yaml:"invitation_ttl"`
+	// InvitationURL, if set, is the page accepting invitations. Invitation
+	// responses then carry it with the token appended as the token query
+	// parameter.
+	InvitationURL string `yaml:"invitation_url"`
+	// AllowedDomains, if any, are the only email domains that may register;
+	// BlockedDomains may not. A domain also covers its subdomains.
+	// Invitations are not checked, as an administrator chose the address.
+	AllowedDomains []string `yaml:"allowed_domains"`
+	BlockedDomains []string `yaml:"blocked_domains"`
+	// BlockDisposable rejects the domains of the bundled list of disposable
+	// email providers.
+	BlockDisposable bool `yaml:"block_disposable"`
+}
+
+var registrationModes = []string{"open", "invite_only", "closed"}
+
+func (c RegistrationConfig) validate() []error {
+	var errs []error
+	if !contains(registrationModes, c.Mode) {
+		errs = append(errs, fmt.Errorf("REGISTRATION_MODE must be one of %v, got %q", registrationModes, c.Mode))
+	}
+	if c.InvitationTTL <= 0 {
+		errs = append(errs, errors.New("INVITATION_TTL must be positive"))
+	}
+	if c.InvitationURL != "" {
+		if u, err := url.Parse(c.InvitationURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("INVITATION_URL must be an http(s) URL, got %q", c.InvitationURL))
+		}
+	}
+	errs = append(errs, validateDomains("REGISTRATION_ALLOWED_DOMAINS", c.AllowedDomains)...)
+	errs = append(errs, validateDomains("REGISTRATION_BLOCKED_DOMAINS", c.BlockedDomains)...)
+	return errs
+}
+
+func validateDomains(key string, domains []string) []error {
+	var errs []error
+	for _, d := range domains {
+		if d == "" || strings.ContainsAny(d, "@/ ") || strings.HasPrefix(d, ".") {
+			errs = append(errs, fmt.Errorf("%s must list domains such as example.com, got %q", key, d))
+		}
+	}
+	return errs
+}
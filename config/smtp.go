@@ -0,0 +1,42 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"time"
+)
+
+// SMTPConfig is the mail server login links are sent through. The
+// connection is upgraded with STARTTLS whenever the server offers it.
+type SMTPConfig struct {
+	// Addr is the host:port of the server, e.g. smtp.example.com:587.
+	Addr string `yaml:"addr"`
+	// Username and Password log in with PLAIN authentication, which is
+	// only done over TLS or to localhost. Without a username no login is
+	// attempted.
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// From is the sender address of the emails.
+	From string `yaml:"from"`
+	// Timeout bounds sending each email.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func (c *Config) validateSMTP() []error {
+	if !c.MagicLink.Enabled {
+		return nil
+	}
+	var errs []error
+	if _, _, err := net.SplitHostPort(c.SMTP.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("SMTP_ADDR must be a host:port when MAGIC_LINK_ENABLED is set, got %q", c.SMTP.Addr))
+	}
+	if _, err := mail.ParseAddress(c.SMTP.From); err != nil {
+		errs = append(errs, fmt.Errorf("SMTP_FROM must be an email address when MAGIC_LINK_ENABLED is set, got %q", c.SMTP.From))
+	}
+	if c.SMTP.Timeout <= 0 {
+		errs = append(errs, errors.New("SMTP_TIMEOUT must be positive"))
+	}
+	return errs
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadFile decodes a YAML config file over cfg. Unknown keys are rejected so
+// typos don't silently fall back to defaults.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// envLoader overrides config fields from environment variables, leaving the
+// current value untouched when a variable is unset, and collects parse errors.
+type envLoader struct {
+	errs []error
+}
+
+func (l *envLoader) err() error {
+	return errors.Join(l.errs...)
+}
+
+func (l *envLoader) string(dst *string, key string) {
+	if v, ok := os.LookupEnv(key); ok {
+		*dst = v
+	}
+}
+
+// secret reads key, or the contents of the file named by key_FILE as used by
+// Docker and Kubernetes secrets. Setting both is an error.
+func (l *envLoader) secret(dst *string, key string) {
+	v, ok := os.LookupEnv(key)
+	path, fromFile := os.LookupEnv(key + "_FILE")
+	if ok && fromFile {
+		l.errs = append(l.errs, fmt.Errorf("only one of %s and %s_FILE may be set", key, key))
+		return
+	}
+	if fromFile {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%s_FILE: %w", key, err))
+			return
+		}
+		v, ok = strings.TrimRight(string(data), "\r\n"), true
+	}
+	if ok {
+		*dst = v
+	}
+}
+
+func (l *envLoader) int(dst *int, key string) {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s must be an integer, got %q", key, v))
+		return
+	}
+	*dst = n
+}
+
+func (l *envLoader) bool(dst *bool, key string) {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s must be a boolean, got %q", key, v))
+		return
+	}
+	*dst = b
+}
+
+func (l *envLoader) float(dst *float64, key string) {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s must be a number, got %q", key, v))
+		return
+	}
+	*dst = f
+}
+
+func (l *envLoader) duration(dst *time.Duration, key string) {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s must be a duration such as 30s or 1h, got %q", key, v))
+		return
+	}
+	*dst = d
+}
+
+// list reads a comma-separated list, dropping empty items.
+func (l *envLoader) list(dst *[]string, key string) {
+	if v, ok := os.LookupEnv(key); ok {
+		*dst = splitList(v)
+	}
+}
+
+func (l *envLoader) rateLimit(dst *RateLimit, key string) {
+	var v string
+	l.string(&v, key)
+	if v == "" {
+		return
+	}
+	limit, err := ParseRateLimit(v)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %w", key, err))
+		return
+	}
+	*dst = limit
+}
+
+// secretList reads a comma-separated secret, dropping empty items.
+func (l *envLoader) secretList(dst *[]string, key string) {
+	var v string
+	l.secret(&v, key)
+	if v != "" {
+		*dst = splitList(v)
+	}
+}
+
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TenancyConfig decides which organization, or tenant, a request belongs
+// to. Users only exist in the organizations they are members of, and tokens
+// are only accepted by the organization they were issued for.
+type TenancyConfig struct {
+	// Mode is single, where every request belongs to the default
+	// organization, header or subdomain.
+	Mode string `yaml:"mode"`
+	// Header carries the organization's slug in header mode.
+	Header string `yaml:"header"`
+	// BaseDomain is the domain under which each organization has its
+	// subdomain in subdomain mode, e.g. auth.example.com for
+	// acme.auth.example.com.
+	BaseDomain string `yaml:"base_domain"`
+	// Uniqueness is global, where a username or email can only be
+	// registered once across all organizations, or tenant, where each
+	// organization registers its own users independently. It applies to
+	// users registered from then on.
+	Uniqueness string `yaml:"uniqueness"`
+}
+
+var (
+	tenancyModes      = []string{"single", "header", "subdomain"}
+	tenancyUniqueness = []string{"global", "tenant"}
+)
+
+func (c TenancyConfig) validate() []error {
+	var errs []error
+	if !contains(tenancyModes, c.Mode) {
+		errs = append(errs, fmt.Errorf("TENANCY_MODE must be one of %v, got %q", tenancyModes, c.Mode))
+	}
+	if c.Mode == "header" && c.Header == "" {
+		errs = append(errs, errors.New("TENANCY_HEADER is required when TENANCY_MODE is header"))
+	}
+	if c.Mode == "subdomain" && (c.BaseDomain == "" || strings.HasPrefix(c.BaseDomain, ".")) {
+		errs = append(errs, errors.New("TENANCY_BASE_DOMAIN must be a domain such as auth.example.com when TENANCY_MODE is subdomain"))
+	}
+	if !contains(tenancyUniqueness, c.Uniqueness) {
+		errs = append(errs, fmt.Errorf("TENANCY_UNIQUENESS must be one of %v, got %q", tenancyUniqueness, c.Uniqueness))
+	}
+	return errs
+}
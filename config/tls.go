@@ -0,0 +1,71 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+// TLSConfig serves the public API over HTTPS instead of plain HTTP.
+type TLSConfig struct {
+	// CertFile and KeyFile are PEM files. They are reloaded when they
+	// change, so renewed certificates are picked up without a restart.
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// ReloadInterval is how often the files are checked for changes.
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+	// SelfSigned generates a throwaway certificate for localhost at
+	// startup, for local development only.
+	SelfSigned bool `yaml:"self_signed"`
+}
+
+// Enabled reports whether the API is served over HTTPS.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.SelfSigned
+}
+
+// InternalConfig serves endpoints for other services, such as token
+// introspection, on a separate port that requires client certificates
+// (mTLS). It uses the certificate of TLSConfig.
+type InternalConfig struct {
+	// Port is the internal listener's port. Zero disables it.
+	Port int `yaml:"port"`
+	// ClientCAFile is a PEM bundle of the CAs client certificates must
+	// chain to.
+	ClientCAFile string `yaml:"client_ca_file"`
+	// AllowedClients are the subjects of the client certificates allowed
+	// to call, matched against the common name or the full subject such as
+	// "CN=billing,O=Example".
+	AllowedClients []string `yaml:"allowed_clients"`
+}
+
+func (c *Config) validateTLS() []error {
+	var errs []error
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+	if c.TLS.CertFile != "" && c.TLS.SelfSigned {
+		errs = append(errs, errors.New("TLS_SELF_SIGNED can't be combined with TLS_CERT_FILE"))
+	}
+	if c.TLS.ReloadInterval <= 0 {
+		errs = append(errs, errors.New("TLS_RELOAD_INTERVAL must be positive"))
+	}
+
+	if c.Internal.Port == 0 {
+		return errs
+	}
+	if c.Internal.Port < 0 || c.Internal.Port > 65535 {
+		errs = append(errs, errors.New("INTERNAL_PORT must be between 0 and 65535"))
+	} else if c.Internal.Port == c.Port || c.Internal.Port == c.MetricsPort {
+		errs = append(errs, errors.New("INTERNAL_PORT must differ from PORT and METRICS_PORT"))
+	}
+	if !c.TLS.Enabled() {
+		errs = append(errs, errors.New("INTERNAL_PORT requires TLS_CERT_FILE or TLS_SELF_SIGNED"))
+	}
+	if c.Internal.ClientCAFile == "" {
+		errs = append(errs, errors.New("INTERNAL_CLIENT_CA_FILE is required with INTERNAL_PORT"))
+	}
+	if len(c.Internal.AllowedClients) == 0 {
+		errs = append(errs, errors.New("INTERNAL_ALLOWED_CLIENTS is required with INTERNAL_PORT"))
+	}
+	return errs
+}
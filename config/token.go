@@ -0,0 +1,108 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Token roles, matching whether the user is an admin.
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+var tokenRoles = []string{RoleAdmin, RoleUser}
+
+// TokenLifetimes bound how long tokens and sessions last. A session starts
+// at login and is extended by each refresh, which issues a new refresh
+// token.
+type TokenLifetimes struct {
+	// Access is how long an access token is valid.
+	Access time.Duration `yaml:"access"`
+	// Refresh is how long a refresh token is valid, so a session that isn't
+	// refreshed for this long ends.
+	Refresh time.Duration `yaml:"refresh"`
+	// RememberMe replaces Refresh for logins asking to stay signed in. Zero
+	// ignores the request.
+	RememberMe time.Duration `yaml:"remember_me"`
+	// MaxSession ends a session this long after login, however often it is
+	// refreshed. Zero doesn't bound sessions.
+	MaxSession time.Duration `yaml:"max_session"`
+}
+
+// TokenConfig holds the default lifetimes and overrides for logins from a
+// given client_id and for users with a given role. Zero fields of an
+// override keep the value below it; a role override takes precedence over a
+// client one, so it can tighten limits for admins on every client.
+type TokenConfig struct {
+	TokenLifetimes `yaml:",inline"`
+	Clients        map[string]TokenLifetimes `yaml:"clients"`
+	Roles          map[string]TokenLifetimes `yaml:"roles"`
+}
+
+// Lifetimes returns the lifetimes for a login from clientID, empty for none,
+// by a user with role. It reports false for a client that isn't configured.
+func (c TokenConfig) Lifetimes(clientID, role string) (TokenLifetimes, bool) {
+	l := c.TokenLifetimes
+	if clientID != "" {
+		client, ok := c.Clients[clientID]
+		if !ok {
+			return TokenLifetimes{}, false
+		}
+		l = l.override(client)
+	}
+	return l.override(c.Roles[role]), true
+}
+
+func (l TokenLifetimes) override(o TokenLifetimes) TokenLifetimes {
+	if o.Access != 0 {
+		l.Access = o.Access
+	}
+	if o.Refresh != 0 {
+		l.Refresh = o.Refresh
+	}
+	if o.RememberMe != 0 {
+		l.RememberMe = o.RememberMe
+	}
+	if o.MaxSession != 0 {
+		l.MaxSession = o.MaxSession
+	}
+	return l
+}
+
+func (c TokenConfig) validate() []error {
+	var errs []error
+	if c.Access <= 0 {
+		errs = append(errs, errors.New("TOKEN_ACCESS_TTL must be positive"))
+	}
+	if c.Refresh <= 0 {
+		errs = append(errs, errors.New("TOKEN_REFRESH_TTL must be positive"))
+	}
+	if c.RememberMe < 0 {
+		errs = append(errs, errors.New("TOKEN_REMEMBER_ME_TTL must not be negative"))
+	}
+	if c.MaxSession < 0 {
+		errs = append(errs, errors.New("TOKEN_MAX_SESSION must not be negative"))
+	}
+	for id, l := range c.Clients {
+		if id == "" {
+			errs = append(errs, errors.New("token client IDs must not be empty"))
+		}
+		errs = append(errs, l.validateOverride(fmt.Sprintf("token lifetimes of client %q", id))...)
+	}
+	for role, l := range c.Roles {
+		if !contains(tokenRoles, role) {
+			errs = append(errs, fmt.Errorf("token role must be one of %v, got %q", tokenRoles, role))
+		}
+		errs = append(errs, l.validateOverride(fmt.Sprintf("token lifetimes of role %q", role))...)
+	}
+	return errs
+}
+
+func (l TokenLifetimes) validateOverride(name string) []error {
+	if l.Access < 0 || l.Refresh < 0 || l.RememberMe < 0 || l.MaxSession < 0 {
+		return []error{fmt.Errorf("%s must not be negative", name)}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Package audit carries request details into audit events and copies
+// recorded events to optional sinks besides the database.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+)
+
+// Sink receives every audit event once it has been stored.
+type Sink interface {
+	Write(ctx context.Context, event model.AuditEvent) error
+}
+
+// FileSink appends events to a file as JSON lines, for shipping to a log
+// pipeline or SIEM independently of the database.
+type FileSink struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// OpenFileSink opens path for appending, creating it if needed.
+func OpenFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (s *FileSink) Write(_ context.Context, event model.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(event)
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+// RequestInfo describes the HTTP request an event originated from.
+type RequestInfo struct {
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+type requestInfoKey struct{}
+
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFromContext returns the request details stored in ctx. It is
+// empty outside HTTP requests, e.g. for CLI commands.
+func RequestInfoFromContext(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info
+}
+
+// Middleware stores the client IP, user agent and request ID in the request
+// context. It must run after Echo's RequestID middleware.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			info := RequestInfo{
+				IP:        c.RealIP(),
+				UserAgent: req.UserAgent(),
+				RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+			}
+			c.SetRequest(req.WithContext(WithRequestInfo(req.Context(), info)))
+			return next(c)
+		}
+	}
+}
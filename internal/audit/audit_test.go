@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+)
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	events := []model.AuditEvent{
+		{ID: uuid.New(), Action: model.AuditLogin, Outcome: model.AuditSuccess},
+		{ID: uuid.New(), Action: model.AuditLogin, Outcome: model.AuditFailure, Reason: "invalid_password"},
+	}
+
+	// events are appended across reopenings
+	for _, event := range events {
+		sink, err := OpenFileSink(path)
+		assert.NoError(t, err)
+		assert.NoError(t, sink.Write(context.Background(), event))
+		assert.NoError(t, sink.Close())
+	}
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	var got []model.AuditEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event model.AuditEvent
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		got = append(got, event)
+	}
+	assert.Equal(t, events, got)
+}
+
+func TestMiddleware(t *testing.T) {
+	e := echo.New()
+	e.Use(middleware.RequestID())
+	e.Use(Middleware())
+	var info RequestInfo
+	e.GET("/", func(c echo.Context) error {
+		info = RequestInfoFromContext(c.Request().Context())
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.7:51234"
+	req.Header.Set("User-Agent", "curl/8.0")
+	req.Header.Set(echo.HeaderXRequestID, "req-1")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, RequestInfo{IP: "203.0.113.7", UserAgent: "curl/8.0", RequestID: "req-1"}, info)
+}
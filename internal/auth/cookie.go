@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+// Cookie names of a cookie session.
+const (
+	AccessCookie  = "access_token"
+	RefreshCookie = "refresh_token"
+	// CSRFCookie is readable by JavaScript, which must echo it in CSRFHeader
+	// on state-changing requests authenticated by cookie (double submit).
+	// Its value is signed for the session, so a cookie planted by another
+	// subdomain doesn't pass.
+	CSRFCookie = "csrf_token"
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// RefreshCookiePath scopes the refresh cookie to the user endpoints, which
+// include refresh and logout, so it isn't sent with other API calls.
+const RefreshCookiePath = "/api/v1/user"
+
+// Cookies reads and writes the cookies of cookie sessions.
+type Cookies struct {
+	domain   string
+	secure   bool
+	sameSite http.SameSite
+	// csrfKey signs CSRF tokens
+	csrfKey []byte
+}
+
+// NewCookies signs CSRF tokens with a key derived from secret, the JWT
+// signing secret, so rotating it also invalidates them.
+func NewCookies(cfg config.CookieConfig, secret string) *Cookies {
+	sameSite := http.SameSiteStrictMode
+	switch cfg.SameSite {
+	case "lax":
+		sameSite = http.SameSiteLaxMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("csrf"))
+	return &Cookies{domain: cfg.Domain, secure: cfg.Secure, sameSite: sameSite, csrfKey: mac.Sum(nil)}
+}
+
+// SetSession stores the tokens in HttpOnly cookies that expire with them,
+// along with a new CSRF token for their session, which it returns.
+func (k *Cookies) SetSession(c echo.Context, accessToken, refreshToken string) (string, error) {
+	nonce, err := utils.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	refreshClaims := unverifiedClaims(refreshToken)
+	csrfToken := nonce + "." + k.csrfSignature(refreshClaims.SessionID, nonce)
+	refreshExpiry := expiry(refreshClaims)
+	c.SetCookie(k.cookie(AccessCookie, accessToken, "/", expiry(unverifiedClaims(accessToken)), true))
+	c.SetCookie(k.cookie(RefreshCookie, refreshToken, RefreshCookiePath, refreshExpiry, true))
+	c.SetCookie(k.cookie(CSRFCookie, csrfToken, "/", refreshExpiry, false))
+	return csrfToken, nil
+}
+
+// Clear removes the session cookies.
+func (k *Cookies) Clear(c echo.Context) {
+	for _, cookie := range []*http.Cookie{
+		k.cookie(AccessCookie, "", "/", time.Time{}, true),
+		k.cookie(RefreshCookie, "", RefreshCookiePath, time.Time{}, true),
+		k.cookie(CSRFCookie, "", "/", time.Time{}, false),
+	} {
+		cookie.MaxAge = -1
+		c.SetCookie(cookie)
+	}
+}
+
+// RefreshToken returns the refresh token cookie of the request, if any.
+func (k *Cookies) RefreshToken(c echo.Context) (string, bool) {
+	return cookieValue(c, RefreshCookie)
+}
+
+func (k *Cookies) cookie(name, value, path string, expires time.Time, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   k.domain,
+		Expires:  expires,
+		Secure:   k.secure,
+		HttpOnly: httpOnly,
+		SameSite: k.sameSite,
+	}
+}
+
+// CheckCSRF reports whether a request authenticated by cookie in the
+// session sessionID may proceed: safe methods always may, others must send
+// the CSRF cookie's value in CSRFHeader, signed for the session. Another
+// site can make the browser send the cookies but can't read them to set the
+// header, nor sign a token of its own.
+func (k *Cookies) CheckCSRF(c echo.Context, sessionID string) bool {
+	switch c.Request().Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	cookie, ok := cookieValue(c, CSRFCookie)
+	header := c.Request().Header.Get(CSRFHeader)
+	if !ok || subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) != 1 {
+		return false
+	}
+	nonce, signature, ok := strings.Cut(header, ".")
+	return ok && sessionID != "" && hmac.Equal([]byte(signature), []byte(k.csrfSignature(sessionID, nonce)))
+}
+
+func (k *Cookies) csrfSignature(sessionID, nonce string) string {
+	mac := hmac.New(sha256.New, k.csrfKey)
+	mac.Write([]byte(sessionID))
+	mac.Write([]byte("."))
+	mac.Write([]byte(nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SessionID returns the sid claim of a token without validating it, to
+// match CSRF tokens before the token itself is validated.
+func SessionID(raw string) string {
+	return unverifiedClaims(raw).SessionID
+}
+
+func cookieValue(c echo.Context, name string) (string, bool) {
+	cookie, err := c.Cookie(name)
+	if err != nil || cookie.Value == "" {
+		return "", false
+	}
+	return cookie.Value, true
+}
+
+// sessionClaims are the claims cookies need from either token type.
+type sessionClaims struct {
+	SessionID string `json:"sid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// unverifiedClaims reads the claims of a token without validating it, as
+// for tokens just issued by this service.
+func unverifiedClaims(raw string) sessionClaims {
+	var claims sessionClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(raw, &claims); err != nil {
+		return sessionClaims{}
+	}
+	return claims
+}
+
+// expiry returns the exp claim, or the zero time, making a session cookie,
+// if there is none.
+func expiry(claims sessionClaims) time.Time {
+	if claims.ExpiresAt == nil {
+		return time.Time{}
+	}
+	return claims.ExpiresAt.Time
+}
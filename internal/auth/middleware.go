@@ -0,0 +1,103 @@
+// Package auth authenticates API requests with access tokens.
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/tenant"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+// ContextKey is the echo.Context key the Principal is stored under.
+const ContextKey = "principal"
+
+// Middleware rejects requests without a valid access token in a Bearer
+// Authorization header with 401. Tokens are validated with
+// utils.ValidateAccessToken, so refresh tokens and tokens for another issuer
+// or audience are rejected too. The caller's Principal is stored in both the
+// echo.Context and the request's context.Context.
+//
+// On tenant-scoped routes, tokens issued for another organization than the
+// request's are rejected too.
+//
+// With cookies set, requests without the header may instead authenticate
+// with the access token cookie, and state-changing ones must then pass
+// Cookies.CheckCSRF or are rejected with 403.
+func Middleware(opts utils.JWTOptions, cookies *Cookies) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			raw, ok := bearerToken(c.Request())
+			fromCookie := false
+			if !ok && cookies != nil {
+				raw, ok = cookieValue(c, AccessCookie)
+				fromCookie = ok
+			}
+			if !ok {
+				return unauthorized(c, "")
+			}
+			claims, err := utils.ValidateAccessToken(raw, opts)
+			if err != nil {
+				return unauthorized(c, "invalid_token")
+			}
+			if fromCookie && !cookies.CheckCSRF(c, claims.SessionID) {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": "Missing or invalid CSRF token"})
+			}
+			p, err := principal(claims)
+			if err != nil {
+				return unauthorized(c, "invalid_token")
+			}
+			if t, ok := tenant.FromContext(c.Request().Context()); ok && t.ID != p.TenantID {
+				return unauthorized(c, "invalid_token")
+			}
+
+			c.Set(ContextKey, p)
+			c.SetRequest(c.Request().WithContext(WithPrincipal(c.Request().Context(), p)))
+			return next(c)
+		}
+	}
+}
+
+func principal(claims *utils.Claims) (Principal, error) {
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return Principal{}, err
+	}
+	tenantID, err := claims.OrgID()
+	if err != nil {
+		return Principal{}, err
+	}
+	return Principal{
+		UserID:    userID,
+		Username:  claims.Username,
+		Email:     claims.Email,
+		IsAdmin:   claims.IsAdmin,
+		ClientID:  claims.ClientID,
+		TenantID:  tenantID,
+		Org:       claims.Org,
+		OrgRole:   claims.OrgRole,
+		TokenID:   claims.ID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get(echo.HeaderAuthorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// unauthorized answers 401 with a WWW-Authenticate challenge as in RFC 6750.
+func unauthorized(c echo.Context, code string) error {
+	challenge := "Bearer"
+	if code != "" {
+		challenge += ` error="` + code + `"`
+	}
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, challenge)
+	return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+}
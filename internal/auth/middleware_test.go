@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/tenant"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+func TestMiddleware(t *testing.T) {
+	opts := utils.JWTOptions{Key: "test-secret-key", Issuer: "test-issuer", Audience: "test-audience"}
+	user := model.User{ID: uuid.New(), Username: "alice"}
+	access, refresh, err := utils.GenerateJWT(user, opts, utils.Session{AuthTime: time.Now(), Lifetimes: config.Default().Token.TokenLifetimes})
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		authorization string
+		tenant        *tenant.Tenant
+		expectedCode  int
+		expectedAuth  string
+	}{
+		{name: "Valid", authorization: "Bearer " + access, expectedCode: http.StatusOK},
+		{name: "Lowercase Scheme", authorization: "bearer " + access, expectedCode: http.StatusOK},
+		{name: "Missing", expectedCode: http.StatusUnauthorized, expectedAuth: "Bearer"},
+		{name: "Wrong Scheme", authorization: "Basic " + access, expectedCode: http.StatusUnauthorized, expectedAuth: "Bearer"},
+		{name: "Refresh Token", authorization: "Bearer " + refresh, expectedCode: http.StatusUnauthorized, expectedAuth: `Bearer error="invalid_token"`},
+		{name: "Garbage", authorization: "Bearer not-a-jwt", expectedCode: http.StatusUnauthorized, expectedAuth: `Bearer error="invalid_token"`},
+		{name: "Same Organization", authorization: "Bearer " + access, tenant: &tenant.Default, expectedCode: http.StatusOK},
+		{name: "Other Organization", authorization: "Bearer " + access, tenant: &tenant.Tenant{ID: uuid.New(), Slug: "acme"}, expectedCode: http.StatusUnauthorized, expectedAuth: `Bearer error="invalid_token"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.authorization != "" {
+				req.Header.Set(echo.HeaderAuthorization, tc.authorization)
+			}
+			if tc.tenant != nil {
+				req = req.WithContext(tenant.WithTenant(req.Context(), *tc.tenant))
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err := Middleware(opts, nil)(func(c echo.Context) error {
+				p, ok := FromEcho(c)
+				assert.True(t, ok)
+				assert.Equal(t, user.ID, p.UserID)
+				assert.Equal(t, "alice", p.Username)
+				assert.Equal(t, model.DefaultOrganizationID, p.TenantID)
+				fromCtx, ok := FromContext(c.Request().Context())
+				assert.True(t, ok)
+				assert.Equal(t, p, fromCtx)
+				return c.NoContent(http.StatusOK)
+			})(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			assert.Equal(t, tc.expectedAuth, rec.Header().Get(echo.HeaderWWWAuthenticate))
+		})
+	}
+}
+
+func TestFromContext_Missing(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	c.Set(ContextKey, "not a principal")
+	_, ok = FromEcho(c)
+	assert.False(t, ok)
+}
+
+func TestMiddleware_Cookies(t *testing.T) {
+	opts := utils.JWTOptions{Key: "test-secret-key", Issuer: "test-issuer", Audience: "test-audience"}
+	user := model.User{ID: uuid.New(), Username: "alice"}
+	access, refresh, err := utils.GenerateJWT(user, opts, utils.Session{AuthTime: time.Now(), Lifetimes: config.Default().Token.TokenLifetimes})
+	assert.NoError(t, err)
+	cookies := NewCookies(config.Default().Cookie, opts.Key)
+
+	// start a session to get its cookies
+	rec := httptest.NewRecorder()
+	csrfToken, err := cookies.SetSession(echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec), access, refresh)
+	assert.NoError(t, err)
+	sessionCookies := rec.Result().Cookies()
+	if assert.Len(t, sessionCookies, 3) {
+		assert.True(t, sessionCookies[0].HttpOnly)
+		assert.True(t, sessionCookies[0].Secure)
+		assert.Equal(t, http.SameSiteStrictMode, sessionCookies[0].SameSite)
+		assert.Equal(t, RefreshCookiePath, sessionCookies[1].Path)
+		assert.False(t, sessionCookies[2].HttpOnly)
+		assert.Equal(t, csrfToken, sessionCookies[2].Value)
+	}
+
+	// a valid CSRF token of another session, as planted in the cookie by
+	// an attacker controlling a sibling subdomain
+	otherAccess, otherRefresh, err := utils.GenerateJWT(model.User{ID: uuid.New()}, opts, utils.Session{AuthTime: time.Now(), Lifetimes: config.Default().Token.TokenLifetimes})
+	assert.NoError(t, err)
+	otherCSRFToken, err := cookies.SetSession(echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder()), otherAccess, otherRefresh)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		method       string
+		csrfHeader   string
+		csrfCookie   string
+		cookies      *Cookies
+		expectedCode int
+	}{
+		{name: "Safe Method", method: http.MethodGet, cookies: cookies, expectedCode: http.StatusOK},
+		{name: "Unsafe Method With CSRF Token", method: http.MethodPut, csrfHeader: csrfToken, cookies: cookies, expectedCode: http.StatusOK},
+		{name: "Unsafe Method Without CSRF Token", method: http.MethodPut, cookies: cookies, expectedCode: http.StatusForbidden},
+		{name: "Unsafe Method With Wrong CSRF Token", method: http.MethodPut, csrfHeader: "forged", cookies: cookies, expectedCode: http.StatusForbidden},
+		{name: "Unsafe Method With Planted CSRF Token", method: http.MethodPut, csrfHeader: otherCSRFToken, csrfCookie: otherCSRFToken, cookies: cookies, expectedCode: http.StatusForbidden},
+		{name: "Unsafe Method With Unsigned CSRF Token", method: http.MethodPut, csrfHeader: "unsigned", csrfCookie: "unsigned", cookies: cookies, expectedCode: http.StatusForbidden},
+		{name: "Cookie Sessions Disabled", method: http.MethodGet, expectedCode: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/", nil)
+			for _, cookie := range sessionCookies {
+				if cookie.Name == CSRFCookie && tc.csrfCookie != "" {
+					cookie = &http.Cookie{Name: CSRFCookie, Value: tc.csrfCookie}
+				}
+				req.AddCookie(cookie)
+			}
+			if tc.csrfHeader != "" {
+				req.Header.Set(CSRFHeader, tc.csrfHeader)
+			}
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+
+			err := Middleware(opts, tc.cookies)(func(c echo.Context) error {
+				p, ok := FromEcho(c)
+				assert.True(t, ok)
+				assert.Equal(t, user.ID, p.UserID)
+				return c.NoContent(http.StatusOK)
+			})(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+		})
+	}
+}
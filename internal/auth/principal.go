@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// Principal is the authenticated user a request acts for, taken from its
+// access token.
+type Principal struct {
+	UserID   uuid.UUID
+	Username string
+	Email    string
+	IsAdmin  bool
+	// ClientID is the client_id the session was started with, if any.
+	ClientID string
+	// TenantID and Org identify the organization the token was issued for,
+	// and OrgRole is the user's role in it.
+	TenantID uuid.UUID
+	Org      string
+	OrgRole  string
+	// TokenID and ExpiresAt are the jti and exp of the access token.
+	TokenID   string
+	ExpiresAt time.Time
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal stored in ctx by Middleware and whether
+// there is one, so services can tell who they act for.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// FromEcho is FromContext for handlers.
+func FromEcho(c echo.Context) (Principal, bool) {
+	p, ok := c.Get(ContextKey).(Principal)
+	return p, ok
+}
@@ -0,0 +1,21 @@
+// Package cache provides the key-value stores used to cache users and to
+// share revoked token IDs: an in-process LRU and Redis.
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Cache stores values under string keys for a limited time.
+type Cache interface {
+	// Get returns the value stored under key and whether it was found.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key for ttl, replacing any previous value.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Add stores value under key for ttl unless key holds a value already,
+	// and reports whether it did. Of concurrent Adds of one key, one wins.
+	Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// Delete removes keys. Missing keys are ignored.
+	Delete(ctx context.Context, keys ...string) error
+}
@@ -0,0 +1,202 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaches(t *testing.T) {
+	caches := map[string]func(t *testing.T) (Cache, func(time.Duration)){
+		"LRU": func(t *testing.T) (Cache, func(time.Duration)) {
+			now := time.Unix(1_700_000_000, 0)
+			c := NewLRU(10)
+			c.now = func() time.Time { return now }
+			return c, func(d time.Duration) { now = now.Add(d) }
+		},
+		"Redis": func(t *testing.T) (Cache, func(time.Duration)) {
+			mr := miniredis.RunT(t)
+			return NewRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "test:"), mr.FastForward
+		},
+	}
+
+	for name, newCache := range caches {
+		t.Run(name, func(t *testing.T) {
+			c, advance := newCache(t)
+			ctx := context.Background()
+
+			_, found, err := c.Get(ctx, "a")
+			assert.NoError(t, err)
+			assert.False(t, found)
+
+			assert.NoError(t, c.Set(ctx, "a", []byte("1"), time.Minute))
+			assert.NoError(t, c.Set(ctx, "b", []byte("2"), time.Minute))
+			value, found, err := c.Get(ctx, "a")
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, []byte("1"), value)
+
+			added, err := c.Add(ctx, "a", []byte("3"), time.Minute)
+			assert.NoError(t, err)
+			assert.False(t, added)
+			value, _, _ = c.Get(ctx, "a")
+			assert.Equal(t, []byte("1"), value, "kept by Add")
+
+			assert.NoError(t, c.Delete(ctx, "a", "missing"))
+			_, found, _ = c.Get(ctx, "a")
+			assert.False(t, found)
+			added, err = c.Add(ctx, "a", []byte("3"), time.Minute)
+			assert.NoError(t, err)
+			assert.True(t, added)
+
+			advance(time.Minute)
+			_, found, _ = c.Get(ctx, "b")
+			assert.False(t, found, "expired")
+		})
+	}
+}
+
+func TestLRU_Evicts(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2)
+
+	c.Set(ctx, "a", []byte("1"), time.Minute)
+	c.Set(ctx, "b", []byte("2"), time.Minute)
+	c.Get(ctx, "a") // b is now least recently used
+	c.Set(ctx, "c", []byte("3"), time.Minute)
+
+	assert.Equal(t, 2, c.Len())
+	_, found, _ := c.Get(ctx, "b")
+	assert.False(t, found)
+	_, found, _ = c.Get(ctx, "a")
+	assert.True(t, found)
+	_, found, _ = c.Get(ctx, "c")
+	assert.True(t, found)
+}
+
+func TestRedis_Prefix(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c := NewRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "cache:")
+
+	assert.NoError(t, c.Set(context.Background(), "user:1", []byte("x"), time.Minute))
+
+	assert.Equal(t, []string{"cache:user:1"}, mr.Keys())
+	assert.Equal(t, time.Minute, mr.TTL("cache:user:1"))
+}
+
+func TestDenylist(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	c := NewLRU(10)
+	c.now = func() time.Time { return now }
+	d := &denylist{cache: c, now: c.now}
+
+	assert.NoError(t, d.Revoke(ctx, "jti-1", now.Add(time.Hour)))
+	assert.NoError(t, d.Revoke(ctx, "jti-expired", now.Add(-time.Second)))
+
+	revoked, err := d.IsRevoked(ctx, "jti-1")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, _ = d.IsRevoked(ctx, "jti-2")
+	assert.False(t, revoked)
+	revoked, _ = d.IsRevoked(ctx, "jti-expired")
+	assert.False(t, revoked)
+	assert.Equal(t, 1, c.Len(), "expired tokens are not stored")
+
+	// forgotten once the token has expired
+	now = now.Add(time.Hour)
+	revoked, _ = d.IsRevoked(ctx, "jti-1")
+	assert.False(t, revoked)
+
+	// only the first RevokeOnce revokes
+	once, err := d.RevokeOnce(ctx, "jti-3", now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, once)
+	once, _ = d.RevokeOnce(ctx, "jti-3", now.Add(time.Hour))
+	assert.False(t, once)
+	once, _ = d.RevokeOnce(ctx, "jti-expired", now.Add(-time.Second))
+	assert.False(t, once)
+}
+
+func TestFailures(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	c := NewLRU(10)
+	c.now = func() time.Time { return now }
+	f := NewFailures(c)
+
+	n, err := f.Count(ctx, "login:bob@x.com")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	for want := 1; want <= 3; want++ {
+		n, err = f.Add(ctx, "login:bob@x.com", time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, want, n)
+	}
+	n, _ = f.Count(ctx, "login:alice@x.com")
+	assert.Equal(t, 0, n, "counted per key")
+
+	// each failure extends the window
+	now = now.Add(50 * time.Second)
+	_, _ = f.Add(ctx, "login:bob@x.com", time.Minute)
+	now = now.Add(50 * time.Second)
+	n, _ = f.Count(ctx, "login:bob@x.com")
+	assert.Equal(t, 4, n)
+
+	assert.NoError(t, f.Reset(ctx, "login:bob@x.com"))
+	n, _ = f.Count(ctx, "login:bob@x.com")
+	assert.Equal(t, 0, n)
+
+	_, _ = f.Add(ctx, "login:bob@x.com", time.Minute)
+	now = now.Add(time.Minute)
+	n, _ = f.Count(ctx, "login:bob@x.com")
+	assert.Equal(t, 0, n, "forgotten after the window")
+}
+
+func TestFailures_Concurrent(t *testing.T) {
+	counters := map[string]func(t *testing.T) Counter{
+		"LRU": func(t *testing.T) Counter { return NewLRU(10) },
+		"Redis": func(t *testing.T) Counter {
+			mr := miniredis.RunT(t)
+			return NewRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "test:")
+		},
+	}
+
+	for name, newCounter := range counters {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			f := NewFailures(newCounter(t))
+
+			const attempts = 50
+			var wg sync.WaitGroup
+			counts := make(chan int, attempts)
+			for range attempts {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					n, err := f.Add(ctx, "login:bob@x.com", time.Minute)
+					assert.NoError(t, err)
+					counts <- n
+				}()
+			}
+			wg.Wait()
+			close(counts)
+
+			// every failure is counted, each seeing a different count
+			seen := make(map[int]bool)
+			for n := range counts {
+				seen[n] = true
+			}
+			assert.Len(t, seen, attempts)
+			n, err := f.Count(ctx, "login:bob@x.com")
+			assert.NoError(t, err)
+			assert.Equal(t, attempts, n)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Denylist records revoked token IDs (the jti claim) until the tokens would
+// have expired anyway.
+type Denylist interface {
+	// Revoke rejects the token with ID id from now until expiresAt.
+	Revoke(ctx context.Context, id string, expiresAt time.Time) error
+	// RevokeOnce revokes id like Revoke and reports whether this call did,
+	// false if it was revoked already. Of concurrent calls for one id, one
+	// revokes it, so a token can be exchanged once.
+	RevokeOnce(ctx context.Context, id string, expiresAt time.Time) (bool, error)
+	IsRevoked(ctx context.Context, id string) (bool, error)
+}
+
+type denylist struct {
+	cache Cache
+	now   func() time.Time
+}
+
+// NewDenylist keeps revoked token IDs in c. An LRU that is too small forgets
+// revocations early, so it should be sized for the tokens revoked within a
+// refresh token lifetime and not shared with other data.
+func NewDenylist(c Cache) Denylist {
+	return &denylist{cache: c, now: time.Now}
+}
+
+func (d *denylist) Revoke(ctx context.Context, id string, expiresAt time.Time) error {
+	ttl := expiresAt.Sub(d.now())
+	if ttl <= 0 {
+		// already expired, nothing left to reject
+		return nil
+	}
+	return d.cache.Set(ctx, revokedKey(id), []byte{1}, ttl)
+}
+
+func (d *denylist) RevokeOnce(ctx context.Context, id string, expiresAt time.Time) (bool, error) {
+	ttl := expiresAt.Sub(d.now())
+	if ttl <= 0 {
+		// expired, so no longer usable either way
+		return false, nil
+	}
+	return d.cache.Add(ctx, revokedKey(id), []byte{1}, ttl)
+}
+
+func (d *denylist) IsRevoked(ctx context.Context, id string) (bool, error) {
+	_, found, err := d.cache.Get(ctx, revokedKey(id))
+	return found, err
+}
+
+func revokedKey(id string) string {
+	return "revoked:" + id
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Failures counts recent failures per key, such as failed logins to an
+// account.
+type Failures interface {
+	// Count returns the failures recorded under key and not yet forgotten.
+	Count(ctx context.Context, key string) (int, error)
+	// Add records a failure under key and returns the new count. The count
+	// is forgotten window after the last failure.
+	Add(ctx context.Context, key string, window time.Duration) (int, error)
+	// Reset forgets the failures under key.
+	Reset(ctx context.Context, key string) error
+}
+
+// Counter is a Cache that can also increment counts atomically.
+type Counter interface {
+	Cache
+	// Incr adds one to the count stored under key as a decimal number,
+	// starting from zero, keeps it for ttl and returns the new count.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int, error)
+}
+
+type failures struct {
+	cache Counter
+}
+
+// NewFailures keeps failure counts in c. Concurrent failures are each
+// counted, so they can't be used to keep an account under the captcha
+// threshold.
+func NewFailures(c Counter) Failures {
+	return &failures{cache: c}
+}
+
+func (f *failures) Count(ctx context.Context, key string) (int, error) {
+	v, found, err := f.cache.Get(ctx, failuresKey(key))
+	if err != nil || !found {
+		return 0, err
+	}
+	n, err := strconv.Atoi(string(v))
+	if err != nil {
+		// unreadable counts start over
+		return 0, nil
+	}
+	return n, nil
+}
+
+func (f *failures) Add(ctx context.Context, key string, window time.Duration) (int, error) {
+	return f.cache.Incr(ctx, failuresKey(key), window)
+}
+
+func (f *failures) Reset(ctx context.Context, key string) error {
+	return f.cache.Delete(ctx, failuresKey(key))
+}
+
+func failuresKey(key string) string {
+	return "failures:" + key
+}
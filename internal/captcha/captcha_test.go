@@ -0,0 +1,61 @@
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+)
+
+func TestSiteVerify(t *testing.T) {
+	var form map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		form = map[string]string{
+			"secret":   r.PostForm.Get("secret"),
+			"response": r.PostForm.Get("response"),
+			"remoteip": r.PostForm.Get("remoteip"),
+		}
+		if r.PostForm.Get("response") == "broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		res := siteVerifyResponse{Success: r.PostForm.Get("response") == "solved"}
+		if !res.Success {
+			res.ErrorCodes = []string{"invalid-input-response"}
+		}
+		_ = json.NewEncoder(w).Encode(res)
+	}))
+	defer srv.Close()
+
+	v := NewSiteVerify(config.CaptchaConfig{Secret: "s3cret", VerifyURL: srv.URL, Timeout: time.Second})
+	ctx := context.Background()
+
+	assert.NoError(t, v.Verify(ctx, "solved", "203.0.113.7"))
+	assert.Equal(t, map[string]string{"secret": "s3cret", "response": "solved", "remoteip": "203.0.113.7"}, form)
+
+	err := v.Verify(ctx, "unsolved", "")
+	assert.ErrorIs(t, err, ErrInvalid)
+	assert.ErrorContains(t, err, "invalid-input-response")
+
+	form = nil
+	assert.ErrorIs(t, v.Verify(ctx, "", ""), ErrInvalid)
+	assert.Nil(t, form, "an empty token is rejected without asking the provider")
+
+	err = v.Verify(ctx, "broken", "")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrInvalid)
+}
+
+func TestFake(t *testing.T) {
+	f := Fake{Token: "ok"}
+	assert.NoError(t, f.Verify(context.Background(), "ok", ""))
+	assert.ErrorIs(t, f.Verify(context.Background(), "nope", ""), ErrInvalid)
+	assert.ErrorIs(t, Fake{}.Verify(context.Background(), "", ""), ErrInvalid)
+}
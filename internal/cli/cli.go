@@ -0,0 +1,169 @@
+// Package cli implements the operator subcommands of the binary. Commands
+// share config.LoadConfig and the repository/service layers with the server.
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/audit"
+	"github.com/kevinmarcellius/go-simple-auth/internal/cache"
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository"
+	"github.com/kevinmarcellius/go-simple-auth/internal/service"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+type Command struct {
+	Name  string
+	Usage string
+	Run   func(ctx context.Context, cfg *config.Config, args []string) error
+}
+
+// Commands lists the operator subcommands. The server itself ("serve") is
+// registered by main.
+var Commands = []Command{
+	{Name: "migrate", Usage: "migrate up | down [steps] | status | check-collisions | normalize", Run: Migrate},
+	{Name: "user", Usage: "user create | promote | reset-password | disable | delete ...", Run: User},
+	{Name: "org", Usage: "org create | add-member | remove-member ...", Run: Org},
+	{Name: "keys", Usage: "keys rotate", Run: Keys},
+	{Name: "token", Usage: "token inspect | revoke <token>", Run: Token},
+	{Name: "config", Usage: "config print [--redacted]", Run: Config},
+}
+
+// PrintUsage writes the usage line of every command to w.
+func PrintUsage(w io.Writer, commands []Command) {
+	fmt.Fprintln(w, "Usage: go-simple-auth <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %s\n", c.Usage)
+	}
+}
+
+// OpenDB connects to Postgres and checks the connection is usable.
+func OpenDB(cfg *config.Config) (*gorm.DB, error) {
+	db, err := config.ConnectPostgres(cfg.Postgres)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.DBHealthCheck(db); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// OpenRedis connects to the Redis server at cfg.Redis.URL and checks it
+// answers.
+func OpenRedis(ctx context.Context, cfg *config.Config) (*redis.Client, error) {
+	opts, err := redis.ParseURL(cfg.Redis.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse REDIS_URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := client.Ping(pingCtx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connect to Redis: %w", err)
+	}
+	return client, nil
+}
+
+// NewCaches returns the user cache and the token denylist selected by
+// cfg.Cache.Store. rdb is only used, and must be set, for the redis store.
+// Memory stores are private to the process, so operator commands can't
+// evict users cached by the server or revoke tokens with them, and a
+// server's revocations on logout and refresh don't reach other replicas.
+// Deployments with several servers need the redis store.
+func NewCaches(cfg *config.Config, rdb redis.Cmdable) (cache.Cache, cache.Denylist) {
+	if cfg.Cache.Store == "redis" {
+		c := cache.NewRedis(rdb, "cache:")
+		return c, cache.NewDenylist(c)
+	}
+	// separate LRUs, so cached users can't push out revocations
+	return cache.NewLRU(cfg.Cache.Size), cache.NewDenylist(cache.NewLRU(cfg.Cache.Size))
+}
+
+// NewFailures returns the store counting failed logins, kept with the
+// cached users.
+func NewFailures(cfg *config.Config, rdb redis.Cmdable) cache.Failures {
+	if cfg.Cache.Store == "redis" {
+		return cache.NewFailures(cache.NewRedis(rdb, "cache:"))
+	}
+	return cache.NewFailures(cache.NewLRU(cfg.Cache.Size))
+}
+
+// openCaches is NewCaches for operator commands, connecting to Redis when
+// the store needs it. The returned function closes the connection.
+func openCaches(ctx context.Context, cfg *config.Config) (cache.Cache, cache.Denylist, func() error, error) {
+	if cfg.Cache.Store != "redis" {
+		users, denylist := NewCaches(cfg, nil)
+		return users, denylist, func() error { return nil }, nil
+	}
+	rdb, err := OpenRedis(ctx, cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	users, denylist := NewCaches(cfg, rdb)
+	return users, denylist, rdb.Close, nil
+}
+
+// NewUserService wires the user repository and service the same way for the
+// server and the CLI, so operator commands are audited like API calls, with
+// opts applied on top. The returned function closes the audit log file, if
+// one is configured.
+func NewUserService(cfg *config.Config, db *gorm.DB, users cache.Cache, denylist cache.Denylist, opts ...service.Option) (service.UserService, func() error, error) {
+	userRepository := repository.NewUserRepository(db, cfg.Postgres.QueryTimeout)
+	if cfg.Cache.UserTTL > 0 {
+		userRepository = repository.NewCachedUserRepository(userRepository, users, cfg.Cache.UserTTL)
+	}
+	// updates evict users even when this process doesn't cache them, as
+	// another one sharing the store may
+	txManager := repository.NewCachedTxManager(repository.NewTxManager(db, cfg.Postgres.QueryTimeout), users)
+	opts = append([]service.Option{
+		service.WithEmailPolicy(EmailPolicy(cfg)),
+		service.WithTokenConfig(cfg.Token),
+		service.WithDenylist(denylist),
+		service.WithTenancy(cfg.Tenancy),
+	}, opts...)
+
+	closeSinks := func() error { return nil }
+	if cfg.AuditLogFile != "" {
+		sink, err := audit.OpenFileSink(cfg.AuditLogFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open audit log file: %w", err)
+		}
+		opts = append(opts, service.WithAuditSinks(sink))
+		closeSinks = sink.Close
+	}
+	return service.NewUserService(userRepository, txManager, JWTOptions(cfg), opts...), closeSinks, nil
+}
+
+func JWTOptions(cfg *config.Config) utils.JWTOptions {
+	return utils.JWTOptions{
+		Key:      cfg.JWTkey,
+		Issuer:   cfg.JWT.Issuer,
+		Audience: cfg.JWT.Audience,
+		Leeway:   cfg.JWT.Leeway,
+	}
+}
+
+func EmailPolicy(cfg *config.Config) utils.EmailPolicy {
+	return utils.EmailPolicy{StripPlusTag: cfg.EmailStripPlusTag}
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(name, flag.ContinueOnError)
+}
+
+func usageError(usage string) error {
+	return fmt.Errorf("usage: %s", usage)
+}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"context"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+)
+
+// Config prints the effective configuration as YAML, in the same shape
+// accepted by CONFIG_FILE.
+func Config(ctx context.Context, cfg *config.Config, args []string) error {
+	const usage = "config print [--redacted]"
+	if len(args) == 0 || args[0] != "print" {
+		return usageError(usage)
+	}
+
+	fs := newFlagSet("config print")
+	redacted := fs.Bool("redacted", false, "mask secrets")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageError(usage)
+	}
+
+	out := *cfg
+	if *redacted {
+		out = cfg.Redacted()
+	}
+
+	enc := yaml.NewEncoder(os.Stdout)
+	defer enc.Close()
+	return enc.Encode(out)
+}
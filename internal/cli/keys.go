@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+func Keys(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) != 1 || args[0] != "rotate" {
+		return usageError("keys rotate")
+	}
+
+	secret, err := utils.RandomToken(48)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Generated a new JWT signing secret. Set it on every instance and restart:")
+	fmt.Println()
+	fmt.Printf("JWT_SECRET=%s\n", secret)
+	fmt.Println()
+	fmt.Println("Tokens signed with the current secret stop validating once the new one is deployed,")
+	fmt.Println("so every user has to log in again.")
+	return nil
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/migration"
+)
+
+func Migrate(ctx context.Context, cfg *config.Config, args []string) error {
+	const usage = "migrate up | down [steps] | status | check-collisions | normalize"
+	if len(args) == 0 {
+		return usageError(usage)
+	}
+
+	db, err := OpenDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer config.CloseDB(db)
+
+	if args[0] == "check-collisions" {
+		collisions, err := migration.FindCollisions(ctx, db, EmailPolicy(cfg))
+		if err != nil {
+			return err
+		}
+		for _, c := range collisions {
+			scope := "organization " + c.Scope.String()
+			if c.Scope == uuid.Nil {
+				scope = "all organizations"
+			}
+			fmt.Printf("%s %q is shared in %s by:\n", c.Field, c.Normalized, scope)
+			for _, u := range c.Users {
+				fmt.Printf("  %s\t%s\t%s\n", u.ID, u.Username, u.Email)
+			}
+		}
+		if len(collisions) > 0 {
+			return fmt.Errorf("found %d collisions, resolve them before applying 0002_case_insensitive_uniqueness", len(collisions))
+		}
+		fmt.Println("No collisions found.")
+		return nil
+	}
+	if args[0] == "normalize" {
+		changed, err := migration.Normalize(ctx, db, EmailPolicy(cfg))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("normalized %d users\n", changed)
+		return nil
+	}
+
+	migrator, err := migration.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("No pending migrations.")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid steps %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	default:
+		return usageError(usage)
+	}
+}
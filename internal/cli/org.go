@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+)
+
+func Org(ctx context.Context, cfg *config.Config, args []string) error {
+	const usage = "org create [-name <name>] <slug>\n" +
+		"       org add-member [-role owner|admin|member] <slug> <email>\n" +
+		"       org remove-member <slug> <email>"
+	if len(args) == 0 {
+		return usageError(usage)
+	}
+
+	db, err := OpenDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer config.CloseDB(db)
+	users, denylist, closeCaches, err := openCaches(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeCaches()
+	userService, closeAudit, err := NewUserService(cfg, db, users, denylist)
+	if err != nil {
+		return err
+	}
+	defer closeAudit()
+
+	switch args[0] {
+	case "create":
+		fs := newFlagSet("org create")
+		name := fs.String("name", "", "display name; defaults to the slug")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return usageError(usage)
+		}
+		org, err := userService.CreateOrganization(ctx, fs.Arg(0), *name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created organization %s (%s).\n", org.Slug, org.ID)
+		return nil
+	case "add-member":
+		fs := newFlagSet("org add-member")
+		role := fs.String("role", model.OrgRoleMember, "role in the organization")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 2 {
+			return usageError(usage)
+		}
+		if err := userService.AddMember(ctx, fs.Arg(0), fs.Arg(1), *role); err != nil {
+			return err
+		}
+		fmt.Printf("%s is now %s of %s.\n", fs.Arg(1), *role, fs.Arg(0))
+		return nil
+	case "remove-member":
+		if len(args) != 3 {
+			return usageError(usage)
+		}
+		if err := userService.RemoveMember(ctx, args[1], args[2]); err != nil {
+			return err
+		}
+		fmt.Printf("%s has been removed from %s.\n", args[2], args[1])
+		return nil
+	default:
+		return usageError(usage)
+	}
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+func Token(ctx context.Context, cfg *config.Config, args []string) error {
+	const usage = "token inspect <token>\n" +
+		"       token revoke <refresh-token>"
+	if len(args) != 2 {
+		return usageError(usage)
+	}
+	switch args[0] {
+	case "inspect":
+		return inspectToken(cfg, args[1])
+	case "revoke":
+		return revokeToken(ctx, cfg, args[1])
+	default:
+		return usageError(usage)
+	}
+}
+
+func inspectToken(cfg *config.Config, raw string) error {
+	claims := jwt.MapClaims{}
+	token, _, err := jwt.NewParser().ParseUnverified(raw, claims)
+	if err != nil {
+		return fmt.Errorf("cannot decode token: %w", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	fmt.Println("Header:")
+	if err := enc.Encode(token.Header); err != nil {
+		return err
+	}
+	fmt.Println("Claims:")
+	if err := enc.Encode(claims); err != nil {
+		return err
+	}
+
+	// validate as the type the token claims to be; a mismatch with where it
+	// is used is caught there
+	if typ, _ := claims["typ"].(string); typ == utils.TokenTypeRefresh {
+		_, err = utils.ValidateRefreshToken(raw, JWTOptions(cfg))
+	} else {
+		_, err = utils.ValidateAccessToken(raw, JWTOptions(cfg))
+	}
+	if err != nil {
+		fmt.Printf("Valid: no (%v)\n", err)
+		return nil
+	}
+	fmt.Println("Valid: yes")
+	return nil
+}
+
+// revokeToken adds a refresh token's ID to the shared denylist until it
+// expires, so it can no longer be exchanged for access tokens.
+func revokeToken(ctx context.Context, cfg *config.Config, raw string) error {
+	if cfg.Cache.Store != "redis" {
+		return errors.New("revoking tokens needs CACHE_STORE=redis; the memory denylist is private to each server")
+	}
+	claims, err := utils.ValidateRefreshToken(raw, JWTOptions(cfg))
+	if err != nil {
+		return err
+	}
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return errors.New("token has no ID or expiry and can't be revoked")
+	}
+
+	_, denylist, closeCaches, err := openCaches(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeCaches()
+	if err := denylist.Revoke(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return err
+	}
+	fmt.Printf("Token %s revoked until %s.\n", claims.ID, claims.ExpiresAt.Time.Format(time.RFC3339))
+	return nil
+}
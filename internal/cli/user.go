@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository"
+	"github.com/kevinmarcellius/go-simple-auth/internal/service"
+	"github.com/kevinmarcellius/go-simple-auth/internal/tenant"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+func User(ctx context.Context, cfg *config.Config, args []string) error {
+	const usage = "user create -username <name> -email <email> [-password <password>] [-admin] [-org <slug>]\n" +
+		"       user promote [-org <slug>] <email>\n" +
+		"       user reset-password [-password <password>] [-org <slug>] <email>\n" +
+		"       user disable [-org <slug>] <email>\n" +
+		"       user delete [-org <slug>] <email>"
+	if len(args) == 0 {
+		return usageError(usage)
+	}
+
+	db, err := OpenDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer config.CloseDB(db)
+	users, denylist, closeCaches, err := openCaches(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeCaches()
+	userService, closeAudit, err := NewUserService(cfg, db, users, denylist)
+	if err != nil {
+		return err
+	}
+	defer closeAudit()
+	orgs := repository.NewOrganizationRepository(db, cfg.Postgres.QueryTimeout)
+
+	switch args[0] {
+	case "create":
+		return createUser(ctx, userService, orgs.FindBySlug, args[1:])
+	case "reset-password":
+		return resetPassword(ctx, userService, orgs.FindBySlug, args[1:])
+	case "promote", "disable", "delete":
+		fs := newFlagSet("user " + args[0])
+		orgSlug := orgFlag(fs)
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return usageError(usage)
+		}
+		ctx, err := inOrg(ctx, orgs.FindBySlug, *orgSlug)
+		if err != nil {
+			return err
+		}
+		email := fs.Arg(0)
+		switch args[0] {
+		case "promote":
+			if err := userService.PromoteUser(ctx, email); err != nil {
+				return err
+			}
+			fmt.Printf("%s is now an admin.\n", email)
+		case "disable":
+			if err := userService.DisableUser(ctx, email); err != nil {
+				return err
+			}
+			fmt.Printf("%s has been disabled.\n", email)
+		case "delete":
+			if err := userService.DeleteUser(ctx, email); err != nil {
+				return err
+			}
+			fmt.Printf("%s has been deleted.\n", email)
+		}
+		return nil
+	default:
+		return usageError(usage)
+	}
+}
+
+// orgFlag adds the -org flag of the commands acting on an existing user.
+// Users are looked up among all users without it, which fails when
+// TENANCY_UNIQUENESS=tenant and several organizations have registered the
+// email.
+func orgFlag(fs *flag.FlagSet) *string {
+	return fs.String("org", "", "organization the user is a member of; required when several users have the email")
+}
+
+// inOrg scopes ctx to the organization with slug, unless slug is empty.
+func inOrg(ctx context.Context, findOrg tenant.FindFunc, slug string) (context.Context, error) {
+	if slug == "" {
+		return ctx, nil
+	}
+	org, err := findOrg(ctx, slug)
+	if err != nil {
+		return nil, fmt.Errorf("find organization %q: %w", slug, err)
+	}
+	return tenant.WithTenant(ctx, tenant.Tenant{ID: org.ID, Slug: org.Slug}), nil
+}
+
+func createUser(ctx context.Context, userService service.UserService, findOrg tenant.FindFunc, args []string) error {
+	fs := newFlagSet("user create")
+	username := fs.String("username", "", "username of the new user")
+	email := fs.String("email", "", "email of the new user")
+	password := fs.String("password", "", "password; a random one is generated and printed when empty")
+	admin := fs.Bool("admin", false, "grant admin privileges")
+	orgSlug := fs.String("org", model.DefaultOrganizationSlug, "organization the user registers in")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	org, err := findOrg(ctx, *orgSlug)
+	if err != nil {
+		return fmt.Errorf("find organization %q: %w", *orgSlug, err)
+	}
+
+	generated := *password == ""
+	if generated {
+		p, err := utils.RandomToken(18)
+		if err != nil {
+			return err
+		}
+		*password = p
+	}
+
+	req := model.UserRequest{Username: *username, Email: *email, Password: *password}
+	if err := req.ValidateUserRequest(); err != nil {
+		return err
+	}
+	ctx = tenant.WithTenant(ctx, tenant.Tenant{ID: org.ID, Slug: org.Slug})
+	if _, err := userService.CreateUser(ctx, req); err != nil {
+		return err
+	}
+	if *admin {
+		if err := userService.PromoteUser(ctx, *email); err != nil {
+			return fmt.Errorf("user created but promotion failed: %w", err)
+		}
+	}
+
+	fmt.Printf("Created user %s <%s> in %s (admin: %t).\n", *username, *email, org.Slug, *admin)
+	if generated {
+		fmt.Printf("Generated password: %s\n", *password)
+	}
+	return nil
+}
+
+func resetPassword(ctx context.Context, userService service.UserService, findOrg tenant.FindFunc, args []string) error {
+	fs := newFlagSet("user reset-password")
+	password := fs.String("password", "", "new password; a random one is generated and printed when empty")
+	orgSlug := orgFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError("user reset-password [-password <password>] [-org <slug>] <email>")
+	}
+	ctx, err := inOrg(ctx, findOrg, *orgSlug)
+	if err != nil {
+		return err
+	}
+	email := fs.Arg(0)
+
+	generated := *password == ""
+	if generated {
+		p, err := utils.RandomToken(18)
+		if err != nil {
+			return err
+		}
+		*password = p
+	}
+
+	if len(*password) < 6 || len(*password) > 100 {
+		return errors.New("password must be between 6 and 100 characters")
+	}
+	if err := userService.ResetPassword(ctx, email, *password); err != nil {
+		return err
+	}
+
+	fmt.Printf("Password reset for %s.\n", email)
+	if generated {
+		fmt.Printf("Generated password: %s\n", *password)
+	}
+	return nil
+}
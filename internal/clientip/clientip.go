@@ -0,0 +1,42 @@
+// Package clientip decides which address of a request is the client's.
+package clientip
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Extractor returns the IP extractor for Echo. X-Forwarded-For is only
+// believed for hops through trusted, which holds addresses or CIDRs as
+// validated by config; without any, the connection's peer address is used so
+// clients cannot spoof their IP.
+func Extractor(trusted []string) echo.IPExtractor {
+	if len(trusted) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	// only the configured ranges are trusted, not echo's private defaults
+	opts := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, t := range trusted {
+		prefix, err := netip.ParsePrefix(t)
+		if err != nil {
+			addr, err := netip.ParseAddr(t)
+			if err != nil {
+				continue
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		_, ipNet, err := net.ParseCIDR(prefix.Masked().String())
+		if err != nil {
+			continue
+		}
+		opts = append(opts, echo.TrustIPRange(ipNet))
+	}
+	return echo.ExtractIPFromXFFHeader(opts...)
+}
@@ -0,0 +1,57 @@
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractor(t *testing.T) {
+	testCases := []struct {
+		name     string
+		trusted  []string
+		remote   string
+		xff      string
+		expected string
+	}{
+		{
+			name:     "No Trusted Proxies Ignores Header",
+			remote:   "10.0.0.5:1234",
+			xff:      "198.51.100.7",
+			expected: "10.0.0.5",
+		},
+		{
+			name:     "Trusted Proxy",
+			trusted:  []string{"10.0.0.0/8"},
+			remote:   "10.0.0.5:1234",
+			xff:      "198.51.100.7",
+			expected: "198.51.100.7",
+		},
+		{
+			name:     "Spoofed Hop Before Trusted Proxy",
+			trusted:  []string{"10.0.0.5"},
+			remote:   "10.0.0.5:1234",
+			xff:      "1.2.3.4, 198.51.100.7",
+			expected: "198.51.100.7",
+		},
+		{
+			name:     "Untrusted Peer",
+			trusted:  []string{"10.0.0.0/8"},
+			remote:   "192.168.1.9:1234",
+			xff:      "198.51.100.7",
+			expected: "192.168.1.9",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remote
+			req.Header.Set("X-Forwarded-For", tc.xff)
+
+			assert.Equal(t, tc.expected, Extractor(tc.trusted)(req))
+		})
+	}
+}
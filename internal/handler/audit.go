@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/logging"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/service"
+)
+
+type AuditHandler struct {
+	auditService service.AuditService
+	logger       *slog.Logger
+}
+
+func NewAuditHandler(auditService service.AuditService, logger *slog.Logger) *AuditHandler {
+	return &AuditHandler{auditService: auditService, logger: logger}
+}
+
+// ListEvents serves GET /admin/audit-events. All query parameters are
+// optional: action, outcome, actor_id, target_id, ip, since and until
+// (RFC 3339), limit and cursor (next_cursor of the previous page).
+func (h *AuditHandler) ListEvents(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "AuditHandler.ListEvents")
+	defer span.End()
+
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		return c.JSON(400, map[string]string{"error": err.Error()})
+	}
+
+	page, err := h.auditService.ListEvents(ctx, filter, c.QueryParam("cursor"))
+	if errors.Is(err, service.ErrInvalidCursor) {
+		return c.JSON(400, map[string]string{"error": "invalid cursor"})
+	}
+	if err != nil {
+		logging.FromContext(ctx, h.logger).Error("list audit events failed", slog.Any("error", err))
+		return c.JSON(500, map[string]string{"error": "Failed to list audit events"})
+	}
+
+	return c.JSON(200, page)
+}
+
+func parseAuditFilter(c echo.Context) (model.AuditFilter, error) {
+	filter := model.AuditFilter{
+		Action:  c.QueryParam("action"),
+		Outcome: c.QueryParam("outcome"),
+		IP:      c.QueryParam("ip"),
+	}
+
+	var err error
+	if filter.ActorID, err = uuidParam(c, "actor_id"); err != nil {
+		return filter, err
+	}
+	if filter.TargetID, err = uuidParam(c, "target_id"); err != nil {
+		return filter, err
+	}
+	if filter.Since, err = timeParam(c, "since"); err != nil {
+		return filter, err
+	}
+	if filter.Until, err = timeParam(c, "until"); err != nil {
+		return filter, err
+	}
+	if v := c.QueryParam("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > service.MaxAuditPageSize {
+			return filter, errors.New("limit must be between 1 and " + strconv.Itoa(service.MaxAuditPageSize))
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+func uuidParam(c echo.Context, name string) (*uuid.UUID, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(v)
+	if err != nil {
+		return nil, errors.New(name + " must be a UUID")
+	}
+	return &id, nil
+}
+
+func timeParam(c echo.Context, name string) (*time.Time, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, errors.New(name + " must be an RFC 3339 timestamp")
+	}
+	return &t, nil
+}
@@ -1,33 +1,39 @@
 package handler
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 
 	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/lifecycle"
 )
 
 type HealthHandler struct {
-	DB *gorm.DB
+	DB        *gorm.DB
+	Lifecycle *lifecycle.Manager
 }
 
-func NewHealthHandler(db *gorm.DB) *HealthHandler {
-	return &HealthHandler{DB: db}
+func NewHealthHandler(db *gorm.DB, lc *lifecycle.Manager) *HealthHandler {
+	return &HealthHandler{DB: db, Lifecycle: lc}
 }
 
 func (h *HealthHandler) ReadinessCheck(c echo.Context) error {
-	log.Println("Performing readiness check")
+	slog.DebugContext(c.Request().Context(), "performing readiness check")
+	if !h.Lifecycle.Ready() {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"status": "shutting down"})
+	}
 	err := config.DBHealthCheck(h.DB)
 	if err != nil {
+		slog.ErrorContext(c.Request().Context(), "readiness check failed", slog.Any("error", err))
 		return c.JSON(http.StatusInternalServerError, map[string]string{"status": "unhealthy"})
 	}
 	return c.JSON(http.StatusOK, map[string]string{"status": "healthy"})
 }
 
 func (h *HealthHandler) LivenessCheck(c echo.Context) error {
-	log.Println("Performing liveness check")
+	slog.DebugContext(c.Request().Context(), "performing liveness check")
 	return c.JSON(http.StatusOK, map[string]string{"status": "alive"})
 }
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"log/slog"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/logging"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/server"
+	"github.com/kevinmarcellius/go-simple-auth/internal/service"
+)
+
+// IntrospectionHandler serves the internal endpoints other services call
+// over mTLS.
+type IntrospectionHandler struct {
+	userService service.UserService
+	logger      *slog.Logger
+}
+
+func NewIntrospectionHandler(userService service.UserService, logger *slog.Logger) *IntrospectionHandler {
+	return &IntrospectionHandler{userService: userService, logger: logger}
+}
+
+// Introspect serves POST /internal/v1/introspect, answering whether a token
+// is active as in RFC 7662.
+func (h *IntrospectionHandler) Introspect(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "IntrospectionHandler.Introspect")
+	defer span.End()
+
+	var req model.IntrospectionRequest
+	if err := c.Bind(&req); err != nil || req.Token == "" {
+		return c.JSON(400, map[string]string{"error": "Invalid request"})
+	}
+
+	res, err := h.userService.Introspect(ctx, req.Token)
+	if err != nil {
+		logging.FromContext(ctx, h.logger).Error("introspection failed", slog.String("client", server.Client(c)), slog.Any("error", err))
+		return c.JSON(500, map[string]string{"error": "Failed to introspect token"})
+	}
+	return c.JSON(200, res)
+}
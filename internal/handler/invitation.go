@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/logging"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/service"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+// InvitationHandler manages the invitations of the organization of the
+// request, and accepts them.
+type InvitationHandler struct {
+	userService service.UserService
+	logger      *slog.Logger
+}
+
+func NewInvitationHandler(userService service.UserService, logger *slog.Logger) *InvitationHandler {
+	return &InvitationHandler{userService: userService, logger: logger}
+}
+
+func (h *InvitationHandler) CreateInvitation(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "InvitationHandler.CreateInvitation")
+	defer span.End()
+
+	var req model.InvitationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(400, map[string]string{"error": "Invalid request"})
+	}
+
+	res, err := h.userService.CreateInvitation(ctx, req)
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		return c.JSON(403, map[string]string{"error": "Forbidden"})
+	case errors.Is(err, service.ErrInvalidOrgRole):
+		return c.JSON(400, map[string]string{"error": err.Error()})
+	case errors.Is(err, utils.ErrInvalidEmail):
+		return c.JSON(400, map[string]string{"error": "invalid email format"})
+	case err != nil:
+		logging.FromContext(ctx, h.logger).Error("create invitation failed", slog.Any("error", err))
+		return c.JSON(500, map[string]string{"error": "Failed to create invitation"})
+	}
+
+	return c.JSON(201, res)
+}
+
+func (h *InvitationHandler) ListInvitations(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "InvitationHandler.ListInvitations")
+	defer span.End()
+
+	invs, err := h.userService.ListInvitations(ctx)
+	if errors.Is(err, service.ErrForbidden) {
+		return c.JSON(403, map[string]string{"error": "Forbidden"})
+	}
+	if err != nil {
+		logging.FromContext(ctx, h.logger).Error("list invitations failed", slog.Any("error", err))
+		return c.JSON(500, map[string]string{"error": "Failed to list invitations"})
+	}
+
+	return c.JSON(200, map[string]any{"invitations": invs})
+}
+
+func (h *InvitationHandler) ResendInvitation(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "InvitationHandler.ResendInvitation")
+	defer span.End()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(404, map[string]string{"error": "Invitation not found"})
+	}
+
+	res, err := h.userService.ResendInvitation(ctx, id)
+	if code, msg, ok := invitationError(err); ok {
+		return c.JSON(code, map[string]string{"error": msg})
+	}
+	if err != nil {
+		logging.FromContext(ctx, h.logger).Error("resend invitation failed", slog.Any("error", err))
+		return c.JSON(500, map[string]string{"error": "Failed to resend invitation"})
+	}
+
+	return c.JSON(200, res)
+}
+
+func (h *InvitationHandler) RevokeInvitation(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "InvitationHandler.RevokeInvitation")
+	defer span.End()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(404, map[string]string{"error": "Invitation not found"})
+	}
+
+	err = h.userService.RevokeInvitation(ctx, id)
+	if code, msg, ok := invitationError(err); ok {
+		return c.JSON(code, map[string]string{"error": msg})
+	}
+	if err != nil {
+		logging.FromContext(ctx, h.logger).Error("revoke invitation failed", slog.Any("error", err))
+		return c.JSON(500, map[string]string{"error": "Failed to revoke invitation"})
+	}
+
+	return c.NoContent(204)
+}
+
+// AcceptInvitation is unauthenticated: the invitation token is the
+// credential.
+func (h *InvitationHandler) AcceptInvitation(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "InvitationHandler.AcceptInvitation")
+	defer span.End()
+
+	var req model.AcceptInvitationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(400, map[string]string{"error": "Invalid request"})
+	}
+	if req.Token == "" {
+		return c.JSON(400, map[string]string{"error": "token is required"})
+	}
+	if len(req.Password) < 6 || len(req.Password) > 100 {
+		return c.JSON(400, map[string]string{"error": "password must be between 6 and 100 characters"})
+	}
+	if req.Username != "" && (len(req.Username) < 3 || len(req.Username) > 50) {
+		return c.JSON(400, map[string]string{"error": "username must be between 3 and 50 characters"})
+	}
+
+	res, err := h.userService.AcceptInvitation(ctx, req)
+	switch {
+	case errors.Is(err, service.ErrInvalidInvitation):
+		return c.JSON(400, map[string]string{"error": "Invalid or expired invitation"})
+	case errors.Is(err, service.ErrUsernameRequired):
+		return c.JSON(400, map[string]string{"error": err.Error()})
+	case errors.Is(err, utils.ErrInvalidPassword):
+		return c.JSON(401, map[string]string{"error": "Invalid credentials"})
+	case errors.Is(err, service.ErrAlreadyMember):
+		return c.JSON(409, map[string]string{"error": "Already a member"})
+	case errors.Is(err, service.ErrRegistrationClosed):
+		return c.JSON(403, map[string]string{"error": "Registration is closed"})
+	case err != nil:
+		logging.FromContext(ctx, h.logger).Error("accept invitation failed", slog.Any("error", err))
+		return c.JSON(500, map[string]string{"error": "Failed to accept invitation"})
+	}
+
+	return c.JSON(201, res)
+}
+
+// invitationError maps the errors of managing an existing invitation to a
+// response.
+func invitationError(err error) (int, string, bool) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		return 403, "Forbidden", true
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return 404, "Invitation not found", true
+	case errors.Is(err, service.ErrInvitationNotPending):
+		return 409, "Invitation is no longer pending", true
+	}
+	return 0, "", false
+}
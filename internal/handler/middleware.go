@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/auth"
+)
+
+// RequireAdmin rejects requests whose access token is not an admin's. It
+// must run after auth.Middleware.
+func RequireAdmin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		p, ok := auth.FromEcho(c)
+		if !ok {
+			return c.JSON(401, map[string]string{"error": "Unauthorized"})
+		}
+		if !p.IsAdmin {
+			return c.JSON(403, map[string]string{"error": "Forbidden"})
+		}
+		return next(c)
+	}
+}
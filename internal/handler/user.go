@@ -1,22 +1,35 @@
 package handler
 
 import (
-	"github.com/golang-jwt/jwt/v5"
+	"errors"
+	"log/slog"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/auth"
+	"github.com/kevinmarcellius/go-simple-auth/internal/logging"
 	"github.com/kevinmarcellius/go-simple-auth/internal/model"
 	"github.com/kevinmarcellius/go-simple-auth/internal/service"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
 	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
 )
 
+var tracer = otel.Tracer("github.com/kevinmarcellius/go-simple-auth/internal/handler")
+
 type UserHandler struct {
 	userService service.UserService
+	// cookies is nil unless cookie sessions are enabled.
+	cookies *auth.Cookies
+	logger  *slog.Logger
 }
 
-func NewUserHandler(userService service.UserService) *UserHandler {
-	return &UserHandler{userService: userService}
+func NewUserHandler(userService service.UserService, cookies *auth.Cookies, logger *slog.Logger) *UserHandler {
+	return &UserHandler{userService: userService, cookies: cookies, logger: logger}
 }
 
 func (h *UserHandler) CreateUser(c echo.Context) error {
-	ctx := c.Request().Context()
+	ctx, span := tracer.Start(c.Request().Context(), "UserHandler.CreateUser")
+	defer span.End()
+
 	// process payload and call userService.CreateUser
 	var req model.UserRequest
 	if err := c.Bind(&req); err != nil {
@@ -29,7 +42,23 @@ func (h *UserHandler) CreateUser(c echo.Context) error {
 	}
 
 	res, err := h.userService.CreateUser(ctx, req)
+	if errors.Is(err, service.ErrRegistrationClosed) {
+		return c.JSON(403, map[string]string{"error": res.Message})
+	}
+	if errors.Is(err, service.ErrCaptchaRequired) {
+		return c.JSON(400, map[string]string{"error": "Captcha verification failed"})
+	}
+	if errors.Is(err, utils.ErrInvalidEmail) {
+		return c.JSON(400, map[string]string{"error": "invalid email format"})
+	}
+	if errors.Is(err, utils.ErrEmailDomainNotAllowed) {
+		return c.JSON(400, map[string]string{"error": "email domain is not allowed"})
+	}
+	if errors.Is(err, service.ErrEmailInOrganization) {
+		return c.JSON(409, map[string]string{"error": "Email is already registered"})
+	}
 	if err != nil {
+		logging.FromContext(ctx, h.logger).Error("create user failed", slog.Any("error", err))
 		return c.JSON(500, map[string]string{"error": "Failed to create user"})
 	}
 
@@ -38,40 +67,184 @@ func (h *UserHandler) CreateUser(c echo.Context) error {
 }
 
 func (h *UserHandler) Login(c echo.Context) error {
-	ctx := c.Request().Context()
+	ctx, span := tracer.Start(c.Request().Context(), "UserHandler.Login")
+	defer span.End()
+
 	var req model.LoginRequest
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(400, map[string]string{"error": "Invalid request"})
 	}
+	if req.UseCookies && h.cookies == nil {
+		return c.JSON(400, map[string]string{"error": "Cookie sessions are disabled"})
+	}
 
 	res, err := h.userService.Login(ctx, req)
+	if errors.Is(err, service.ErrUnknownClient) {
+		return c.JSON(400, map[string]string{"error": "Unknown client"})
+	}
+	// 428 tells clients to show a captcha and retry with its token
+	if errors.Is(err, service.ErrCaptchaRequired) {
+		return c.JSON(428, map[string]string{"error": "Captcha required"})
+	}
 	if err != nil {
 		return c.JSON(401, map[string]string{"error": "Invalid credentials"})
 	}
 
+	if req.UseCookies {
+		return h.cookieSession(c, res.AccessToken, res.RefreshToken)
+	}
+	return c.JSON(200, res)
+}
+
+// RequestMagicLink answers the same whether or not the email has an
+// account, so it can't be used to find out.
+func (h *UserHandler) RequestMagicLink(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "UserHandler.RequestMagicLink")
+	defer span.End()
+
+	var req model.MagicLinkRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(400, map[string]string{"error": "Invalid request"})
+	}
+
+	err := h.userService.RequestMagicLink(ctx, req)
+	if errors.Is(err, service.ErrUnknownClient) {
+		return c.JSON(400, map[string]string{"error": "Unknown client"})
+	}
+	if errors.Is(err, utils.ErrInvalidEmail) {
+		return c.JSON(400, map[string]string{"error": "invalid email format"})
+	}
+	if err != nil {
+		logging.FromContext(ctx, h.logger).Error("request magic link failed", slog.Any("error", err))
+		return c.JSON(500, map[string]string{"error": "Failed to send login link"})
+	}
+
+	return c.JSON(202, map[string]string{"message": "If the email has an account, a login link has been sent"})
+}
+
+func (h *UserHandler) VerifyMagicLink(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "UserHandler.VerifyMagicLink")
+	defer span.End()
+
+	var req model.VerifyMagicLinkRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(400, map[string]string{"error": "Invalid request"})
+	}
+	if req.Token == "" {
+		return c.JSON(400, map[string]string{"error": "token is required"})
+	}
+	if req.UseCookies && h.cookies == nil {
+		return c.JSON(400, map[string]string{"error": "Cookie sessions are disabled"})
+	}
+
+	res, err := h.userService.VerifyMagicLink(ctx, req)
+	if errors.Is(err, service.ErrInvalidMagicLink) || errors.Is(err, utils.ErrUserDisabled) {
+		return c.JSON(401, map[string]string{"error": "Invalid or expired login link"})
+	}
+	if err != nil {
+		logging.FromContext(ctx, h.logger).Error("verify magic link failed", slog.Any("error", err))
+		return c.JSON(500, map[string]string{"error": "Failed to log in"})
+	}
+
+	if req.UseCookies {
+		return h.cookieSession(c, res.AccessToken, res.RefreshToken)
+	}
 	return c.JSON(200, res)
 }
 
 func (h *UserHandler) Refresh(c echo.Context) error {
-	ctx := c.Request().Context()
+	ctx, span := tracer.Start(c.Request().Context(), "UserHandler.Refresh")
+	defer span.End()
+
 	var req model.RefreshTokenRequest
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(400, map[string]string{"error": "Invalid request"})
 	}
+	fromCookie, code, msg := h.refreshToken(c, &req)
+	if code != 0 {
+		return c.JSON(code, map[string]string{"error": msg})
+	}
 
 	res, err := h.userService.Refresh(ctx, req)
 	if err != nil {
 		return c.JSON(401, map[string]string{"error": "Invalid refresh token"})
 	}
 
+	if fromCookie {
+		return h.cookieSession(c, res.AccessToken, res.RefreshToken)
+	}
 	return c.JSON(200, res)
 }
 
+// Logout revokes the refresh token sent in the body or, in cookie sessions,
+// in its cookie, and clears the session cookies.
+func (h *UserHandler) Logout(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "UserHandler.Logout")
+	defer span.End()
+
+	var req model.RefreshTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(400, map[string]string{"error": "Invalid request"})
+	}
+	fromCookie, code, msg := h.refreshToken(c, &req)
+	if code != 0 {
+		return c.JSON(code, map[string]string{"error": msg})
+	}
+
+	err := h.userService.Logout(ctx, req)
+	if errors.Is(err, utils.ErrInvalidToken) {
+		return c.JSON(401, map[string]string{"error": "Invalid refresh token"})
+	}
+	if err != nil {
+		logging.FromContext(ctx, h.logger).Error("logout failed", slog.Any("error", err))
+		return c.JSON(500, map[string]string{"error": "Failed to log out"})
+	}
+
+	if fromCookie {
+		h.cookies.Clear(c)
+	}
+	return c.NoContent(204)
+}
+
+// refreshToken fills in the refresh token from its cookie when the body has
+// none and cookie sessions are enabled, checking the CSRF token in that case.
+// It reports whether the cookie was used, or the status and error message to
+// respond with.
+func (h *UserHandler) refreshToken(c echo.Context, req *model.RefreshTokenRequest) (bool, int, string) {
+	if req.RefreshToken != "" {
+		return false, 0, ""
+	}
+	if h.cookies != nil {
+		if token, ok := h.cookies.RefreshToken(c); ok {
+			if !h.cookies.CheckCSRF(c, auth.SessionID(token)) {
+				return false, 403, "Missing or invalid CSRF token"
+			}
+			req.RefreshToken = token
+			return true, 0, ""
+		}
+	}
+	return false, 400, "Missing refresh token"
+}
+
+// cookieSession stores the tokens in cookies and responds with the session's
+// CSRF token.
+func (h *UserHandler) cookieSession(c echo.Context, accessToken, refreshToken string) error {
+	csrfToken, err := h.cookies.SetSession(c, accessToken, refreshToken)
+	if err != nil {
+		logging.FromContext(c.Request().Context(), h.logger).Error("set session cookies failed", slog.Any("error", err))
+		return c.JSON(500, map[string]string{"error": "Failed to start session"})
+	}
+	return c.JSON(200, model.CookieSessionResponse{CSRFToken: csrfToken})
+}
+
 func (h *UserHandler) UpdatePassword(c echo.Context) error {
-	ctx := c.Request().Context()
-	user := c.Get("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	ctx, span := tracer.Start(c.Request().Context(), "UserHandler.UpdatePassword")
+	defer span.End()
+
+	p, ok := auth.FromEcho(c)
+	if !ok {
+		return c.JSON(401, map[string]string{"error": "Unauthorized"})
+	}
 
 	var req model.UpdatePasswordRequest
 	if err := c.Bind(&req); err != nil {
@@ -79,8 +252,9 @@ func (h *UserHandler) UpdatePassword(c echo.Context) error {
 	}
 
 	// Implement password update logic here
-	err := h.userService.UpdatePassword(ctx, userID, req)
+	err := h.userService.UpdatePassword(ctx, p.UserID.String(), req)
 	if err != nil {
+		logging.FromContext(ctx, h.logger).Error("update password failed", slog.Any("error", err))
 		return c.JSON(500, map[string]string{"error": err.Error()})
 	}
 
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/logging"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/service"
+)
+
+type WebhookHandler struct {
+	webhookService service.WebhookService
+	logger         *slog.Logger
+}
+
+func NewWebhookHandler(webhookService service.WebhookService, logger *slog.Logger) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService, logger: logger}
+}
+
+func (h *WebhookHandler) CreateSubscription(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "WebhookHandler.CreateSubscription")
+	defer span.End()
+
+	var req model.CreateWebhookRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(400, map[string]string{"error": "Invalid request"})
+	}
+
+	res, err := h.webhookService.CreateSubscription(ctx, req)
+	if errors.Is(err, service.ErrInvalidWebhook) {
+		return c.JSON(400, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		logging.FromContext(ctx, h.logger).Error("create webhook failed", slog.Any("error", err))
+		return c.JSON(500, map[string]string{"error": "Failed to create webhook"})
+	}
+
+	return c.JSON(201, res)
+}
+
+func (h *WebhookHandler) ListSubscriptions(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "WebhookHandler.ListSubscriptions")
+	defer span.End()
+
+	subs, err := h.webhookService.ListSubscriptions(ctx)
+	if err != nil {
+		logging.FromContext(ctx, h.logger).Error("list webhooks failed", slog.Any("error", err))
+		return c.JSON(500, map[string]string{"error": "Failed to list webhooks"})
+	}
+
+	return c.JSON(200, map[string]any{"webhooks": subs})
+}
+
+func (h *WebhookHandler) DeleteSubscription(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "WebhookHandler.DeleteSubscription")
+	defer span.End()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(404, map[string]string{"error": "Webhook not found"})
+	}
+
+	err = h.webhookService.DeleteSubscription(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(404, map[string]string{"error": "Webhook not found"})
+	}
+	if err != nil {
+		logging.FromContext(ctx, h.logger).Error("delete webhook failed", slog.Any("error", err))
+		return c.JSON(500, map[string]string{"error": "Failed to delete webhook"})
+	}
+
+	return c.NoContent(204)
+}
+
+func (h *WebhookHandler) ListDeliveries(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "WebhookHandler.ListDeliveries")
+	defer span.End()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(404, map[string]string{"error": "Webhook not found"})
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(ctx, id)
+	if err != nil {
+		logging.FromContext(ctx, h.logger).Error("list webhook deliveries failed", slog.Any("error", err))
+		return c.JSON(500, map[string]string{"error": "Failed to list deliveries"})
+	}
+
+	return c.JSON(200, map[string]any{"deliveries": deliveries})
+}
@@ -0,0 +1,135 @@
+// Package lifecycle coordinates process startup and graceful shutdown.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+type hook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Manager tracks readiness and the resources to release on shutdown.
+// Shutdown hooks run in reverse registration order, like defer, so resources
+// registered as they are created are torn down dependants-first: the HTTP
+// server drains before workers stop, and workers stop before the DB closes.
+type Manager struct {
+	timeout time.Duration
+	delay   time.Duration
+
+	ready atomic.Bool
+
+	mu    sync.Mutex
+	hooks []hook
+}
+
+// New returns a Manager that gives shutdown hooks timeout to finish in total,
+// after waiting delay with readiness off so load balancers stop routing here.
+func New(timeout, delay time.Duration) *Manager {
+	return &Manager{timeout: timeout, delay: delay}
+}
+
+func (m *Manager) Ready() bool {
+	return m.ready.Load()
+}
+
+func (m *Manager) SetReady(ready bool) {
+	m.ready.Store(ready)
+}
+
+// OnShutdown registers fn to run during shutdown.
+func (m *Manager) OnShutdown(name string, fn func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, hook{name: name, fn: fn})
+}
+
+// Go starts a background worker. Its context is cancelled at shutdown and
+// shutdown waits for fn to return.
+func (m *Manager) Go(name string, fn func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(ctx)
+	}()
+
+	m.OnShutdown(name, func(shutdownCtx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-shutdownCtx.Done():
+			return shutdownCtx.Err()
+		}
+	})
+}
+
+// Run marks the process ready, calls serve, and blocks until ctx is done,
+// SIGINT/SIGTERM is received or serve fails. It then shuts down and returns
+// serve's error, if any, joined with shutdown errors.
+func (m *Manager) Run(ctx context.Context, serve func() error) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- serve()
+	}()
+	m.SetReady(true)
+
+	var err error
+	select {
+	case <-ctx.Done():
+		slog.Info("shutdown signal received")
+	case err = <-serveErr:
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+		if err != nil {
+			slog.Error("server stopped", slog.Any("error", err))
+		}
+	}
+
+	return errors.Join(err, m.Shutdown())
+}
+
+// Shutdown turns readiness off and runs the shutdown hooks within the
+// configured timeout.
+func (m *Manager) Shutdown() error {
+	m.SetReady(false)
+	if m.delay > 0 {
+		slog.Info("not ready, waiting before draining", slog.Duration("delay", m.delay))
+		time.Sleep(m.delay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	m.mu.Lock()
+	hooks := m.hooks
+	m.hooks = nil
+	m.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		slog.Info("shutting down", slog.String("component", h.name))
+		if err := h.fn(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown %s: %w", h.name, err))
+		}
+	}
+	slog.Info("shutdown complete")
+	return errors.Join(errs...)
+}
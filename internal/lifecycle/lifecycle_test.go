@@ -0,0 +1,70 @@
+package lifecycle
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_Run(t *testing.T) {
+	m := New(time.Second, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var order []string
+	m.OnShutdown("database", func(ctx context.Context) error {
+		order = append(order, "database")
+		return nil
+	})
+	m.Go("worker", func(ctx context.Context) {
+		<-ctx.Done()
+		order = append(order, "worker")
+	})
+
+	stopped := make(chan struct{})
+	m.OnShutdown("http", func(ctx context.Context) error {
+		assert.False(t, m.Ready())
+		order = append(order, "http")
+		close(stopped)
+		return nil
+	})
+
+	err := m.Run(ctx, func() error {
+		assert.Eventually(t, m.Ready, time.Second, time.Millisecond)
+		cancel()
+		<-stopped
+		return http.ErrServerClosed
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http", "worker", "database"}, order)
+}
+
+func TestManager_RunServeError(t *testing.T) {
+	m := New(time.Second, 0)
+	closed := false
+	m.OnShutdown("database", func(ctx context.Context) error {
+		closed = true
+		return nil
+	})
+
+	err := m.Run(context.Background(), func() error {
+		return assert.AnError
+	})
+
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.True(t, closed)
+}
+
+func TestManager_ShutdownTimeout(t *testing.T) {
+	m := New(10*time.Millisecond, 0)
+	m.Go("stuck", func(ctx context.Context) {
+		time.Sleep(time.Second)
+	})
+
+	err := m.Shutdown()
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
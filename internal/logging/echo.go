@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/tracing"
+)
+
+// Middleware derives a per-request logger from logger, tagged with the
+// request ID set by Echo's RequestID middleware and the trace ID, stores it
+// in the request context and writes one access log line per request.
+func Middleware(logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			req := c.Request()
+
+			reqLogger := logger.With(slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)))
+			if traceID := tracing.TraceID(req.Context()); traceID != "" {
+				reqLogger = reqLogger.With(slog.String("trace_id", traceID))
+			}
+			c.SetRequest(req.WithContext(WithLogger(req.Context(), reqLogger)))
+
+			err := next(c)
+
+			status := c.Response().Status
+			if err != nil {
+				var httpErr *echo.HTTPError
+				if errors.As(err, &httpErr) {
+					status = httpErr.Code
+				} else if !c.Response().Committed {
+					status = http.StatusInternalServerError
+				}
+			}
+
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			attrs := []slog.Attr{
+				slog.String("method", req.Method),
+				slog.String("route", c.Path()),
+				slog.String("path", req.URL.Path),
+				slog.Int("status", status),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("remote_ip", c.RealIP()),
+				slog.Int64("bytes_out", c.Response().Size),
+			}
+			if err != nil {
+				attrs = append(attrs, slog.Any("error", err))
+			}
+			reqLogger.LogAttrs(req.Context(), level, "request", attrs...)
+			return err
+		}
+	}
+}
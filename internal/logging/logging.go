@@ -0,0 +1,41 @@
+// Package logging builds the service's slog logger, carries request-scoped
+// loggers through contexts and redacts personal data and credentials.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+)
+
+// New returns a logger writing to w in the configured format and level.
+// Every record passes through Redact.
+func New(w io.Writer, cfg config.LogConfig) *slog.Logger {
+	var level slog.Level
+	// config.Validate has already rejected unknown levels
+	_ = level.UnmarshalText([]byte(cfg.Level))
+
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: Redact}
+	if cfg.Format == "text" {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the request-scoped logger stored in ctx, or fallback
+// when there is none, e.g. outside an HTTP request.
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return fallback
+}
@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// secretKeys are attribute keys whose values are dropped entirely.
+var secretKeys = map[string]bool{
+	"password":      true,
+	"old_password":  true,
+	"new_password":  true,
+	"token":         true,
+	"access_token":  true,
+	"refresh_token": true,
+	"authorization": true,
+	"cookie":        true,
+	"secret":        true,
+	"jwt_secret":    true,
+}
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	jwtPattern   = regexp.MustCompile(`eyJ[A-Za-z0-9_\-]*\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`)
+)
+
+// Redact is a slog ReplaceAttr function. Credentials are replaced with
+// [REDACTED] by key, and emails and JWTs are masked wherever they appear in
+// a string value, including the message. Emails are hashed rather than
+// dropped so log lines about the same account can still be correlated.
+func Redact(_ []string, a slog.Attr) slog.Attr {
+	if secretKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Scrub(a.Value.String()))
+	case slog.KindAny:
+		// errors often wrap the offending input, e.g. a rejected email
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, Scrub(err.Error()))
+		}
+	}
+	return a
+}
+
+// Scrub masks emails and JWTs in s.
+func Scrub(s string) string {
+	if strings.Contains(s, "@") {
+		s = emailPattern.ReplaceAllStringFunc(s, HashEmail)
+	}
+	if strings.Contains(s, "eyJ") {
+		s = jwtPattern.ReplaceAllString(s, redacted)
+	}
+	return s
+}
+
+// HashEmail returns a short, stable fingerprint of email that identifies an
+// account across log lines without revealing the address.
+func HashEmail(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(email)))
+	return "email:" + hex.EncodeToString(sum[:6])
+}
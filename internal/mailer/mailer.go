@@ -0,0 +1,132 @@
+// Package mailer emails users the credentials, such as login links, that
+// must reach them and nobody else.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+)
+
+// MagicLink is a login link for the user with email To.
+type MagicLink struct {
+	To        string
+	Username  string
+	Link      string
+	ExpiresAt time.Time
+}
+
+// Sender delivers login links.
+type Sender interface {
+	// SendMagicLink returns once the link has been handed to the mail
+	// server.
+	SendMagicLink(ctx context.Context, msg MagicLink) error
+}
+
+// SMTP sends emails through an SMTP server.
+type SMTP struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     *mail.Address
+	timeout  time.Duration
+}
+
+// NewSMTP returns a sender for cfg, which must have been validated.
+func NewSMTP(cfg config.SMTPConfig) (*SMTP, error) {
+	host, _, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("parse SMTP_ADDR: %w", err)
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("parse SMTP_FROM: %w", err)
+	}
+	return &SMTP{
+		addr:     cfg.Addr,
+		host:     host,
+		username: cfg.Username,
+		password: cfg.Password,
+		from:     from,
+		timeout:  cfg.Timeout,
+	}, nil
+}
+
+func (m *SMTP) SendMagicLink(ctx context.Context, msg MagicLink) error {
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "Hi %s,\r\n\r\n", msg.Username)
+	fmt.Fprintf(&body, "Open this link to log in. It works once, until %s.\r\n\r\n", msg.ExpiresAt.UTC().Format(time.RFC1123))
+	fmt.Fprintf(&body, "%s\r\n\r\n", msg.Link)
+	body.WriteString("If you didn't ask to log in, you can ignore this email.\r\n")
+	return m.send(ctx, msg.To, "Your login link", body.Bytes())
+}
+
+func (m *SMTP) send(ctx context.Context, to, subject string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return fmt.Errorf("dial SMTP server: %w", err)
+	}
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("greet SMTP server: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return fmt.Errorf("start TLS: %w", err)
+		}
+	}
+	if m.username != "" {
+		// PlainAuth refuses to send the password unencrypted except to
+		// localhost
+		if err := c.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return fmt.Errorf("authenticate: %w", err)
+		}
+	}
+	if err := c.Mail(m.from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	var header bytes.Buffer
+	fmt.Fprintf(&header, "From: %s\r\n", m.from)
+	fmt.Fprintf(&header, "To: %s\r\n", (&mail.Address{Address: to}).String())
+	fmt.Fprintf(&header, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&header, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	header.WriteString("MIME-Version: 1.0\r\n")
+	header.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
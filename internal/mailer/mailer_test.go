@@ -0,0 +1,106 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+)
+
+// fakeSMTP accepts one email over plain SMTP and returns its envelope and
+// data.
+func fakeSMTP(t *testing.T) (addr string, received <-chan []string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	out := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		var lines []string
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO", "HELO":
+				reply("250 localhost")
+			case "MAIL", "RCPT":
+				lines = append(lines, line)
+				reply("250 OK")
+			case "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					line = strings.TrimRight(line, "\r\n")
+					if line == "." {
+						break
+					}
+					lines = append(lines, line)
+				}
+				reply("250 OK")
+			case "QUIT":
+				out <- lines
+				reply("221 bye")
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+	return ln.Addr().String(), out
+}
+
+func TestSMTP_SendMagicLink(t *testing.T) {
+	addr, received := fakeSMTP(t)
+	sender, err := NewSMTP(config.SMTPConfig{Addr: addr, From: "Example <login@example.com>", Timeout: 5 * time.Second})
+	assert.NoError(t, err)
+
+	err = sender.SendMagicLink(context.Background(), MagicLink{
+		To:        "bob@example.com",
+		Username:  "bob",
+		Link:      "https://app.example.com/magic?token=secret",
+		ExpiresAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+	assert.NoError(t, err)
+
+	// the server hands over the email before answering QUIT
+	lines := <-received
+	assert.Contains(t, lines, "MAIL FROM:<login@example.com>")
+	assert.Contains(t, lines, "RCPT TO:<bob@example.com>")
+	assert.Contains(t, lines, `From: "Example" <login@example.com>`)
+	assert.Contains(t, lines, "To: <bob@example.com>")
+	assert.Contains(t, lines, "Subject: Your login link")
+	assert.Contains(t, lines, "https://app.example.com/magic?token=secret")
+}
+
+func TestSMTP_SendMagicLink_Unreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	sender, err := NewSMTP(config.SMTPConfig{Addr: addr, From: "login@example.com", Timeout: time.Second})
+	assert.NoError(t, err)
+
+	err = sender.SendMagicLink(context.Background(), MagicLink{To: "bob@example.com", Link: "https://app.example.com/magic?token=secret"})
+	assert.ErrorContains(t, err, "dial SMTP server")
+}
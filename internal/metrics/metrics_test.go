@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func requestCount(t *testing.T, method, route, status string) uint64 {
+	t.Helper()
+	var m dto.Metric
+	err := HTTPRequestDuration.WithLabelValues(method, route, status).(prometheus.Metric).Write(&m)
+	assert.NoError(t, err)
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestMiddleware(t *testing.T) {
+	e := echo.New()
+	e.Use(Middleware())
+	e.GET("/user/:id", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	})
+	e.GET("/fail", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusTeapot)
+	})
+
+	for _, path := range []string{"/user/1", "/user/2", "/fail", "/missing"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, uint64(2), requestCount(t, "GET", "/user/:id", "204"))
+	assert.Equal(t, uint64(1), requestCount(t, "GET", "/fail", "418"))
+	assert.Equal(t, uint64(1), requestCount(t, "GET", "unmatched", "404"))
+}
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audit actions
+const (
+	AuditUserCreate     = "user.create"
+	AuditLogin          = "user.login"
+	AuditTokenRefresh   = "token.refresh"
+	AuditLogout         = "user.logout"
+	AuditPasswordChange = "user.password_change"
+	AuditPasswordReset  = "user.password_reset"
+	AuditUserPromote    = "user.promote"
+	AuditUserDisable    = "user.disable"
+	AuditUserDelete     = "user.delete"
+	AuditOrgCreate      = "org.create"
+	AuditMemberAdd      = "org.member_add"
+	AuditMemberRemove   = "org.member_remove"
+
+	AuditInvitationCreate = "invitation.create"
+	AuditInvitationResend = "invitation.resend"
+	AuditInvitationRevoke = "invitation.revoke"
+	AuditInvitationAccept = "invitation.accept"
+
+	AuditMagicLinkRequest = "user.magic_link_request"
+	AuditMagicLinkLogin   = "user.magic_link_login"
+)
+
+// Audit outcomes
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEvent records a security-relevant action. ActorID is who performed it,
+// empty for operator commands run from the CLI, and TargetID the account it
+// applied to. TargetEmail keeps the address that was tried when no account
+// matched, e.g. a failed login for an unknown email.
+type AuditEvent struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	OccurredAt  time.Time  `gorm:"not null" json:"occurred_at"`
+	Action      string     `gorm:"type:varchar(64);not null" json:"action"`
+	Outcome     string     `gorm:"type:varchar(16);not null" json:"outcome"`
+	Reason      string     `gorm:"type:varchar(64)" json:"reason,omitempty"`
+	ActorID     *uuid.UUID `gorm:"type:uuid" json:"actor_id,omitempty"`
+	TargetID    *uuid.UUID `gorm:"type:uuid" json:"target_id,omitempty"`
+	TargetEmail string     `gorm:"type:varchar(255)" json:"target_email,omitempty"`
+	IP          string     `gorm:"type:varchar(64)" json:"ip,omitempty"`
+	UserAgent   string     `json:"user_agent,omitempty"`
+	RequestID   string     `gorm:"type:varchar(128)" json:"request_id,omitempty"`
+}
+
+func (AuditEvent) TableName() string {
+	return "audit_event"
+}
+
+// AuditCursor is the position of the last event of a page; the next page
+// starts with the events just older than it.
+type AuditCursor struct {
+	OccurredAt time.Time
+	ID         uuid.UUID
+}
+
+// AuditFilter selects audit events. Zero fields match everything; Since is
+// inclusive and Until exclusive.
+type AuditFilter struct {
+	Action   string
+	Outcome  string
+	ActorID  *uuid.UUID
+	TargetID *uuid.UUID
+	IP       string
+	Since    *time.Time
+	Until    *time.Time
+	After    *AuditCursor
+	Limit    int
+}
+
+type AuditPage struct {
+	Events     []AuditEvent `json:"events"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
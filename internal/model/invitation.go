@@ -0,0 +1,82 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Invitation statuses, derived from an invitation's timestamps
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationRevoked  = "revoked"
+	InvitationExpired  = "expired"
+)
+
+// Invitation invites Email to join an organization with Role and, if
+// IsAdmin, as an administrator of the service. It is accepted with a signed
+// token whose jti is TokenID; resending it issues a new TokenID, which
+// invalidates earlier tokens.
+type Invitation struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	OrgID      uuid.UUID  `gorm:"type:uuid;not null" json:"org_id"`
+	Email      string     `gorm:"type:varchar(255);not null" json:"email"`
+	Role       string     `gorm:"type:varchar(16);not null" json:"role"`
+	IsAdmin    bool       `gorm:"not null;default:false" json:"is_admin"`
+	InvitedBy  *uuid.UUID `gorm:"type:uuid" json:"invited_by,omitempty"`
+	TokenID    uuid.UUID  `gorm:"type:uuid;not null" json:"-"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	// UserID is the user who accepted the invitation.
+	UserID    *uuid.UUID `gorm:"type:uuid" json:"user_id,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `gorm:"not null" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"not null" json:"updated_at"`
+	// Status is filled in from StatusAt when the invitation is returned.
+	Status string `gorm:"-" json:"status,omitempty"`
+}
+
+func (Invitation) TableName() string {
+	return "invitation"
+}
+
+// StatusAt returns whether the invitation is pending, accepted, revoked or
+// expired at now.
+func (i Invitation) StatusAt(now time.Time) string {
+	switch {
+	case i.AcceptedAt != nil:
+		return InvitationAccepted
+	case i.RevokedAt != nil:
+		return InvitationRevoked
+	case !now.Before(i.ExpiresAt):
+		return InvitationExpired
+	}
+	return InvitationPending
+}
+
+// InvitationRequest invites Email. Role defaults to member; only service
+// administrators may invite other administrators.
+type InvitationRequest struct {
+	Email   string `json:"email"`
+	Role    string `json:"role,omitempty"`
+	IsAdmin bool   `json:"is_admin,omitempty"`
+}
+
+// InvitationResponse is the only place an invitation's token is shown.
+// AcceptURL is the configured invitation URL with the token appended, if
+// one is configured.
+type InvitationResponse struct {
+	Invitation
+	Token     string `json:"token"`
+	AcceptURL string `json:"accept_url,omitempty"`
+}
+
+// AcceptInvitationRequest accepts an invitation. Username is only used when
+// a new account is created; a user who already has an account joins with
+// their current password instead.
+type AcceptInvitationRequest struct {
+	Token    string `json:"token"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
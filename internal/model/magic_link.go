@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MagicLink logs UserID in to OrgID without a password, once, until it
+// expires. It is used with a signed token whose jti is ID.
+type MagicLink struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null"`
+	OrgID      uuid.UUID `gorm:"type:uuid;not null"`
+	ClientID   string    `gorm:"type:varchar(64);not null"`
+	RememberMe bool      `gorm:"not null;default:false"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	UsedAt     *time.Time
+	CreatedAt  time.Time `gorm:"not null"`
+}
+
+func (MagicLink) TableName() string {
+	return "magic_link"
+}
+
+// MagicLinkRequest asks for a login link to be sent to Email. ClientID and
+// RememberMe apply to the session the link starts, as for a login.
+type MagicLinkRequest struct {
+	Email      string `json:"email"`
+	ClientID   string `json:"client_id,omitempty"`
+	RememberMe bool   `json:"remember_me,omitempty"`
+}
+
+// VerifyMagicLinkRequest logs in with the token of a login link.
+type VerifyMagicLinkRequest struct {
+	Token string `json:"token"`
+	// UseCookies asks for the tokens in cookies instead of the response body.
+	UseCookies bool `json:"use_cookies,omitempty"`
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultOrganizationID is the organization created by the migration that
+// introduced organizations. Single-tenant deployments keep every user in it.
+var DefaultOrganizationID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+const DefaultOrganizationSlug = "default"
+
+// Roles of a user in an organization
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+var OrgRoles = []string{OrgRoleOwner, OrgRoleAdmin, OrgRoleMember}
+
+// slugPattern keeps slugs usable as DNS labels, for subdomain tenancy.
+var slugPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// ValidSlug reports whether slug may identify an organization.
+func ValidSlug(slug string) bool {
+	return slugPattern.MatchString(slug)
+}
+
+type Organization struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	Slug      string    `gorm:"type:varchar(63);not null" json:"slug"`
+	Name      string    `gorm:"type:varchar(255);not null" json:"name"`
+	CreatedAt time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null" json:"updated_at"`
+}
+
+func (Organization) TableName() string {
+	return "organization"
+}
+
+// Membership gives a user a role in an organization.
+type Membership struct {
+	OrgID     uuid.UUID `gorm:"type:uuid;primary_key" json:"org_id"`
+	UserID    uuid.UUID `gorm:"type:uuid;primary_key" json:"user_id"`
+	Role      string    `gorm:"type:varchar(16);not null" json:"role"`
+	CreatedAt time.Time `gorm:"not null" json:"created_at"`
+}
+
+func (Membership) TableName() string {
+	return "organization_membership"
+}
@@ -0,0 +1,28 @@
+package model
+
+// IntrospectionRequest asks about a token, following RFC 7662. The token
+// may be sent as JSON or as a form parameter.
+type IntrospectionRequest struct {
+	Token string `json:"token" form:"token"`
+}
+
+// IntrospectionResponse describes an access or refresh token. Inactive
+// tokens, whether invalid, expired, revoked or of a disabled user, only
+// carry Active.
+type IntrospectionResponse struct {
+	Active bool `json:"active"`
+	// TokenType is access or refresh.
+	TokenType string `json:"token_type,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	Username  string `json:"username,omitempty"`
+	IsAdmin   bool   `json:"is_admin,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	// TenantID and Org identify the organization the token was issued for,
+	// and OrgRole is the user's current role in it.
+	TenantID  string `json:"tenant_id,omitempty"`
+	Org       string `json:"org,omitempty"`
+	OrgRole   string `json:"org_role,omitempty"`
+	TokenID   string `json:"jti,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+}
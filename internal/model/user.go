@@ -11,14 +11,30 @@ import (
 )
 
 type User struct {
-	ID           uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`
-	Username     string         `gorm:"type:varchar(50);unique;not null" json:"username"`
-	Email        string         `gorm:"type:varchar(255);unique;not null" json:"email"`
-	PasswordHash string         `gorm:"type:varchar(255);not null" json:"-"`
-	IsAdmin      bool           `gorm:"not null;default:false" json:"is_admin"`
-	CreatedAt    time.Time      `gorm:"not null" json:"created_at"`
-	UpdatedAt    time.Time      `gorm:"not null" json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	ID uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	// Username and Email are unique case-insensitively within
+	// UniquenessScope through the lower(...) indexes in migration/, not
+	// through column constraints.
+	Username     string `gorm:"type:varchar(50);not null" json:"username"`
+	Email        string `gorm:"type:varchar(255);not null" json:"email"`
+	PasswordHash string `gorm:"type:varchar(255);not null" json:"-"`
+	// IsAdmin makes the user an administrator of the whole service, as
+	// opposed to OrgRole.
+	IsAdmin    bool           `gorm:"not null;default:false" json:"is_admin"`
+	DisabledAt *time.Time     `json:"disabled_at,omitempty"`
+	CreatedAt  time.Time      `gorm:"not null" json:"created_at"`
+	UpdatedAt  time.Time      `gorm:"not null" json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	// EmailVerifiedAt is when the user first proved they own Email, by
+	// logging in with a magic link.
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// UniquenessScope is the organization the user registered in when
+	// usernames and emails are unique per organization, or uuid.Nil when
+	// they are unique across all of them.
+	UniquenessScope uuid.UUID `gorm:"type:uuid;not null" json:"-"`
+	// OrgRole is the user's role in the organization of the request. It is
+	// read along with tenant-scoped lookups and never written.
+	OrgRole string `gorm:"->;-:migration" json:"org_role,omitempty"`
 }
 
 func (User) TableName() string {
@@ -29,6 +45,8 @@ type UserRequest struct {
 	Username string `json:"username" validate:"required,min=3,max=50"`
 	Email    string `json:"email" validate:"required,email,max=255"`
 	Password string `json:"password" validate:"required,min=6,max=100"`
+	// CaptchaToken is the solved captcha, required when captchas are enabled.
+	CaptchaToken string `json:"captcha_token,omitempty"`
 }
 
 type UserResponse struct {
@@ -38,6 +56,17 @@ type UserResponse struct {
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required,email,max=255"`
 	Password string `json:"password" validate:"required,min=6,max=100"`
+	// ClientID selects the token lifetimes configured for the client, if any.
+	// It is not authenticated, so any caller can pick any configured client:
+	// client lifetimes are advisory, and only role lifetimes are enforced.
+	ClientID string `json:"client_id,omitempty"`
+	// RememberMe asks for a long-lived refresh token.
+	RememberMe bool `json:"remember_me,omitempty"`
+	// UseCookies asks for the tokens in cookies instead of the response body.
+	UseCookies bool `json:"use_cookies,omitempty"`
+	// CaptchaToken is the solved captcha, required after repeated failed
+	// logins to the account when captchas are enabled.
+	CaptchaToken string `json:"captcha_token,omitempty"`
 }
 
 type LoginResponse struct {
@@ -45,12 +74,25 @@ type LoginResponse struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+// RefreshTokenRequest is also used to log out. RefreshToken is required,
+// except in cookie sessions, where it may be omitted and is read from its
+// cookie instead.
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
 }
 
+// RefreshTokenResponse carries a new refresh token too, which extends the
+// session and should replace the one sent.
 type RefreshTokenResponse struct {
-	AccessToken string `json:"access_token"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// CookieSessionResponse answers logins and refreshes of cookie sessions. The
+// CSRF token must be sent in the X-CSRF-Token header of state-changing
+// requests.
+type CookieSessionResponse struct {
+	CSRFToken string `json:"csrf_token"`
 }
 
 type UpdatePasswordRequest struct {
@@ -82,4 +124,4 @@ func (ur *UpdatePasswordRequest) ValidateUpdatePasswordRequest() error {
 		return fmt.Errorf("new password must be between 6 and 100 characters")
 	}
 	return nil
-}
\ No newline at end of file
+}
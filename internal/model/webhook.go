@@ -0,0 +1,113 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook event types
+const (
+	WebhookUserRegistered      = "user.registered"
+	WebhookUserVerified        = "user.verified"
+	WebhookUserPasswordChanged = "user.password_changed"
+	WebhookUserDisabled        = "user.disabled"
+	WebhookUserDeleted         = "user.deleted"
+)
+
+// WebhookEventTypes lists every event type subscriptions may ask for.
+var WebhookEventTypes = []string{
+	WebhookUserRegistered,
+	WebhookUserVerified,
+	WebhookUserPasswordChanged,
+	WebhookUserDisabled,
+	WebhookUserDeleted,
+}
+
+// Webhook message statuses
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookFailed    = "failed"
+)
+
+// WebhookSubscription receives events of EventTypes at URL. Payloads are
+// signed with Secret, which is only shown when the subscription is created.
+type WebhookSubscription struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	URL        string    `gorm:"not null" json:"url"`
+	Secret     string    `gorm:"not null" json:"-"`
+	EventTypes []string  `gorm:"type:jsonb;serializer:json;not null" json:"event_types"`
+	CreatedAt  time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"not null" json:"updated_at"`
+}
+
+func (WebhookSubscription) TableName() string {
+	return "webhook_subscription"
+}
+
+// WebhookEvent is the JSON body posted to subscribers.
+type WebhookEvent struct {
+	ID         uuid.UUID `json:"id"`
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       any       `json:"data"`
+}
+
+// WebhookUserData is the Data of user events.
+type WebhookUserData struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username,omitempty"`
+	Email    string    `json:"email,omitempty"`
+}
+
+// WebhookMessage is an outbox entry: one event to deliver to one
+// subscription. It is written in the transaction of the change it reports,
+// so an event is sent if and only if the change committed.
+type WebhookMessage struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	SubscriptionID uuid.UUID  `gorm:"type:uuid;not null" json:"subscription_id"`
+	EventID        uuid.UUID  `gorm:"type:uuid;not null" json:"event_id"`
+	EventType      string     `gorm:"type:varchar(64);not null" json:"event_type"`
+	Payload        string     `gorm:"type:jsonb;not null" json:"payload"`
+	Status         string     `gorm:"type:varchar(16);not null" json:"status"`
+	Attempts       int        `gorm:"not null" json:"attempts"`
+	NextAttemptAt  time.Time  `gorm:"not null" json:"next_attempt_at"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `gorm:"not null" json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+
+	Subscription *WebhookSubscription `gorm:"-" json:"-"`
+}
+
+func (WebhookMessage) TableName() string {
+	return "webhook_outbox"
+}
+
+// WebhookDelivery logs one delivery attempt of a message.
+type WebhookDelivery struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	MessageID      uuid.UUID `gorm:"type:uuid;not null" json:"message_id"`
+	SubscriptionID uuid.UUID `gorm:"type:uuid;not null" json:"subscription_id"`
+	EventType      string    `gorm:"type:varchar(64);not null" json:"event_type"`
+	Attempt        int       `gorm:"not null" json:"attempt"`
+	StatusCode     int       `json:"status_code,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	DurationMS     int64     `json:"duration_ms"`
+	AttemptedAt    time.Time `gorm:"not null" json:"attempted_at"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_delivery"
+}
+
+type CreateWebhookRequest struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+}
+
+// CreateWebhookResponse is the only place a subscription's secret is shown.
+type CreateWebhookResponse struct {
+	WebhookSubscription
+	Secret string `json:"secret"`
+}
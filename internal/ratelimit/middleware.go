@@ -0,0 +1,164 @@
+package ratelimit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/logging"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+// Response headers, following the IETF RateLimit header fields draft
+const (
+	HeaderLimit     = "RateLimit-Limit"
+	HeaderRemaining = "RateLimit-Remaining"
+	HeaderReset     = "RateLimit-Reset"
+	HeaderPolicy    = "RateLimit-Policy"
+)
+
+// KeyFunc returns what a request is counted against, or "" to not count it.
+type KeyFunc func(c echo.Context) string
+
+// Rule is one limit applied to a route, e.g. logins per IP.
+type Rule struct {
+	// Name namespaces the buckets of this rule, e.g. "login:ip".
+	Name  string
+	Limit config.RateLimit
+	Key   KeyFunc
+}
+
+// ByIP counts requests per client IP, as resolved by Echo's IP extractor.
+func ByIP(c echo.Context) string {
+	return c.RealIP()
+}
+
+// ByEmail counts requests per email of the request body, normalized with
+// policy like the service does so every spelling of an address shares one
+// bucket. The body is bound into a new T with c.Bind, like the handler does,
+// so the email counted is the one the handler will see however the body
+// spells or repeats its keys. Values that aren't valid emails are counted as
+// they are. Requests with no email, or a body the binder rejects, are
+// counted per client IP instead of escaping the limit.
+func ByEmail[T any](email func(*T) string, policy utils.EmailPolicy) KeyFunc {
+	return func(c echo.Context) string {
+		v := strings.TrimSpace(bindEmail(c, email))
+		if v == "" {
+			return "ip:" + c.RealIP()
+		}
+		if normalized, err := utils.NormalizeEmail(v, policy); err == nil {
+			v = normalized
+		}
+		return hashKey(v)
+	}
+}
+
+// bindEmail binds the request into a new T and returns its email, leaving
+// the body in place for the handler.
+func bindEmail[T any](c echo.Context, email func(*T) string) string {
+	req := c.Request()
+	if req.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	defer func() { req.Body = io.NopCloser(bytes.NewReader(body)) }()
+
+	var v T
+	if c.Bind(&v) != nil {
+		return ""
+	}
+	return email(&v)
+}
+
+func hashKey(v string) string {
+	if v == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(v))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Middleware enforces rules, answering 429 Too Many Requests once any of
+// them is exhausted. Headers describe the rule closest to its limit. If the
+// store fails the request is let through, as an outage of the store should
+// not take logins down with it.
+func Middleware(store Store, logger *slog.Logger, rules ...Rule) echo.MiddlewareFunc {
+	var active []Rule
+	var policies []string
+	for _, r := range rules {
+		if r.Limit.Enabled() {
+			active = append(active, r)
+			policies = append(policies, strconv.Itoa(r.Limit.Requests)+";w="+seconds(r.Limit.Period))
+		}
+	}
+	policy := strings.Join(policies, ", ")
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		if len(active) == 0 {
+			return next
+		}
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+
+			var tightest *Result
+			for _, rule := range active {
+				key := rule.Key(c)
+				if key == "" {
+					continue
+				}
+				res, err := store.Take(ctx, "ratelimit:"+rule.Name+":"+key, rule.Limit)
+				if err != nil {
+					logging.FromContext(ctx, logger).Error("rate limit store failed", slog.String("rule", rule.Name), slog.Any("error", err))
+					continue
+				}
+				if tightest == nil || tighter(res, *tightest) {
+					tightest = &res
+				}
+			}
+			if tightest == nil {
+				return next(c)
+			}
+
+			h := c.Response().Header()
+			h.Set(HeaderLimit, strconv.Itoa(tightest.Limit))
+			h.Set(HeaderRemaining, strconv.Itoa(tightest.Remaining))
+			h.Set(HeaderReset, seconds(tightest.Reset))
+			h.Set(HeaderPolicy, policy)
+			if !tightest.Allowed {
+				h.Set("Retry-After", seconds(tightest.RetryAfter))
+				return c.JSON(http.StatusTooManyRequests, map[string]string{"error": "Too many requests"})
+			}
+			return next(c)
+		}
+	}
+}
+
+// tighter reports whether a is closer to blocking than b.
+func tighter(a, b Result) bool {
+	if a.Allowed != b.Allowed {
+		return !a.Allowed
+	}
+	if !a.Allowed {
+		return a.RetryAfter > b.RetryAfter
+	}
+	return a.Remaining < b.Remaining
+}
+
+// seconds formats d as whole seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,50 @@
+// Package ratelimit throttles requests with token buckets kept in a
+// pluggable store.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+)
+
+// Result is the state of a bucket after a request took, or failed to take,
+// a token from it.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next token, when not Allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps token buckets. A bucket holds limit.Requests tokens and
+// refills at limit.Requests per limit.Period; each request takes one.
+type Store interface {
+	Take(ctx context.Context, key string, limit config.RateLimit) (Result, error)
+}
+
+// refill returns the tokens in a bucket last seen holding tokens elapsed ago.
+func refill(limit config.RateLimit, tokens float64, elapsed time.Duration) float64 {
+	rate := float64(limit.Requests) / float64(limit.Period)
+	return math.Min(float64(limit.Requests), tokens+float64(max(elapsed, 0))*rate)
+}
+
+// newResult describes a bucket left with tokens after the request.
+func newResult(limit config.RateLimit, tokens float64, allowed bool) Result {
+	perToken := float64(limit.Period) / float64(limit.Requests)
+	r := Result{
+		Allowed:   allowed,
+		Limit:     limit.Requests,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration(math.Ceil((float64(limit.Requests) - tokens) * perToken)),
+	}
+	if !allowed {
+		r.RetryAfter = time.Duration(math.Ceil((1 - tokens) * perToken))
+	}
+	return r
+}
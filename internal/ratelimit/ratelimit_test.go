@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T, c *clock) Store{
+		"Memory": func(t *testing.T, c *clock) Store {
+			s := NewMemoryStore()
+			s.now = c.Now
+			return s
+		},
+		"Redis": func(t *testing.T, c *clock) Store {
+			mr := miniredis.RunT(t)
+			s := NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+			s.now = c.Now
+			return s
+		},
+	}
+
+	limit := config.RateLimit{Requests: 3, Period: time.Minute}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			c := &clock{now: time.Unix(1_700_000_000, 0)}
+			store := newStore(t, c)
+			ctx := context.Background()
+
+			for i := 2; i >= 0; i-- {
+				res, err := store.Take(ctx, "k", limit)
+				assert.NoError(t, err)
+				assert.True(t, res.Allowed)
+				assert.Equal(t, i, res.Remaining)
+			}
+
+			res, err := store.Take(ctx, "k", limit)
+			assert.NoError(t, err)
+			assert.False(t, res.Allowed)
+			assert.Equal(t, 20*time.Second, res.RetryAfter)
+			assert.Equal(t, time.Minute, res.Reset)
+
+			// other keys have their own bucket
+			res, err = store.Take(ctx, "other", limit)
+			assert.NoError(t, err)
+			assert.True(t, res.Allowed)
+
+			// one token refills every 20s
+			c.now = c.now.Add(20 * time.Second)
+			res, err = store.Take(ctx, "k", limit)
+			assert.NoError(t, err)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, 0, res.Remaining)
+		})
+	}
+}
+
+// loginBody is the body a login handler binds.
+type loginBody struct {
+	Email string `json:"email"`
+}
+
+func loginEmail(r *loginBody) string { return r.Email }
+
+func TestMiddleware(t *testing.T) {
+	store := NewMemoryStore()
+	e := echo.New()
+	e.POST("/login", func(c echo.Context) error {
+		// the body is still readable after the account key was taken from it
+		var req loginBody
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, req.Email)
+	}, Middleware(store, slog.New(slog.DiscardHandler),
+		Rule{Name: "login:ip", Limit: config.RateLimit{Requests: 5, Period: time.Minute}, Key: ByIP},
+		Rule{Name: "login:account", Limit: config.RateLimit{Requests: 2, Period: time.Minute}, Key: ByEmail(loginEmail, utils.EmailPolicy{StripPlusTag: true})},
+	))
+
+	login := func(ip, email string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email":"`+email+`"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := login("203.0.113.1", "bob@example.com")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "bob@example.com", rec.Body.String())
+	assert.Equal(t, "2", rec.Header().Get(HeaderLimit))
+	assert.Equal(t, "1", rec.Header().Get(HeaderRemaining))
+	assert.Equal(t, "30", rec.Header().Get(HeaderReset))
+	assert.Equal(t, "5;w=60, 2;w=60", rec.Header().Get(HeaderPolicy))
+
+	// the account limit applies across IPs and to every spelling of the
+	// email the service treats as the same account
+	assert.Equal(t, http.StatusOK, login("203.0.113.2", "Bob+work@Example.com").Code)
+	rec = login("203.0.113.3", "bob@example.com")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "0", rec.Header().Get(HeaderRemaining))
+
+	// the IP limit applies across accounts
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, http.StatusOK, login("203.0.113.1", "user"+string(rune('a'+i))+"@example.com").Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, login("203.0.113.1", "fresh@example.com").Code)
+}
+
+func TestByEmail(t *testing.T) {
+	key := func(body string) string {
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.RemoteAddr = "203.0.113.1:1234"
+		return ByEmail(loginEmail, utils.EmailPolicy{})(echo.New().NewContext(req, httptest.NewRecorder()))
+	}
+
+	bob := key(`{"email":"bob@example.com"}`)
+	assert.Equal(t, bob, key(`{"email":" BOB@Example.com "}`))
+	assert.NotEqual(t, bob, key(`{"email":"bob+work@example.com"}`), "plus tags are kept unless the policy strips them")
+	// keys are read like the handler binds them, case-insensitively with
+	// the last one winning
+	assert.Equal(t, bob, key(`{"EMAIL":"bob@example.com"}`))
+	assert.Equal(t, bob, key(`{"email":"decoy@example.com","Email":"bob@example.com"}`))
+	// invalid emails still get a key of their own
+	assert.NotEmpty(t, key(`{"email":"not-an-email"}`))
+	assert.NotEqual(t, key(`{"email":"not-an-email"}`), key(`{"email":"other"}`))
+	// requests without an email are counted per IP
+	assert.Equal(t, "ip:203.0.113.1", key(`{"email":""}`))
+	assert.Equal(t, "ip:203.0.113.1", key(`{"user":"bob@example.com"}`))
+	assert.Equal(t, "ip:203.0.113.1", key(`not json`))
+}
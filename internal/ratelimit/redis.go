@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+)
+
+// takeScript refills and takes from the bucket in KEYS[1] atomically. ARGV
+// holds the capacity, the period and the current time, both in
+// milliseconds. It returns whether a token was taken and the tokens left,
+// as a string since Lua numbers are truncated to integers on return.
+var takeScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * capacity / period)
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], period)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisStore keeps buckets in Redis, so all instances share limits. Any
+// client implementing redis.Scripter works, including cluster and ring
+// clients. Instance clocks are used for refills and should be in sync.
+type RedisStore struct {
+	client redis.Scripter
+	now    func() time.Time
+}
+
+func NewRedisStore(client redis.Scripter) *RedisStore {
+	return &RedisStore{client: client, now: time.Now}
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, limit config.RateLimit) (Result, error) {
+	res, err := takeScript.Run(ctx, s.client, []string{key},
+		limit.Requests, limit.Period.Milliseconds(), s.now().UnixMilli()).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	allowed, _ := res[0].(int64)
+	left, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(left, 64)
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(limit, tokens, allowed == 1), nil
+}
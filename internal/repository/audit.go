@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"gorm.io/gorm"
+)
+
+// AuditRepository holds the audit log of the whole service. Unlike
+// UserRepository it ignores the tenant in the context: events are only read
+// by service admins.
+type AuditRepository interface {
+	CreateEvent(ctx context.Context, event model.AuditEvent) error
+	// ListEvents returns events matching filter, newest first.
+	ListEvents(ctx context.Context, filter model.AuditFilter) ([]model.AuditEvent, error)
+}
+
+type auditRepository struct {
+	dbConn
+}
+
+func NewAuditRepository(db *gorm.DB, queryTimeout time.Duration) AuditRepository {
+	return &auditRepository{dbConn{db: db, queryTimeout: queryTimeout}}
+}
+
+func (r *auditRepository) CreateEvent(ctx context.Context, event model.AuditEvent) error {
+	ctx, span := tracer.Start(ctx, "auditRepository.CreateEvent")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	return db.Create(&event).Error
+}
+
+func (r *auditRepository) ListEvents(ctx context.Context, filter model.AuditFilter) ([]model.AuditEvent, error) {
+	ctx, span := tracer.Start(ctx, "auditRepository.ListEvents")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	query := db.Model(&model.AuditEvent{})
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Outcome != "" {
+		query = query.Where("outcome = ?", filter.Outcome)
+	}
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.TargetID != nil {
+		query = query.Where("target_id = ?", *filter.TargetID)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if filter.Since != nil {
+		query = query.Where("occurred_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("occurred_at < ?", *filter.Until)
+	}
+	if filter.After != nil {
+		// keyset pagination, served by idx_audit_event_occurred_at
+		query = query.Where("(occurred_at, id) < (?, ?)", filter.After.OccurredAt, filter.After.ID)
+	}
+
+	var events []model.AuditEvent
+	err := query.Order("occurred_at DESC, id DESC").Limit(filter.Limit).Find(&events).Error
+	return events, err
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// dbConn is embedded by the repositories to bind their queries to the
+// caller's context.
+type dbConn struct {
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+// conn returns db bound to ctx and, when queryTimeout is positive, cut off
+// after queryTimeout. Call the CancelFunc once the query is done.
+func (c dbConn) conn(ctx context.Context) (*gorm.DB, context.CancelFunc) {
+	if c.queryTimeout <= 0 {
+		return c.db.WithContext(ctx), func() {}
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.queryTimeout)
+	return c.db.WithContext(ctx), cancel
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+)
+
+type InvitationRepository interface {
+	CreateInvitation(ctx context.Context, inv model.Invitation) error
+	// FindInvitation returns gorm.ErrRecordNotFound for invitations of
+	// other organizations.
+	FindInvitation(ctx context.Context, orgID, id uuid.UUID) (model.Invitation, error)
+	// ListInvitations returns the latest limit invitations of the
+	// organization, newest first.
+	ListInvitations(ctx context.Context, orgID uuid.UUID, limit int) ([]model.Invitation, error)
+	// UpdatePending applies the non-zero fields of updates to the invitation
+	// if it is neither accepted nor revoked and its token is still tokenID,
+	// and returns gorm.ErrRecordNotFound otherwise. Concurrent accepts,
+	// revocations and resends of an invitation thus can't both succeed.
+	UpdatePending(ctx context.Context, id, tokenID uuid.UUID, updates model.Invitation) error
+}
+
+type invitationRepository struct {
+	dbConn
+}
+
+func NewInvitationRepository(db *gorm.DB, queryTimeout time.Duration) InvitationRepository {
+	return &invitationRepository{dbConn{db: db, queryTimeout: queryTimeout}}
+}
+
+func (r *invitationRepository) CreateInvitation(ctx context.Context, inv model.Invitation) error {
+	ctx, span := tracer.Start(ctx, "invitationRepository.CreateInvitation")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	return db.Create(&inv).Error
+}
+
+func (r *invitationRepository) FindInvitation(ctx context.Context, orgID, id uuid.UUID) (model.Invitation, error) {
+	ctx, span := tracer.Start(ctx, "invitationRepository.FindInvitation")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	var inv model.Invitation
+	err := db.First(&inv, "id = ? AND org_id = ?", id, orgID).Error
+	return inv, err
+}
+
+func (r *invitationRepository) ListInvitations(ctx context.Context, orgID uuid.UUID, limit int) ([]model.Invitation, error) {
+	ctx, span := tracer.Start(ctx, "invitationRepository.ListInvitations")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	var invs []model.Invitation
+	err := db.Where("org_id = ?", orgID).Order("created_at DESC").Limit(limit).Find(&invs).Error
+	return invs, err
+}
+
+func (r *invitationRepository) UpdatePending(ctx context.Context, id, tokenID uuid.UUID, updates model.Invitation) error {
+	ctx, span := tracer.Start(ctx, "invitationRepository.UpdatePending")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	result := db.Model(&model.Invitation{}).
+		Where("id = ? AND token_id = ? AND accepted_at IS NULL AND revoked_at IS NULL", id, tokenID).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
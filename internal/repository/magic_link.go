@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+)
+
+type MagicLinkRepository interface {
+	// CreateMagicLink stores link and deletes the user's links that were
+	// used or have expired.
+	CreateMagicLink(ctx context.Context, link model.MagicLink) error
+	// UseMagicLink marks the link used at now and returns it if it is
+	// neither used nor expired, and returns gorm.ErrRecordNotFound
+	// otherwise. A link thus logs in at most once, even when used
+	// concurrently.
+	UseMagicLink(ctx context.Context, id uuid.UUID, now time.Time) (model.MagicLink, error)
+}
+
+type magicLinkRepository struct {
+	dbConn
+}
+
+func NewMagicLinkRepository(db *gorm.DB, queryTimeout time.Duration) MagicLinkRepository {
+	return &magicLinkRepository{dbConn{db: db, queryTimeout: queryTimeout}}
+}
+
+func (r *magicLinkRepository) CreateMagicLink(ctx context.Context, link model.MagicLink) error {
+	ctx, span := tracer.Start(ctx, "magicLinkRepository.CreateMagicLink")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	err := db.Where("user_id = ? AND (used_at IS NOT NULL OR expires_at <= ?)", link.UserID, link.CreatedAt).
+		Delete(&model.MagicLink{}).Error
+	if err != nil {
+		return err
+	}
+	return db.Create(&link).Error
+}
+
+func (r *magicLinkRepository) UseMagicLink(ctx context.Context, id uuid.UUID, now time.Time) (model.MagicLink, error) {
+	ctx, span := tracer.Start(ctx, "magicLinkRepository.UseMagicLink")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	var link model.MagicLink
+	result := db.Model(&link).Clauses(clause.Returning{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", id, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return model.MagicLink{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return model.MagicLink{}, gorm.ErrRecordNotFound
+	}
+	return link, nil
+}
@@ -0,0 +1,73 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/repository/magic_link.go
+//
+// Generated by this command:
+//
+//	mockgen -source=internal/repository/magic_link.go -destination=internal/repository/mocks/magic_link_mock.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	uuid "github.com/google/uuid"
+	model "github.com/kevinmarcellius/go-simple-auth/internal/model"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockMagicLinkRepository is a mock of MagicLinkRepository interface.
+type MockMagicLinkRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockMagicLinkRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockMagicLinkRepositoryMockRecorder is the mock recorder for MockMagicLinkRepository.
+type MockMagicLinkRepositoryMockRecorder struct {
+	mock *MockMagicLinkRepository
+}
+
+// NewMockMagicLinkRepository creates a new mock instance.
+func NewMockMagicLinkRepository(ctrl *gomock.Controller) *MockMagicLinkRepository {
+	mock := &MockMagicLinkRepository{ctrl: ctrl}
+	mock.recorder = &MockMagicLinkRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMagicLinkRepository) EXPECT() *MockMagicLinkRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateMagicLink mocks base method.
+func (m *MockMagicLinkRepository) CreateMagicLink(ctx context.Context, link model.MagicLink) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMagicLink", ctx, link)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateMagicLink indicates an expected call of CreateMagicLink.
+func (mr *MockMagicLinkRepositoryMockRecorder) CreateMagicLink(ctx, link any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMagicLink", reflect.TypeOf((*MockMagicLinkRepository)(nil).CreateMagicLink), ctx, link)
+}
+
+// UseMagicLink mocks base method.
+func (m *MockMagicLinkRepository) UseMagicLink(ctx context.Context, id uuid.UUID, now time.Time) (model.MagicLink, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseMagicLink", ctx, id, now)
+	ret0, _ := ret[0].(model.MagicLink)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseMagicLink indicates an expected call of UseMagicLink.
+func (mr *MockMagicLinkRepositoryMockRecorder) UseMagicLink(ctx, id, now any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseMagicLink", reflect.TypeOf((*MockMagicLinkRepository)(nil).UseMagicLink), ctx, id, now)
+}
@@ -0,0 +1,17 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository"
+)
+
+// TxManager runs units of work directly against Repos, without a database
+// transaction, so services can be tested with repository mocks.
+type TxManager struct {
+	Repos repository.Repositories
+}
+
+func (m TxManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context, repos repository.Repositories) error) error {
+	return fn(ctx, m.Repos)
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+)
+
+type OrganizationRepository interface {
+	CreateOrganization(ctx context.Context, org model.Organization) error
+	FindBySlug(ctx context.Context, slug string) (model.Organization, error)
+	// SetMember adds the user to the organization with role, or changes
+	// their role if they are a member already.
+	SetMember(ctx context.Context, membership model.Membership) error
+	// RemoveMember returns gorm.ErrRecordNotFound if the user wasn't a
+	// member.
+	RemoveMember(ctx context.Context, orgID, userID uuid.UUID) error
+}
+
+type organizationRepository struct {
+	dbConn
+}
+
+func NewOrganizationRepository(db *gorm.DB, queryTimeout time.Duration) OrganizationRepository {
+	return &organizationRepository{dbConn{db: db, queryTimeout: queryTimeout}}
+}
+
+func (r *organizationRepository) CreateOrganization(ctx context.Context, org model.Organization) error {
+	ctx, span := tracer.Start(ctx, "organizationRepository.CreateOrganization")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	return db.Create(&org).Error
+}
+
+func (r *organizationRepository) FindBySlug(ctx context.Context, slug string) (model.Organization, error) {
+	ctx, span := tracer.Start(ctx, "organizationRepository.FindBySlug")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	var org model.Organization
+	err := db.First(&org, "slug = ?", slug).Error
+	return org, err
+}
+
+func (r *organizationRepository) SetMember(ctx context.Context, membership model.Membership) error {
+	ctx, span := tracer.Start(ctx, "organizationRepository.SetMember")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "org_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role"}),
+	}).Create(&membership).Error
+}
+
+func (r *organizationRepository) RemoveMember(ctx context.Context, orgID, userID uuid.UUID) error {
+	ctx, span := tracer.Start(ctx, "organizationRepository.RemoveMember")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	result := db.Delete(&model.Membership{}, "org_id = ? AND user_id = ?", orgID, userID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Repositories are the repositories available to a unit of work, all bound
+// to the same transaction.
+type Repositories struct {
+	Users         UserRepository
+	Audit         AuditRepository
+	Webhooks      WebhookRepository
+	Organizations OrganizationRepository
+	Invitations   InvitationRepository
+	MagicLinks    MagicLinkRepository
+}
+
+type TxManager interface {
+	// WithinTransaction runs fn in a transaction and commits if it returns
+	// nil. The transaction is rolled back if fn returns an error or panics;
+	// the panic is re-raised after rollback. Calling WithinTransaction again
+	// with the ctx passed to fn nests the work in a savepoint, so an inner
+	// failure only rolls back the inner work.
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) error
+}
+
+type txKey struct{}
+
+type txManager struct {
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+func NewTxManager(db *gorm.DB, queryTimeout time.Duration) TxManager {
+	return &txManager{db: db, queryTimeout: queryTimeout}
+}
+
+func (m *txManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) error {
+	db := m.db
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		// gorm turns a Transaction on an open transaction into a savepoint
+		db = tx
+	}
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx), m.repositories(tx))
+	})
+}
+
+func (m *txManager) repositories(tx *gorm.DB) Repositories {
+	return Repositories{
+		Users:         NewUserRepository(tx, m.queryTimeout),
+		Audit:         NewAuditRepository(tx, m.queryTimeout),
+		Webhooks:      NewWebhookRepository(tx, m.queryTimeout),
+		Organizations: NewOrganizationRepository(tx, m.queryTimeout),
+		Invitations:   NewInvitationRepository(tx, m.queryTimeout),
+		MagicLinks:    NewMagicLinkRepository(tx, m.queryTimeout),
+	}
+}
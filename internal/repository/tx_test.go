@@ -0,0 +1,90 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository"
+)
+
+func newMockTxManager(t *testing.T) (repository.TxManager, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	assert.NoError(t, err)
+	return repository.NewTxManager(db, 0), mock
+}
+
+func TestTxManager_Commit(t *testing.T) {
+	manager, mock := newMockTxManager(t)
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	err := manager.WithinTransaction(context.Background(), func(ctx context.Context, repos repository.Repositories) error {
+		assert.NotNil(t, repos.Users)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTxManager_RollbackOnError(t *testing.T) {
+	manager, mock := newMockTxManager(t)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	err := manager.WithinTransaction(context.Background(), func(ctx context.Context, repos repository.Repositories) error {
+		return assert.AnError
+	})
+
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTxManager_RollbackOnPanic(t *testing.T) {
+	manager, mock := newMockTxManager(t)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = manager.WithinTransaction(context.Background(), func(ctx context.Context, repos repository.Repositories) error {
+			panic("boom")
+		})
+	})
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTxManager_NestedSavepoint(t *testing.T) {
+	manager, mock := newMockTxManager(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT sp").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT sp").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT sp").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	innerErr := errors.New("inner failed")
+	err := manager.WithinTransaction(context.Background(), func(ctx context.Context, repos repository.Repositories) error {
+		// an inner failure only rolls back to its savepoint
+		err := manager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+			return innerErr
+		})
+		assert.ErrorIs(t, err, innerErr)
+
+		// a successful inner unit keeps its savepoint and commits with the outer one
+		return manager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+			return nil
+		})
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,46 +1,149 @@
 package repository
 
 import (
+	"context"
+	"time"
+
 	"github.com/google/uuid"
-	"gorm.io/gorm"
 	model "github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/tenant"
+	"go.opentelemetry.io/otel"
+	"gorm.io/gorm"
 )
 
+var tracer = otel.Tracer("github.com/kevinmarcellius/go-simple-auth/internal/repository")
+
+// UserRepository only sees the users that are members of the tenant in the
+// context, if any; lookups then also read the user's OrgRole in it.
 type UserRepository interface {
 	// finduserbyid, id is uuid
-	FindUserByID(id uuid.UUID) (model.User, error)
-	CreateUser(user model.User) error
-	GetUserByEmail(email string) (model.User, error)
-	UpdateUserById(id uuid.UUID, updatedUser model.User) error
+	FindUserByID(ctx context.Context, id uuid.UUID) (model.User, error)
+	CreateUser(ctx context.Context, user model.User) error
+	GetUserByEmail(ctx context.Context, email string) (model.User, error)
+	// ListUsersByEmail returns up to limit users with email. There can be
+	// several when TENANCY_UNIQUENESS=tenant, registered in different
+	// organizations.
+	ListUsersByEmail(ctx context.Context, email string, limit int) ([]model.User, error)
+	// UpdateUserById sets the non-zero fields of updatedUser. It returns
+	// gorm.ErrRecordNotFound if no user was updated, e.g. because they are
+	// not a member of the tenant in the context.
+	UpdateUserById(ctx context.Context, id uuid.UUID, updatedUser model.User) error
+	// DeleteUser soft-deletes the user, whose username and email stay
+	// taken. It returns gorm.ErrRecordNotFound if no user was deleted.
+	DeleteUser(ctx context.Context, id uuid.UUID) error
 }
 
 type userRepository struct {
-	db *gorm.DB
+	dbConn
+}
+
+// NewUserRepository returns a UserRepository backed by db. Each query is
+// bound to the caller's context and, when queryTimeout is positive, cut off
+// after queryTimeout.
+func NewUserRepository(db *gorm.DB, queryTimeout time.Duration) UserRepository {
+	return &userRepository{dbConn{db: db, queryTimeout: queryTimeout}}
 }
 
-func NewUserRepository(db *gorm.DB) UserRepository {
-	return &userRepository{db: db}
+// scoped restricts db to the members of the tenant in ctx and selects their
+// role in it.
+func scoped(ctx context.Context, db *gorm.DB) *gorm.DB {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return db
+	}
+	return db.Select(`"go_user".*, m.role AS org_role`).
+		Joins(`JOIN "organization_membership" m ON m.user_id = "go_user".id AND m.org_id = ?`, t.ID)
 }
 
 // FindUserByID implements UserRepository
-func (r *userRepository) FindUserByID(id uuid.UUID) (model.User, error) {
+func (r *userRepository) FindUserByID(ctx context.Context, id uuid.UUID) (model.User, error) {
+	ctx, span := tracer.Start(ctx, "userRepository.FindUserByID")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
 	var user model.User
-	result := r.db.First(&user, "id = ?", id)
+	result := scoped(ctx, db).First(&user, `"go_user".id = ?`, id)
 	return user, result.Error
 }
 
-func (r *userRepository) CreateUser(user model.User) error {
-	result := r.db.Create(&user)
+func (r *userRepository) CreateUser(ctx context.Context, user model.User) error {
+	ctx, span := tracer.Start(ctx, "userRepository.CreateUser")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	result := db.Create(&user)
 	return result.Error
 }
 
-func (r *userRepository) GetUserByEmail(email string) (model.User, error) {
+// GetUserByEmail matches case-insensitively so rows stored before emails were
+// normalized are still found; the lookup is served by
+// idx_go_user_email_lower_lookup.
+func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (model.User, error) {
+	ctx, span := tracer.Start(ctx, "userRepository.GetUserByEmail")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
 	var user model.User
-	result := r.db.First(&user, "email = ?", email)
+	result := scoped(ctx, db).First(&user, `lower("go_user".email) = lower(?)`, email)
 	return user, result.Error
 }
 
-func (r *userRepository) UpdateUserById(id uuid.UUID, updatedUser model.User) error {
-	result := r.db.Model(&model.User{}).Where("id = ?", id).Updates(updatedUser)
-	return result.Error
-}
\ No newline at end of file
+func (r *userRepository) ListUsersByEmail(ctx context.Context, email string, limit int) ([]model.User, error) {
+	ctx, span := tracer.Start(ctx, "userRepository.ListUsersByEmail")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	var users []model.User
+	err := scoped(ctx, db).Where(`lower("go_user".email) = lower(?)`, email).Limit(limit).Find(&users).Error
+	return users, err
+}
+
+func (r *userRepository) UpdateUserById(ctx context.Context, id uuid.UUID, updatedUser model.User) error {
+	ctx, span := tracer.Start(ctx, "userRepository.UpdateUserById")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	query := db.Model(&model.User{}).Where("id = ?", id)
+	if t, ok := tenant.FromContext(ctx); ok {
+		query = query.Where(`EXISTS (SELECT 1 FROM "organization_membership" m WHERE m.user_id = "go_user".id AND m.org_id = ?)`, t.ID)
+	}
+	result := query.Updates(updatedUser)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	ctx, span := tracer.Start(ctx, "userRepository.DeleteUser")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	query := db.Where("id = ?", id)
+	if t, ok := tenant.FromContext(ctx); ok {
+		query = query.Where(`EXISTS (SELECT 1 FROM "organization_membership" m WHERE m.user_id = "go_user".id AND m.org_id = ?)`, t.ID)
+	}
+	result := query.Delete(&model.User{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/cache"
+	model "github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/tenant"
+)
+
+// cachedUserRepository serves FindUserByID from a cache. Cache failures fall
+// back to next, so an unavailable cache only costs latency.
+type cachedUserRepository struct {
+	UserRepository
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+// NewCachedUserRepository returns a UserRepository that keeps users found by
+// ID in c for ttl. Credentials are never cached: users found by ID have no
+// PasswordHash, whether they came from c or not, so passwords must be
+// checked against lookups by email or in a transaction. UpdateUserById and
+// DeleteUser evict the user; so do updates and membership changes made
+// through a TxManager wrapped with NewCachedTxManager on the same cache.
+// Lookups by email always go to next, so logins see the current password.
+func NewCachedUserRepository(next UserRepository, c cache.Cache, ttl time.Duration) UserRepository {
+	return &cachedUserRepository{UserRepository: next, cache: c, ttl: ttl}
+}
+
+// cachedUser is the cache entry of a user: the row, and its role in each
+// tenant it was looked up in, uuid.Nil standing for lookups without one. A
+// single entry per user lets updates evict it from every tenant at once.
+type cachedUser struct {
+	User  model.User
+	Roles map[uuid.UUID]string
+}
+
+func (r *cachedUserRepository) FindUserByID(ctx context.Context, id uuid.UUID) (model.User, error) {
+	ctx, span := tracer.Start(ctx, "cachedUserRepository.FindUserByID")
+	defer span.End()
+
+	key := userCacheKey(id)
+	var scope uuid.UUID
+	if t, ok := tenant.FromContext(ctx); ok {
+		scope = t.ID
+	}
+
+	entry := cachedUser{Roles: map[uuid.UUID]string{}}
+	if data, found, err := r.cache.Get(ctx, key); err != nil {
+		span.RecordError(err)
+	} else if found {
+		var cached cachedUser
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&cached); err == nil && cached.Roles != nil {
+			if role, ok := cached.Roles[scope]; ok {
+				span.SetAttributes(attribute.Bool("cache.hit", true))
+				user := cached.User
+				user.PasswordHash = ""
+				user.OrgRole = role
+				return user, nil
+			}
+			// known in other tenants; this one is added below
+			entry = cached
+		}
+		// otherwise written by an incompatible build; replaced below
+	}
+	span.SetAttributes(attribute.Bool("cache.hit", false))
+
+	user, err := r.UserRepository.FindUserByID(ctx, id)
+	if err != nil {
+		return user, err
+	}
+	user.PasswordHash = ""
+	entry.User = user
+	entry.User.OrgRole = ""
+	entry.Roles[scope] = user.OrgRole
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(entry); err != nil {
+		span.RecordError(err)
+	} else if err := r.cache.Set(ctx, key, buf.Bytes(), r.ttl); err != nil {
+		span.RecordError(err)
+	}
+	return user, nil
+}
+
+func (r *cachedUserRepository) UpdateUserById(ctx context.Context, id uuid.UUID, updatedUser model.User) error {
+	if err := r.UserRepository.UpdateUserById(ctx, id, updatedUser); err != nil {
+		return err
+	}
+	return evictUser(ctx, r.cache, id)
+}
+
+func (r *cachedUserRepository) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	if err := r.UserRepository.DeleteUser(ctx, id); err != nil {
+		return err
+	}
+	return evictUser(ctx, r.cache, id)
+}
+
+// cachedTxManager evicts the users updated in a transaction from the cache.
+type cachedTxManager struct {
+	TxManager
+	cache cache.Cache
+}
+
+// NewCachedTxManager wraps next so that users updated in its transactions,
+// or whose memberships changed, are evicted from c. Reads in a transaction
+// are not cached. Updated users are evicted before commit, so a failed
+// eviction rolls the update back, and again after, in case a concurrent
+// lookup cached the old row meanwhile.
+func NewCachedTxManager(next TxManager, c cache.Cache) TxManager {
+	return &cachedTxManager{TxManager: next, cache: c}
+}
+
+func (m *cachedTxManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) error {
+	var updated []uuid.UUID
+	err := m.TxManager.WithinTransaction(ctx, func(ctx context.Context, repos Repositories) error {
+		repos.Users = &evictingUserRepository{UserRepository: repos.Users, cache: m.cache, updated: &updated}
+		repos.Organizations = &evictingOrganizationRepository{OrganizationRepository: repos.Organizations, cache: m.cache, updated: &updated}
+		return fn(ctx, repos)
+	})
+	for _, id := range updated {
+		// best effort: the update is committed or rolled back by now, and
+		// the eviction before commit already succeeded
+		evictUser(ctx, m.cache, id)
+	}
+	return err
+}
+
+type evictingUserRepository struct {
+	UserRepository
+	cache   cache.Cache
+	updated *[]uuid.UUID
+}
+
+func (r *evictingUserRepository) UpdateUserById(ctx context.Context, id uuid.UUID, updatedUser model.User) error {
+	if err := r.UserRepository.UpdateUserById(ctx, id, updatedUser); err != nil {
+		return err
+	}
+	*r.updated = append(*r.updated, id)
+	return evictUser(ctx, r.cache, id)
+}
+
+func (r *evictingUserRepository) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	if err := r.UserRepository.DeleteUser(ctx, id); err != nil {
+		return err
+	}
+	*r.updated = append(*r.updated, id)
+	return evictUser(ctx, r.cache, id)
+}
+
+// evictingOrganizationRepository evicts users whose role changed or who
+// left an organization. Joining one needs no eviction, as lookups in
+// organizations a user isn't cached for go to the database.
+type evictingOrganizationRepository struct {
+	OrganizationRepository
+	cache   cache.Cache
+	updated *[]uuid.UUID
+}
+
+func (r *evictingOrganizationRepository) SetMember(ctx context.Context, membership model.Membership) error {
+	if err := r.OrganizationRepository.SetMember(ctx, membership); err != nil {
+		return err
+	}
+	*r.updated = append(*r.updated, membership.UserID)
+	return evictUser(ctx, r.cache, membership.UserID)
+}
+
+func (r *evictingOrganizationRepository) RemoveMember(ctx context.Context, orgID, userID uuid.UUID) error {
+	if err := r.OrganizationRepository.RemoveMember(ctx, orgID, userID); err != nil {
+		return err
+	}
+	*r.updated = append(*r.updated, userID)
+	return evictUser(ctx, r.cache, userID)
+}
+
+func evictUser(ctx context.Context, c cache.Cache, id uuid.UUID) error {
+	if err := c.Delete(ctx, userCacheKey(id)); err != nil {
+		return fmt.Errorf("evict cached user: %w", err)
+	}
+	return nil
+}
+
+func userCacheKey(id uuid.UUID) string {
+	return "user:" + id.String()
+}
@@ -0,0 +1,201 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/cache"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository"
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository/mocks"
+	"github.com/kevinmarcellius/go-simple-auth/internal/tenant"
+)
+
+// failingCache fails every operation, like an unreachable Redis.
+type failingCache struct{}
+
+func (failingCache) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, errors.New("cache down")
+}
+func (failingCache) Set(context.Context, string, []byte, time.Duration) error {
+	return errors.New("cache down")
+}
+func (failingCache) Add(context.Context, string, []byte, time.Duration) (bool, error) {
+	return false, errors.New("cache down")
+}
+func (failingCache) Delete(context.Context, ...string) error { return errors.New("cache down") }
+
+func withRole(user model.User, role string) model.User {
+	user.OrgRole = role
+	return user
+}
+
+// tenantIs matches contexts carrying t.
+func tenantIs(t tenant.Tenant) gomock.Matcher {
+	return gomock.Cond(func(x any) bool {
+		ctx, ok := x.(context.Context)
+		if !ok {
+			return false
+		}
+		got, ok := tenant.FromContext(ctx)
+		return ok && got == t
+	})
+}
+
+func TestCachedUserRepository(t *testing.T) {
+	disabledAt := time.Now().UTC().Truncate(time.Second)
+	user := model.User{
+		ID:           uuid.New(),
+		Username:     "alice",
+		Email:        "alice@example.com",
+		PasswordHash: "$2a$10$hash",
+		DisabledAt:   &disabledAt,
+	}
+	// what lookups by ID return, without credentials
+	public := user
+	public.PasswordHash = ""
+	ctx := context.Background()
+
+	t.Run("Serves Repeated Lookups From Cache", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		next := mocks.NewMockUserRepository(ctrl)
+		next.EXPECT().FindUserByID(gomock.Any(), user.ID).Return(user, nil).Times(1)
+		repo := repository.NewCachedUserRepository(next, cache.NewLRU(10), time.Minute)
+
+		for i := 0; i < 2; i++ {
+			found, err := repo.FindUserByID(ctx, user.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, public, found)
+		}
+	})
+
+	t.Run("Does Not Cache Credentials", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		next := mocks.NewMockUserRepository(ctrl)
+		next.EXPECT().FindUserByID(gomock.Any(), user.ID).Return(user, nil)
+		c := cache.NewLRU(10)
+		repo := repository.NewCachedUserRepository(next, c, time.Minute)
+
+		_, err := repo.FindUserByID(ctx, user.ID)
+		assert.NoError(t, err)
+		data, found, err := c.Get(ctx, "user:"+user.ID.String())
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.NotContains(t, string(data), user.PasswordHash)
+	})
+
+	t.Run("Does Not Cache Errors", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		next := mocks.NewMockUserRepository(ctrl)
+		next.EXPECT().FindUserByID(gomock.Any(), user.ID).Return(model.User{}, gorm.ErrRecordNotFound).Times(2)
+		repo := repository.NewCachedUserRepository(next, cache.NewLRU(10), time.Minute)
+
+		for i := 0; i < 2; i++ {
+			_, err := repo.FindUserByID(ctx, user.ID)
+			assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		}
+	})
+
+	t.Run("Caches Role Per Tenant", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		next := mocks.NewMockUserRepository(ctrl)
+		acme := tenant.Tenant{ID: uuid.New(), Slug: "acme"}
+		initech := tenant.Tenant{ID: uuid.New(), Slug: "initech"}
+		next.EXPECT().FindUserByID(tenantIs(acme), user.ID).Return(withRole(user, model.OrgRoleOwner), nil).Times(1)
+		next.EXPECT().FindUserByID(tenantIs(initech), user.ID).Return(withRole(user, model.OrgRoleMember), nil).Times(1)
+		repo := repository.NewCachedUserRepository(next, cache.NewLRU(10), time.Minute)
+
+		for i := 0; i < 2; i++ {
+			found, err := repo.FindUserByID(tenant.WithTenant(ctx, acme), user.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, model.OrgRoleOwner, found.OrgRole)
+			found, err = repo.FindUserByID(tenant.WithTenant(ctx, initech), user.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, model.OrgRoleMember, found.OrgRole)
+		}
+	})
+
+	t.Run("Update Evicts", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		next := mocks.NewMockUserRepository(ctrl)
+		next.EXPECT().FindUserByID(gomock.Any(), user.ID).Return(user, nil).Times(2)
+		next.EXPECT().UpdateUserById(gomock.Any(), user.ID, model.User{IsAdmin: true}).Return(nil)
+		repo := repository.NewCachedUserRepository(next, cache.NewLRU(10), time.Minute)
+
+		repo.FindUserByID(ctx, user.ID)
+		assert.NoError(t, repo.UpdateUserById(ctx, user.ID, model.User{IsAdmin: true}))
+		repo.FindUserByID(ctx, user.ID)
+	})
+
+	t.Run("Unavailable Cache Falls Back", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		next := mocks.NewMockUserRepository(ctrl)
+		next.EXPECT().FindUserByID(gomock.Any(), user.ID).Return(user, nil)
+		repo := repository.NewCachedUserRepository(next, failingCache{}, time.Minute)
+
+		found, err := repo.FindUserByID(ctx, user.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, public, found)
+	})
+}
+
+func TestCachedTxManager(t *testing.T) {
+	user := model.User{ID: uuid.New(), Username: "alice"}
+	ctx := context.Background()
+
+	t.Run("Update In Transaction Evicts", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		users := mocks.NewMockUserRepository(ctrl)
+		users.EXPECT().FindUserByID(gomock.Any(), user.ID).Return(user, nil).Times(2)
+		users.EXPECT().UpdateUserById(gomock.Any(), user.ID, gomock.Any()).Return(nil)
+
+		c := cache.NewLRU(10)
+		repo := repository.NewCachedUserRepository(users, c, time.Minute)
+		txManager := repository.NewCachedTxManager(mocks.TxManager{Repos: repository.Repositories{Users: users}}, c)
+
+		repo.FindUserByID(ctx, user.ID)
+		err := txManager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+			return repos.Users.UpdateUserById(ctx, user.ID, model.User{IsAdmin: true})
+		})
+		assert.NoError(t, err)
+		repo.FindUserByID(ctx, user.ID)
+	})
+
+	t.Run("Membership Removal Evicts", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		users := mocks.NewMockUserRepository(ctrl)
+		users.EXPECT().FindUserByID(gomock.Any(), user.ID).Return(user, nil).Times(2)
+		orgs := mocks.NewMockOrganizationRepository(ctrl)
+		orgs.EXPECT().RemoveMember(gomock.Any(), model.DefaultOrganizationID, user.ID).Return(nil)
+
+		c := cache.NewLRU(10)
+		repo := repository.NewCachedUserRepository(users, c, time.Minute)
+		txManager := repository.NewCachedTxManager(mocks.TxManager{Repos: repository.Repositories{Users: users, Organizations: orgs}}, c)
+
+		repo.FindUserByID(ctx, user.ID)
+		err := txManager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+			return repos.Organizations.RemoveMember(ctx, model.DefaultOrganizationID, user.ID)
+		})
+		assert.NoError(t, err)
+		repo.FindUserByID(ctx, user.ID)
+	})
+
+	t.Run("Failed Eviction Fails The Transaction", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		users := mocks.NewMockUserRepository(ctrl)
+		users.EXPECT().UpdateUserById(gomock.Any(), user.ID, gomock.Any()).Return(nil)
+		txManager := repository.NewCachedTxManager(mocks.TxManager{Repos: repository.Repositories{Users: users}}, failingCache{})
+
+		err := txManager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+			return repos.Users.UpdateUserById(ctx, user.ID, model.User{IsAdmin: true})
+		})
+		assert.ErrorContains(t, err, "evict cached user")
+	})
+}
@@ -0,0 +1,28 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository"
+)
+
+func TestUserRepository_UpdateUserById_NotFound(t *testing.T) {
+	manager, mock := newMockTxManager(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "go_user"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err := manager.WithinTransaction(context.Background(), func(ctx context.Context, repos repository.Repositories) error {
+		return repos.Users.UpdateUserById(ctx, uuid.New(), model.User{IsAdmin: true})
+	})
+
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
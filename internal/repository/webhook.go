@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WebhookRepository holds the service-wide webhook subscriptions and their
+// outbox. It ignores the tenant in the context: subscriptions are managed
+// by service admins and receive the events of all organizations.
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, sub model.WebhookSubscription) error
+	ListSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id uuid.UUID) error
+
+	// Enqueue adds event to the outbox once for every subscription to its
+	// type. Call it with the repositories of the transaction making the
+	// change the event reports.
+	Enqueue(ctx context.Context, event model.WebhookEvent) error
+	// ClaimDue returns up to limit pending messages due at now, with their
+	// subscriptions, and pushes their next attempt lease into the future so
+	// concurrent workers skip them while they are being delivered.
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookMessage, error)
+	// RecordAttempt saves the outcome of a delivery attempt: the message's
+	// new status and schedule, and the attempt in the delivery log.
+	RecordAttempt(ctx context.Context, msg model.WebhookMessage, delivery model.WebhookDelivery) error
+	ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]model.WebhookDelivery, error)
+}
+
+type webhookRepository struct {
+	dbConn
+}
+
+func NewWebhookRepository(db *gorm.DB, queryTimeout time.Duration) WebhookRepository {
+	return &webhookRepository{dbConn{db: db, queryTimeout: queryTimeout}}
+}
+
+func (r *webhookRepository) CreateSubscription(ctx context.Context, sub model.WebhookSubscription) error {
+	ctx, span := tracer.Start(ctx, "webhookRepository.CreateSubscription")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	return db.Create(&sub).Error
+}
+
+func (r *webhookRepository) ListSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	ctx, span := tracer.Start(ctx, "webhookRepository.ListSubscriptions")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	var subs []model.WebhookSubscription
+	err := db.Order("created_at").Find(&subs).Error
+	return subs, err
+}
+
+func (r *webhookRepository) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	ctx, span := tracer.Start(ctx, "webhookRepository.DeleteSubscription")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	result := db.Delete(&model.WebhookSubscription{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *webhookRepository) Enqueue(ctx context.Context, event model.WebhookEvent) error {
+	ctx, span := tracer.Start(ctx, "webhookRepository.Enqueue")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	eventType, err := json.Marshal([]string{event.Type})
+	if err != nil {
+		return err
+	}
+	var subs []model.WebhookSubscription
+	if err := db.Where("event_types @> ?::jsonb", string(eventType)).Find(&subs).Error; err != nil {
+		return err
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	msgs := make([]model.WebhookMessage, len(subs))
+	for i, sub := range subs {
+		msgs[i] = model.WebhookMessage{
+			ID:             uuid.New(),
+			SubscriptionID: sub.ID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Payload:        string(payload),
+			Status:         model.WebhookPending,
+			NextAttemptAt:  event.OccurredAt,
+		}
+	}
+	return db.Create(&msgs).Error
+}
+
+func (r *webhookRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookMessage, error) {
+	ctx, span := tracer.Start(ctx, "webhookRepository.ClaimDue")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	var msgs []model.WebhookMessage
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", model.WebhookPending, now).
+			Order("next_attempt_at").Limit(limit).Find(&msgs).Error
+		if err != nil || len(msgs) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(msgs))
+		for i, msg := range msgs {
+			ids[i] = msg.ID
+		}
+		return tx.Model(&model.WebhookMessage{}).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil || len(msgs) == 0 {
+		return nil, err
+	}
+
+	subIDs := make([]uuid.UUID, 0, len(msgs))
+	for _, msg := range msgs {
+		subIDs = append(subIDs, msg.SubscriptionID)
+	}
+	var subs []model.WebhookSubscription
+	if err := db.Where("id IN ?", subIDs).Find(&subs).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]*model.WebhookSubscription, len(subs))
+	for i := range subs {
+		byID[subs[i].ID] = &subs[i]
+	}
+	for i := range msgs {
+		msgs[i].Subscription = byID[msgs[i].SubscriptionID]
+	}
+	return msgs, nil
+}
+
+func (r *webhookRepository) RecordAttempt(ctx context.Context, msg model.WebhookMessage, delivery model.WebhookDelivery) error {
+	ctx, span := tracer.Start(ctx, "webhookRepository.RecordAttempt")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.WebhookMessage{}).Where("id = ?", msg.ID).Updates(map[string]any{
+			"status":          msg.Status,
+			"attempts":        msg.Attempts,
+			"next_attempt_at": msg.NextAttemptAt,
+			"last_error":      msg.LastError,
+			"delivered_at":    msg.DeliveredAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&delivery).Error
+	})
+}
+
+func (r *webhookRepository) ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]model.WebhookDelivery, error) {
+	ctx, span := tracer.Start(ctx, "webhookRepository.ListDeliveries")
+	defer span.End()
+
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	var deliveries []model.WebhookDelivery
+	err := db.Where("subscription_id = ?", subscriptionID).
+		Order("attempted_at DESC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
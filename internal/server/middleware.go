@@ -0,0 +1,112 @@
+// Package server holds the HTTP hardening shared by every route of the
+// public API: security headers, CORS, timeouts and panic recovery.
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/logging"
+)
+
+// Configure applies the connection timeouts of cfg to the server e starts.
+func Configure(e *echo.Echo, cfg config.HTTPConfig) {
+	e.Server.ReadTimeout = cfg.ReadTimeout
+	e.Server.ReadHeaderTimeout = cfg.ReadTimeout
+	e.Server.WriteTimeout = cfg.WriteTimeout
+	e.Server.IdleTimeout = cfg.IdleTimeout
+}
+
+// SecurityHeaders sets Content-Security-Policy, X-Frame-Options,
+// X-Content-Type-Options and Referrer-Policy on every response, and
+// Strict-Transport-Security on those served over HTTPS, directly or behind a
+// proxy setting X-Forwarded-Proto.
+func SecurityHeaders(cfg config.HTTPConfig) echo.MiddlewareFunc {
+	return middleware.SecureWithConfig(middleware.SecureConfig{
+		ContentTypeNosniff:    "nosniff",
+		XFrameOptions:         cfg.FrameOptions,
+		HSTSMaxAge:            int(cfg.HSTSMaxAge / time.Second),
+		ContentSecurityPolicy: cfg.ContentSecurityPolicy,
+		ReferrerPolicy:        "no-referrer",
+	})
+}
+
+// CORS answers preflight requests and sets the CORS headers for the allowed
+// origins. Without any it does nothing, so browsers only allow same-origin
+// calls.
+func CORS(cfg config.CORSConfig) echo.MiddlewareFunc {
+	if len(cfg.AllowOrigins) == 0 {
+		return passThrough
+	}
+	return middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOrigins:     cfg.AllowOrigins,
+		AllowCredentials: cfg.AllowCredentials,
+		ExposeHeaders:    []string{echo.HeaderXRequestID, echo.HeaderRetryAfter},
+		MaxAge:           int(cfg.MaxAge / time.Second),
+	})
+}
+
+// Timeout sets a deadline on each request's context, failing requests that
+// exceed it with 503. Zero disables it.
+func Timeout(timeout time.Duration) echo.MiddlewareFunc {
+	if timeout <= 0 {
+		return passThrough
+	}
+	return middleware.ContextTimeout(timeout)
+}
+
+// Recover turns a panic in a handler into a 500 problem+json response and
+// logs it with its stack to logger, instead of dropping the connection.
+func Recover(logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+				if r == http.ErrAbortHandler {
+					panic(r)
+				}
+				logging.FromContext(c.Request().Context(), logger).Error("panic serving request",
+					slog.Any("panic", r),
+					slog.String("stack", string(debug.Stack())),
+				)
+				if c.Response().Committed {
+					return
+				}
+				err = Problem(c, http.StatusInternalServerError)
+			}()
+			return next(c)
+		}
+	}
+}
+
+// problem is an RFC 9457 problem details body.
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Problem writes an application/problem+json response for status.
+func Problem(c echo.Context, status int) error {
+	c.Response().Header().Set(echo.HeaderContentType, "application/problem+json")
+	return c.JSON(status, problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+	})
+}
+
+func passThrough(next echo.HandlerFunc) echo.HandlerFunc {
+	return next
+}
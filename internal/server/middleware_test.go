@@ -0,0 +1,113 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+)
+
+func TestRecover(t *testing.T) {
+	var logs bytes.Buffer
+	e := echo.New()
+	e.Use(Recover(slog.New(slog.NewTextHandler(&logs, nil))))
+	e.GET("/panic", func(c echo.Context) error {
+		panic("boom")
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get(echo.HeaderContentType))
+	var body problem
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, problem{Type: "about:blank", Title: "Internal Server Error", Status: 500}, body)
+	assert.Contains(t, logs.String(), "panic serving request")
+	assert.Contains(t, logs.String(), "panic=boom")
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	cfg := config.Default().HTTP
+	e := echo.New()
+	e.Use(SecurityHeaders(cfg))
+	e.GET("/", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	testCases := []struct {
+		name         string
+		forwardProto string
+		expectedHSTS string
+	}{
+		{name: "HTTP", expectedHSTS: ""},
+		{name: "HTTPS Behind Proxy", forwardProto: "https", expectedHSTS: "max-age=31536000; includeSubdomains"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.forwardProto != "" {
+				req.Header.Set(echo.HeaderXForwardedProto, tc.forwardProto)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, "DENY", rec.Header().Get(echo.HeaderXFrameOptions))
+			assert.Equal(t, "nosniff", rec.Header().Get(echo.HeaderXContentTypeOptions))
+			assert.Equal(t, cfg.ContentSecurityPolicy, rec.Header().Get(echo.HeaderContentSecurityPolicy))
+			assert.Equal(t, tc.expectedHSTS, rec.Header().Get(echo.HeaderStrictTransportSecurity))
+		})
+	}
+}
+
+func TestCORS(t *testing.T) {
+	testCases := []struct {
+		name                string
+		cfg                 config.CORSConfig
+		origin              string
+		expectedAllowOrigin string
+		expectedCredentials string
+	}{
+		{name: "Disabled", origin: "https://app.example.com"},
+		{
+			name:                "Allowed Origin",
+			cfg:                 config.CORSConfig{AllowOrigins: []string{"https://app.example.com"}, AllowCredentials: true},
+			origin:              "https://app.example.com",
+			expectedAllowOrigin: "https://app.example.com",
+			expectedCredentials: "true",
+		},
+		{
+			name:   "Other Origin",
+			cfg:    config.CORSConfig{AllowOrigins: []string{"https://app.example.com"}},
+			origin: "https://evil.example.com",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			e.Use(CORS(tc.cfg))
+			e.POST("/", func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodOptions, "/", nil)
+			req.Header.Set(echo.HeaderOrigin, tc.origin)
+			req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodPost)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedAllowOrigin, rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+			assert.Equal(t, tc.expectedCredentials, rec.Header().Get(echo.HeaderAccessControlAllowCredentials))
+		})
+	}
+}
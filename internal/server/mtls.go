@@ -0,0 +1,87 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/labstack/echo/v4"
+)
+
+// clientKey is the echo.Context key of the authenticated client's name.
+const clientKey = "mtls_client"
+
+// LoadClientCAs reads a PEM bundle of CA certificates.
+func LoadClientCAs(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
+	return pool, nil
+}
+
+// RequireClient lets requests through only if the verified client
+// certificate's subject, as its common name or in full, is one of allowed.
+// The TLS handshake has already checked the certificate chains to a trusted
+// CA; this decides which of those clients may call.
+func RequireClient(allowed []string) echo.MiddlewareFunc {
+	set := make(map[string]bool, len(allowed))
+	for _, a := range allowed {
+		set[a] = true
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			state := c.Request().TLS
+			if state == nil || len(state.VerifiedChains) == 0 {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Client certificate required"})
+			}
+			subject := state.VerifiedChains[0][0].Subject
+			switch {
+			case set[subject.CommonName]:
+				c.Set(clientKey, subject.CommonName)
+			case set[subject.String()]:
+				c.Set(clientKey, subject.String())
+			default:
+				return c.JSON(http.StatusForbidden, map[string]string{"error": "Client not allowed"})
+			}
+			return next(c)
+		}
+	}
+}
+
+// Client returns the name of the client authenticated by RequireClient.
+func Client(c echo.Context) string {
+	name, _ := c.Get(clientKey).(string)
+	return name
+}
+
+// ServeInternal listens on addr and serves e there with tlsConfig in the
+// background. It returns the error if addr can't be bound, so startup fails
+// rather than running without the internal endpoints; errors serving later
+// are logged. The caller shuts e down.
+func ServeInternal(e *echo.Echo, addr string, tlsConfig *tls.Config, logger *slog.Logger) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", addr, err)
+	}
+	e.Server.Addr = addr
+	e.Server.TLSConfig = tlsConfig
+	e.TLSListener = tls.NewListener(listener, tlsConfig)
+
+	logger.Info("serving internal endpoints", slog.String("addr", listener.Addr().String()))
+	go func() {
+		if err := e.StartServer(e.Server); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("internal server failed", slog.Any("error", err))
+		}
+	}()
+	return nil
+}
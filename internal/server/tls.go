@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+)
+
+// Certificates serves the certificate configured by config.TLSConfig,
+// reloading it from disk when the files change.
+type Certificates struct {
+	certFile, keyFile string
+	interval          time.Duration
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modified time.Time
+}
+
+// NewCertificates loads the certificate files of cfg, or generates a
+// self-signed certificate if cfg asks for one.
+func NewCertificates(cfg config.TLSConfig) (*Certificates, error) {
+	c := &Certificates{certFile: cfg.CertFile, keyFile: cfg.KeyFile, interval: cfg.ReloadInterval}
+	if cfg.SelfSigned {
+		cert, err := SelfSigned([]string{"localhost", "127.0.0.1", "::1"}, 24*time.Hour)
+		if err != nil {
+			return nil, err
+		}
+		c.cert = &cert
+		return c, nil
+	}
+	if _, err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate is the tls.Config callback returning the current
+// certificate.
+func (c *Certificates) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// TLSConfig returns a server configuration using the current certificate.
+func (c *Certificates) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: c.GetCertificate,
+	}
+}
+
+// MutualTLSConfig is TLSConfig requiring client certificates issued by
+// clientCAs.
+func (c *Certificates) MutualTLSConfig(clientCAs *x509.CertPool) *tls.Config {
+	cfg := c.TLSConfig()
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	cfg.ClientCAs = clientCAs
+	return cfg
+}
+
+// Watch reloads the certificate whenever its files change, until ctx is
+// done. A certificate that fails to load is logged and the previous one kept
+// in use, so a half-written renewal doesn't take the server down.
+func (c *Certificates) Watch(ctx context.Context) {
+	if c.certFile == "" {
+		return
+	}
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := c.reload()
+			if err != nil {
+				slog.Error("failed to reload TLS certificate", slog.String("cert_file", c.certFile), slog.Any("error", err))
+			} else if reloaded {
+				slog.Info("reloaded TLS certificate", slog.String("cert_file", c.certFile))
+			}
+		}
+	}
+}
+
+// reload loads the certificate if either file changed since the last load,
+// reporting whether it did.
+func (c *Certificates) reload() (bool, error) {
+	modified, err := latestModTime(c.certFile, c.keyFile)
+	if err != nil {
+		return false, err
+	}
+	c.mu.RLock()
+	unchanged := c.cert != nil && modified.Equal(c.modified)
+	c.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("load TLS certificate: %w", err)
+	}
+	c.mu.Lock()
+	c.cert, c.modified = &cert, modified
+	c.mu.Unlock()
+	return true, nil
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// SelfSigned generates a certificate for hosts, names or IP addresses,
+// valid for validity. Clients won't trust it, so it is only meant for
+// development and tests.
+func SelfSigned(hosts []string, validity time.Duration) (tls.Certificate, error) {
+	if len(hosts) == 0 {
+		return tls.Certificate{}, errors.New("self-signed certificate needs at least one host")
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: hosts[0], Organization: []string{"go-simple-auth development"}},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
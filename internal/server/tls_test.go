@@ -0,0 +1,136 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+)
+
+// writeCert writes a new self-signed certificate for host to certFile and
+// keyFile, stamped with modTime.
+func writeCert(t *testing.T, certFile, keyFile, host string, modTime time.Time) {
+	t.Helper()
+	cert, err := SelfSigned([]string{host}, time.Hour)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0o600))
+	assert.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	assert.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+}
+
+func leafName(t *testing.T, c *Certificates) string {
+	t.Helper()
+	cert, err := c.GetCertificate(nil)
+	assert.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertificates_Reload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	start := time.Now().Add(-time.Hour)
+	writeCert(t, certFile, keyFile, "old.example.com", start)
+
+	certs, err := NewCertificates(config.TLSConfig{CertFile: certFile, KeyFile: keyFile, ReloadInterval: time.Minute})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "old.example.com", leafName(t, certs))
+
+	// unchanged files aren't reloaded
+	reloaded, err := certs.reload()
+	assert.NoError(t, err)
+	assert.False(t, reloaded)
+
+	writeCert(t, certFile, keyFile, "new.example.com", start.Add(time.Minute))
+	reloaded, err = certs.reload()
+	assert.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, "new.example.com", leafName(t, certs))
+
+	// a broken renewal keeps the previous certificate
+	assert.NoError(t, os.WriteFile(keyFile, []byte("garbage"), 0o600))
+	_, err = certs.reload()
+	assert.Error(t, err)
+	assert.Equal(t, "new.example.com", leafName(t, certs))
+}
+
+func TestNewCertificates_SelfSigned(t *testing.T) {
+	certs, err := NewCertificates(config.TLSConfig{SelfSigned: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+	cert, err := certs.GetCertificate(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"localhost"}, cert.Leaf.DNSNames)
+	assert.Len(t, cert.Leaf.IPAddresses, 2)
+}
+
+func TestRequireClient(t *testing.T) {
+	clientCert := func(subject pkix.Name) *tls.ConnectionState {
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: subject}}}}
+	}
+
+	testCases := []struct {
+		name         string
+		state        *tls.ConnectionState
+		expectedCode int
+		expectedName string
+	}{
+		{name: "No Certificate", expectedCode: http.StatusUnauthorized},
+		{name: "Allowed Common Name", state: clientCert(pkix.Name{CommonName: "billing"}), expectedCode: http.StatusOK, expectedName: "billing"},
+		{name: "Allowed Subject", state: clientCert(pkix.Name{CommonName: "reports", Organization: []string{"Example"}}), expectedCode: http.StatusOK, expectedName: "CN=reports,O=Example"},
+		{name: "Other Client", state: clientCert(pkix.Name{CommonName: "reports"}), expectedCode: http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.TLS = tc.state
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+
+			err := RequireClient([]string{"billing", "CN=reports,O=Example"})(func(c echo.Context) error {
+				assert.Equal(t, tc.expectedName, Client(c))
+				return c.NoContent(http.StatusOK)
+			})(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+		})
+	}
+}
+
+func TestServeInternal_AddressInUse(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer taken.Close()
+
+	e := echo.New()
+	err = ServeInternal(e, taken.Addr().String(), &tls.Config{}, slog.New(slog.DiscardHandler))
+	assert.ErrorContains(t, err, "listen on "+taken.Addr().String())
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository"
+	"github.com/kevinmarcellius/go-simple-auth/internal/tracing"
+)
+
+const (
+	DefaultAuditPageSize = 50
+	MaxAuditPageSize     = 500
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type AuditService interface {
+	// ListEvents returns a page of events matching filter, newest first.
+	// cursor is the NextCursor of the previous page, or "" for the first.
+	ListEvents(ctx context.Context, filter model.AuditFilter, cursor string) (model.AuditPage, error)
+}
+
+type auditService struct {
+	auditRepo repository.AuditRepository
+}
+
+func NewAuditService(auditRepo repository.AuditRepository) AuditService {
+	return &auditService{auditRepo: auditRepo}
+}
+
+func (s *auditService) ListEvents(ctx context.Context, filter model.AuditFilter, cursor string) (_ model.AuditPage, err error) {
+	ctx, span := tracer.Start(ctx, "auditService.ListEvents")
+	defer func() { tracing.End(span, err) }()
+
+	if cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return model.AuditPage{}, err
+		}
+		filter.After = &after
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultAuditPageSize
+	}
+	filter.Limit = min(filter.Limit, MaxAuditPageSize)
+
+	// fetch one extra row to learn whether another page follows
+	limit := filter.Limit
+	filter.Limit++
+	events, err := s.auditRepo.ListEvents(ctx, filter)
+	if err != nil {
+		return model.AuditPage{}, err
+	}
+
+	page := model.AuditPage{Events: events}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		last := page.Events[limit-1]
+		page.NextCursor = encodeCursor(model.AuditCursor{OccurredAt: last.OccurredAt, ID: last.ID})
+	}
+	if page.Events == nil {
+		page.Events = []model.AuditEvent{}
+	}
+	return page, nil
+}
+
+// Cursors are opaque to clients: base64url of "<RFC 3339 time>|<event ID>".
+func encodeCursor(c model.AuditCursor) string {
+	raw := c.OccurredAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (model.AuditCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return model.AuditCursor{}, ErrInvalidCursor
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return model.AuditCursor{}, ErrInvalidCursor
+	}
+	occurredAt, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return model.AuditCursor{}, ErrInvalidCursor
+	}
+	eventID, err := uuid.Parse(id)
+	if err != nil {
+		return model.AuditCursor{}, ErrInvalidCursor
+	}
+	return model.AuditCursor{OccurredAt: occurredAt, ID: eventID}, nil
+}
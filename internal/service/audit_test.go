@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/audit"
+	"github.com/kevinmarcellius/go-simple-auth/internal/auth"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository/mocks"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+type sinkFunc func(ctx context.Context, event model.AuditEvent) error
+
+func (f sinkFunc) Write(ctx context.Context, event model.AuditEvent) error {
+	return f(ctx, event)
+}
+
+func TestUserService_Audit(t *testing.T) {
+	password := "password123"
+	hashedPassword, _ := utils.HashPassword(password)
+	testUser := model.User{ID: uuid.New(), Email: "test@mail.id", PasswordHash: hashedPassword}
+	adminID := uuid.New()
+
+	testCases := []struct {
+		name     string
+		mockRepo func(mock *mocks.MockUserRepository)
+		run      func(ctx context.Context, s UserService) error
+		check    func(t *testing.T, event model.AuditEvent)
+	}{
+		{
+			name: "Login Success",
+			mockRepo: func(mock *mocks.MockUserRepository) {
+				mock.EXPECT().GetUserByEmail(gomock.Any(), "test@mail.id").Return(testUser, nil)
+			},
+			run: func(ctx context.Context, s UserService) error {
+				_, err := s.Login(ctx, model.LoginRequest{Email: "test@mail.id", Password: password})
+				return err
+			},
+			check: func(t *testing.T, event model.AuditEvent) {
+				assert.Equal(t, model.AuditLogin, event.Action)
+				assert.Equal(t, model.AuditSuccess, event.Outcome)
+				assert.Equal(t, &testUser.ID, event.ActorID)
+				assert.Equal(t, &testUser.ID, event.TargetID)
+			},
+		},
+		{
+			name: "Login Wrong Password",
+			mockRepo: func(mock *mocks.MockUserRepository) {
+				mock.EXPECT().GetUserByEmail(gomock.Any(), "test@mail.id").Return(testUser, nil)
+			},
+			run: func(ctx context.Context, s UserService) error {
+				_, err := s.Login(ctx, model.LoginRequest{Email: "test@mail.id", Password: "wrong"})
+				return err
+			},
+			check: func(t *testing.T, event model.AuditEvent) {
+				assert.Equal(t, model.AuditFailure, event.Outcome)
+				assert.Equal(t, "invalid_password", event.Reason)
+				assert.Equal(t, &testUser.ID, event.TargetID)
+			},
+		},
+		{
+			name: "Login Unknown Email",
+			mockRepo: func(mock *mocks.MockUserRepository) {
+				mock.EXPECT().GetUserByEmail(gomock.Any(), "nobody@mail.id").Return(model.User{}, gorm.ErrRecordNotFound)
+			},
+			run: func(ctx context.Context, s UserService) error {
+				_, err := s.Login(ctx, model.LoginRequest{Email: "nobody@mail.id", Password: password})
+				return err
+			},
+			check: func(t *testing.T, event model.AuditEvent) {
+				assert.Equal(t, "user_not_found", event.Reason)
+				assert.Nil(t, event.TargetID)
+				assert.Equal(t, "nobody@mail.id", event.TargetEmail)
+			},
+		},
+		{
+			name: "Password Change Rejected",
+			mockRepo: func(mock *mocks.MockUserRepository) {
+				mock.EXPECT().FindUserByID(gomock.Any(), testUser.ID).Return(testUser, nil)
+			},
+			run: func(ctx context.Context, s UserService) error {
+				return s.UpdatePassword(ctx, testUser.ID.String(), model.UpdatePasswordRequest{OldPassword: "wrong", NewPassword: "newpassword"})
+			},
+			check: func(t *testing.T, event model.AuditEvent) {
+				assert.Equal(t, model.AuditPasswordChange, event.Action)
+				assert.Equal(t, model.AuditFailure, event.Outcome)
+				assert.Equal(t, &testUser.ID, event.ActorID)
+			},
+		},
+		{
+			name: "Operator Disable",
+			mockRepo: func(mock *mocks.MockUserRepository) {
+				mock.EXPECT().ListUsersByEmail(gomock.Any(), "test@mail.id", 2).Return([]model.User{testUser}, nil)
+				mock.EXPECT().UpdateUserById(gomock.Any(), testUser.ID, gomock.Any()).Return(nil)
+			},
+			run: func(ctx context.Context, s UserService) error {
+				return s.DisableUser(ctx, "test@mail.id")
+			},
+			check: func(t *testing.T, event model.AuditEvent) {
+				assert.Equal(t, model.AuditUserDisable, event.Action)
+				assert.Equal(t, model.AuditSuccess, event.Outcome)
+				assert.Nil(t, event.ActorID)
+				assert.Equal(t, &testUser.ID, event.TargetID)
+			},
+		},
+		{
+			name: "Disable By Authenticated Admin",
+			mockRepo: func(mock *mocks.MockUserRepository) {
+				mock.EXPECT().ListUsersByEmail(gomock.Any(), "test@mail.id", 2).Return([]model.User{testUser}, nil)
+				mock.EXPECT().UpdateUserById(gomock.Any(), testUser.ID, gomock.Any()).Return(nil)
+			},
+			run: func(ctx context.Context, s UserService) error {
+				return s.DisableUser(auth.WithPrincipal(ctx, auth.Principal{UserID: adminID, IsAdmin: true}), "test@mail.id")
+			},
+			check: func(t *testing.T, event model.AuditEvent) {
+				assert.Equal(t, model.AuditUserDisable, event.Action)
+				assert.Equal(t, &adminID, event.ActorID)
+				assert.Equal(t, &testUser.ID, event.TargetID)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockUserRepo := mocks.NewMockUserRepository(ctrl)
+			tc.mockRepo(mockUserRepo)
+
+			var stored, published []model.AuditEvent
+			mockAuditRepo := mocks.NewMockAuditRepository(ctrl)
+			mockAuditRepo.EXPECT().CreateEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, event model.AuditEvent) error {
+				stored = append(stored, event)
+				return nil
+			}).AnyTimes()
+			sink := sinkFunc(func(ctx context.Context, event model.AuditEvent) error {
+				published = append(published, event)
+				return nil
+			})
+
+			txManager := newTxManager(ctrl, mockUserRepo)
+			txManager.Repos.Audit = mockAuditRepo
+			userService := NewUserService(mockUserRepo, txManager, testJWT, WithAuditSinks(sink))
+
+			ctx := audit.WithRequestInfo(context.Background(), audit.RequestInfo{IP: "203.0.113.7", UserAgent: "curl/8.0", RequestID: "req-1"})
+			tc.run(ctx, userService)
+
+			if assert.Len(t, stored, 1) {
+				event := stored[0]
+				assert.Equal(t, "203.0.113.7", event.IP)
+				assert.Equal(t, "curl/8.0", event.UserAgent)
+				assert.Equal(t, "req-1", event.RequestID)
+				tc.check(t, event)
+			}
+			assert.Equal(t, stored, published)
+		})
+	}
+}
+
+func TestAuditService_ListEvents(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	events := make([]model.AuditEvent, 3)
+	for i := range events {
+		events[i] = model.AuditEvent{ID: uuid.New(), OccurredAt: now.Add(-time.Duration(i) * time.Minute)}
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAuditRepo := mocks.NewMockAuditRepository(ctrl)
+	auditService := NewAuditService(mockAuditRepo)
+
+	// first page: one row more than the limit is fetched
+	mockAuditRepo.EXPECT().ListEvents(gomock.Any(), model.AuditFilter{Action: model.AuditLogin, Limit: 3}).Return(events, nil)
+	page, err := auditService.ListEvents(context.Background(), model.AuditFilter{Action: model.AuditLogin, Limit: 2}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, events[:2], page.Events)
+	assert.NotEmpty(t, page.NextCursor)
+
+	// second page resumes after the last event of the first
+	after := &model.AuditCursor{OccurredAt: events[1].OccurredAt, ID: events[1].ID}
+	mockAuditRepo.EXPECT().ListEvents(gomock.Any(), model.AuditFilter{Action: model.AuditLogin, Limit: 3, After: after}).Return(events[2:], nil)
+	page, err = auditService.ListEvents(context.Background(), model.AuditFilter{Action: model.AuditLogin, Limit: 2}, page.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, events[2:], page.Events)
+	assert.Empty(t, page.NextCursor)
+
+	_, err = auditService.ListEvents(context.Background(), model.AuditFilter{}, "not-a-cursor")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/metrics"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+func observeLogin(err error) {
+	metrics.Logins.WithLabelValues(outcome(err), failureReason(err)).Inc()
+}
+
+func observeRefresh(err error) {
+	metrics.TokenRefreshes.WithLabelValues(outcome(err), failureReason(err)).Inc()
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// failureReason maps service errors to a small, fixed set of metric labels.
+func failureReason(err error) string {
+	switch {
+	case err == nil:
+		return "none"
+	case errors.Is(err, ErrInvalidInvitation):
+		// before invalid_token, which it may wrap
+		return "invalid_invitation"
+	case errors.Is(err, ErrInvalidMagicLink):
+		// before invalid_token and unknown_client, which it may wrap
+		return "invalid_magic_link"
+	case errors.Is(err, utils.ErrInvalidEmail):
+		return "invalid_email"
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return "user_not_found"
+	case errors.Is(err, utils.ErrInvalidPassword):
+		return "invalid_password"
+	case errors.Is(err, utils.ErrUserDisabled):
+		return "user_disabled"
+	case errors.Is(err, utils.ErrInvalidToken):
+		return "invalid_token"
+	case errors.Is(err, ErrUnknownClient):
+		return "unknown_client"
+	case errors.Is(err, ErrRegistrationClosed):
+		return "registration_closed"
+	case errors.Is(err, ErrCaptchaRequired):
+		return "captcha_required"
+	case errors.Is(err, utils.ErrEmailDomainNotAllowed):
+		return "email_domain_not_allowed"
+	case errors.Is(err, ErrForbidden):
+		return "forbidden"
+	case errors.Is(err, ErrAlreadyMember):
+		return "already_member"
+	case errors.Is(err, ErrEmailInOrganization):
+		return "email_in_organization"
+	case errors.Is(err, ErrAmbiguousUser):
+		return "ambiguous_user"
+	case errors.Is(err, ErrInvitationNotPending):
+		return "invitation_not_pending"
+	default:
+		return "error"
+	}
+}
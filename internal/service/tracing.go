@@ -0,0 +1,29 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/metrics"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+var tracer = otel.Tracer("github.com/kevinmarcellius/go-simple-auth/internal/service")
+
+// hashPassword and checkPassword trace and time bcrypt separately, as it
+// usually dominates login and password change latency.
+func hashPassword(ctx context.Context, password string) (string, error) {
+	_, span := tracer.Start(ctx, "bcrypt.GenerateFromPassword")
+	defer span.End()
+	defer metrics.ObservePasswordHash("hash", time.Now())
+	return utils.HashPassword(password)
+}
+
+func checkPassword(ctx context.Context, password, hash string) bool {
+	_, span := tracer.Start(ctx, "bcrypt.CompareHashAndPassword")
+	defer span.End()
+	defer metrics.ObservePasswordHash("compare", time.Now())
+	return utils.CheckPasswordHash(password, hash)
+}
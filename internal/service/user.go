@@ -2,35 +2,225 @@ package service
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/audit"
+	"github.com/kevinmarcellius/go-simple-auth/internal/cache"
+	"github.com/kevinmarcellius/go-simple-auth/internal/captcha"
+	"github.com/kevinmarcellius/go-simple-auth/internal/logging"
+	"github.com/kevinmarcellius/go-simple-auth/internal/mailer"
+	"github.com/kevinmarcellius/go-simple-auth/internal/metrics"
 	"github.com/kevinmarcellius/go-simple-auth/internal/model"
 	"github.com/kevinmarcellius/go-simple-auth/internal/repository"
+	"github.com/kevinmarcellius/go-simple-auth/internal/tenant"
+	"github.com/kevinmarcellius/go-simple-auth/internal/tracing"
 	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+	"gorm.io/gorm"
 )
 
+// ErrUnknownClient is returned when logging in with a client_id that has no
+// configured token lifetimes.
+var ErrUnknownClient = errors.New("unknown client")
+
+// ErrRegistrationClosed is returned by CreateUser when accounts can only be
+// created by accepting an invitation, or not at all.
+var ErrRegistrationClosed = errors.New("registration is closed")
+
+// ErrAmbiguousUser is returned by operator actions not scoped to an
+// organization when several users, registered in different organizations,
+// have the email.
+var ErrAmbiguousUser = errors.New("several users have this email, name their organization")
+
+// ErrEmailInOrganization is returned when a user would register in or join
+// an organization that has a member with the same email already.
+var ErrEmailInOrganization = errors.New("a member of the organization has this email")
+
+// ErrCaptchaRequired is returned when a request needs a solved captcha and
+// its token is missing or rejected.
+var ErrCaptchaRequired = errors.New("captcha required")
+
 type UserService interface {
 	CreateUser(ctx context.Context, req model.UserRequest) (model.UserResponse, error)
 	Login(ctx context.Context, req model.LoginRequest) (model.LoginResponse, error)
 	Refresh(ctx context.Context, req model.RefreshTokenRequest) (model.RefreshTokenResponse, error)
+	Logout(ctx context.Context, req model.RefreshTokenRequest) error
+	Introspect(ctx context.Context, token string) (model.IntrospectionResponse, error)
 	UpdatePassword(ctx context.Context, userID string, req model.UpdatePasswordRequest) error
+
+	// Operator actions, used by the CLI
+	PromoteUser(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, email string, newPassword string) error
+	DisableUser(ctx context.Context, email string) error
+	DeleteUser(ctx context.Context, email string) error
+	CreateOrganization(ctx context.Context, slug, name string) (model.Organization, error)
+	AddMember(ctx context.Context, slug, email, role string) error
+	RemoveMember(ctx context.Context, slug, email string) error
+
+	// Invitations to the organization of the request
+	CreateInvitation(ctx context.Context, req model.InvitationRequest) (model.InvitationResponse, error)
+	ListInvitations(ctx context.Context) ([]model.Invitation, error)
+	ResendInvitation(ctx context.Context, id uuid.UUID) (model.InvitationResponse, error)
+	RevokeInvitation(ctx context.Context, id uuid.UUID) error
+	AcceptInvitation(ctx context.Context, req model.AcceptInvitationRequest) (model.UserResponse, error)
+
+	// Passwordless login
+	RequestMagicLink(ctx context.Context, req model.MagicLinkRequest) error
+	VerifyMagicLink(ctx context.Context, req model.VerifyMagicLinkRequest) (model.LoginResponse, error)
 }
 
 type userService struct {
-	userRepo repository.UserRepository
-	jwtKey   string
+	userRepo     repository.UserRepository
+	txManager    repository.TxManager
+	jwt          utils.JWTOptions
+	tokens       config.TokenConfig
+	emailPolicy  utils.EmailPolicy
+	logger       *slog.Logger
+	auditSinks   []audit.Sink
+	denylist     cache.Denylist
+	tenancy      config.TenancyConfig
+	registration config.RegistrationConfig
+	// captcha is nil unless captchas are enabled. loginFailures counts the
+	// failed logins that make later ones need a captcha.
+	captcha       captcha.Verifier
+	captchaConfig config.CaptchaConfig
+	loginFailures cache.Failures
+	// mailer is nil unless magic links are enabled.
+	mailer    mailer.Sender
+	magicLink config.MagicLinkConfig
+}
+
+// Option configures optional userService behaviour.
+type Option func(*userService)
+
+// WithEmailPolicy sets the policy used to normalize emails on registration and login.
+func WithEmailPolicy(policy utils.EmailPolicy) Option {
+	return func(s *userService) {
+		s.emailPolicy = policy
+	}
+}
+
+// WithLogger sets the logger used when a request carries no logger of its own.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *userService) {
+		s.logger = logger
+	}
+}
+
+// WithAuditSinks copies every recorded audit event to sinks, in addition to
+// the audit_event table.
+func WithAuditSinks(sinks ...audit.Sink) Option {
+	return func(s *userService) {
+		s.auditSinks = append(s.auditSinks, sinks...)
+	}
+}
+
+// WithDenylist rejects refresh tokens whose ID has been revoked in denylist.
+func WithDenylist(denylist cache.Denylist) Option {
+	return func(s *userService) {
+		s.denylist = denylist
+	}
 }
 
-func NewUserService(userRepo repository.UserRepository, jwtKey string) UserService {
-	return &userService{userRepo: userRepo, jwtKey: jwtKey}
+// WithTokenConfig sets the token lifetimes, which default to those of
+// config.Default.
+func WithTokenConfig(tokens config.TokenConfig) Option {
+	return func(s *userService) {
+		s.tokens = tokens
+	}
+}
+
+// WithTenancy sets how usernames and emails are kept unique across
+// organizations, which defaults to config.Default.
+func WithTenancy(tenancy config.TenancyConfig) Option {
+	return func(s *userService) {
+		s.tenancy = tenancy
+	}
 }
 
-func (s *userService) CreateUser(ctx context.Context, req model.UserRequest) (model.UserResponse, error) {
-	log.Println("Create new user")
+// WithRegistration sets who may register and how invitations are issued,
+// which default to those of config.Default. Services for operator commands
+// are left open, so operators can create users in any mode.
+func WithRegistration(registration config.RegistrationConfig) Option {
+	return func(s *userService) {
+		s.registration = registration
+	}
+}
+
+// WithCaptcha asks for a captcha checked by verifier on registration and,
+// once failures counts cfg.LoginFailures failed logins to an account, on its
+// logins.
+func WithCaptcha(verifier captcha.Verifier, failures cache.Failures, cfg config.CaptchaConfig) Option {
+	return func(s *userService) {
+		s.captcha = verifier
+		s.loginFailures = failures
+		s.captchaConfig = cfg
+	}
+}
+
+// WithMagicLink enables login links, issued as set by magicLink and emailed
+// to users by sender.
+func WithMagicLink(sender mailer.Sender, magicLink config.MagicLinkConfig) Option {
+	return func(s *userService) {
+		s.mailer = sender
+		s.magicLink = magicLink
+	}
+}
+
+func NewUserService(userRepo repository.UserRepository, txManager repository.TxManager, jwtOpts utils.JWTOptions, opts ...Option) UserService {
+	s := &userService{userRepo: userRepo, txManager: txManager, jwt: jwtOpts, tokens: config.Default().Token, tenancy: config.Default().Tenancy, registration: config.Default().Registration, magicLink: config.Default().MagicLink, logger: slog.Default()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *userService) CreateUser(ctx context.Context, req model.UserRequest) (_ model.UserResponse, err error) {
+	ctx, span := tracer.Start(ctx, "userService.CreateUser")
+	defer func() { tracing.End(span, err) }()
+
+	event := newAuditEvent(ctx, model.AuditUserCreate)
+	event.TargetEmail = truncate(req.Email, maxAuditEmail)
+	defer func() { s.finishAudit(ctx, event, err) }()
+
+	switch s.registration.Mode {
+	case "invite_only":
+		return model.UserResponse{
+			Message: "Registration is by invitation only",
+		}, ErrRegistrationClosed
+	case "closed":
+		return model.UserResponse{
+			Message: "Registration is closed",
+		}, ErrRegistrationClosed
+	}
+
+	if err := s.verifyCaptcha(ctx, req.CaptchaToken); err != nil {
+		return model.UserResponse{
+			Message: "Captcha verification failed",
+		}, err
+	}
+
+	email, err := utils.NormalizeEmail(req.Email, s.emailPolicy)
+	if err != nil {
+		return model.UserResponse{
+			Message: "Invalid email",
+		}, err
+	}
+	event.TargetEmail = email
+
+	if err := s.domainPolicy().Check(email); err != nil {
+		return model.UserResponse{
+			Message: "Email domain is not allowed",
+		}, err
+	}
 
 	// create password hash here in real application
-	hashedPassword, err := utils.HashPassword(req.Password)
+	hashedPassword, err := hashPassword(ctx, req.Password)
 	if err != nil {
 		return model.UserResponse{
 			Message: "Password error",
@@ -38,40 +228,81 @@ func (s *userService) CreateUser(ctx context.Context, req model.UserRequest) (mo
 	}
 	req.Password = hashedPassword
 
-	newUser := model.User{
-		ID:           uuid.New(),
-		Username:     req.Username,
-		Email:        req.Email,
-		PasswordHash: hashedPassword,
-	}
+	newUser := s.newUser(ctx, req.Username, email, hashedPassword)
 
-	err = s.userRepo.CreateUser(newUser)
+	err = s.txManager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := checkEmailFree(ctx, repos.Users, email, newUser.ID); err != nil {
+			return err
+		}
+		if err := register(ctx, repos, newUser, model.OrgRoleMember); err != nil {
+			return err
+		}
+		// self-registration: the new user is both actor and target
+		event.ActorID, event.TargetID = &newUser.ID, &newUser.ID
+		return repos.Audit.CreateEvent(ctx, event)
+	})
 	if err != nil {
 		return model.UserResponse{
 			Message: "Failed to create user",
 		}, err
 	}
+	s.log(ctx).Info("user created", slog.String("user_id", newUser.ID.String()))
 
 	return model.UserResponse{
 		Message: "User created successfully",
 	}, nil
 }
 
-func (s *userService) Login(ctx context.Context, req model.LoginRequest) (model.LoginResponse, error) {
-	user, err := s.userRepo.GetUserByEmail(req.Email)
+func (s *userService) Login(ctx context.Context, req model.LoginRequest) (_ model.LoginResponse, err error) {
+	ctx, span := tracer.Start(ctx, "userService.Login")
+	defer func() { tracing.End(span, err) }()
+	defer func() { observeLogin(err) }()
+
+	event := newAuditEvent(ctx, model.AuditLogin)
+	defer func() { s.recordAudit(ctx, event, err) }()
+
+	if _, ok := s.tokens.Lifetimes(req.ClientID, ""); !ok {
+		event.TargetEmail = truncate(req.Email, maxAuditEmail)
+		return model.LoginResponse{}, ErrUnknownClient
+	}
+
+	failuresKey := s.loginFailuresKey(ctx, req.Email)
+	if err := s.checkLoginCaptcha(ctx, failuresKey, req.CaptchaToken); err != nil {
+		event.TargetEmail = truncate(req.Email, maxAuditEmail)
+		return model.LoginResponse{}, err
+	}
+	defer func() { s.countLoginFailure(ctx, failuresKey, err) }()
+
+	user, err := s.findByEmail(ctx, s.userRepo, req.Email)
 	if err != nil {
+		event.TargetEmail = truncate(req.Email, maxAuditEmail)
 		return model.LoginResponse{}, err
 	}
-	log.Println("User found:", user.Email)
+	event.ActorID, event.TargetID = &user.ID, &user.ID
+	logger := s.log(ctx).With(slog.String("user_id", user.ID.String()))
 
-	if !utils.CheckPasswordHash(req.Password, user.PasswordHash) {
-		log.Println("Invalid password for user:", user.Email)
+	if !checkPassword(ctx, req.Password, user.PasswordHash) {
+		logger.Info("login rejected", slog.String("reason", "invalid_password"))
 		return model.LoginResponse{}, utils.ErrInvalidPassword
 	}
-	
-	log.Println("Password verified for user:", user.Email)
 
-	accessToken, refreshToken, err := utils.GenerateJWT(user, s.jwtKey)
+	if user.DisabledAt != nil {
+		logger.Info("login rejected", slog.String("reason", "user_disabled"))
+		return model.LoginResponse{}, utils.ErrUserDisabled
+	}
+	logger.Debug("password verified")
+
+	lifetimes, _ := s.tokens.Lifetimes(req.ClientID, userRole(user))
+	org := requestTenant(ctx)
+	accessToken, refreshToken, err := utils.GenerateJWT(user, s.jwt, utils.Session{
+		ClientID:   req.ClientID,
+		RememberMe: req.RememberMe,
+		AuthTime:   time.Now(),
+		Lifetimes:  lifetimes,
+		OrgID:      org.ID,
+		Org:        org.Slug,
+		OrgRole:    user.OrgRole,
+	})
 	if err != nil {
 		return model.LoginResponse{}, err
 	}
@@ -82,58 +313,428 @@ func (s *userService) Login(ctx context.Context, req model.LoginRequest) (model.
 	}, nil
 }
 
-func (s *userService) Refresh(ctx context.Context, req model.RefreshTokenRequest) (model.RefreshTokenResponse, error) {
-	claims, err := utils.ValidateRefreshToken(req.RefreshToken, s.jwtKey)
+func (s *userService) Refresh(ctx context.Context, req model.RefreshTokenRequest) (_ model.RefreshTokenResponse, err error) {
+	ctx, span := tracer.Start(ctx, "userService.Refresh")
+	defer func() { tracing.End(span, err) }()
+	defer func() { observeRefresh(err) }()
+
+	event := newAuditEvent(ctx, model.AuditTokenRefresh)
+	defer func() { s.recordAudit(ctx, event, err) }()
+
+	claims, err := utils.ValidateRefreshToken(req.RefreshToken, s.jwt)
 	if err != nil {
 		return model.RefreshTokenResponse{}, err
 	}
+	if s.denylist != nil {
+		revoked, err := s.denylist.IsRevoked(ctx, claims.ID)
+		if err != nil {
+			return model.RefreshTokenResponse{}, err
+		}
+		if revoked {
+			return model.RefreshTokenResponse{}, fmt.Errorf("%w: token revoked", utils.ErrInvalidToken)
+		}
+	}
 
 	userID, err := uuid.Parse(claims.UserID)
 	if err != nil {
 		return model.RefreshTokenResponse{}, err
 	}
+	event.ActorID, event.TargetID = &userID, &userID
 
-	user, err := s.userRepo.FindUserByID(userID)
+	session, err := claims.Session()
 	if err != nil {
 		return model.RefreshTokenResponse{}, err
 	}
+	if t, ok := tenant.FromContext(ctx); ok && t.ID != session.OrgID {
+		return model.RefreshTokenResponse{}, fmt.Errorf("%w: issued for another organization", utils.ErrInvalidToken)
+	}
 
-	accessToken, err := utils.GenerateNewAccessToken(user, s.jwtKey)
+	// users removed from the organization are no longer found in it
+	ctx = tenant.WithTenant(ctx, tenant.Tenant{ID: session.OrgID, Slug: session.Org})
+	user, err := s.userRepo.FindUserByID(ctx, userID)
+	if err != nil {
+		return model.RefreshTokenResponse{}, err
+	}
+	if user.DisabledAt != nil {
+		return model.RefreshTokenResponse{}, utils.ErrUserDisabled
+	}
+
+	// the roles are the user's current ones, so a demoted admin gets user
+	// lifetimes
+	session.OrgRole = user.OrgRole
+	lifetimes, ok := s.tokens.Lifetimes(session.ClientID, userRole(user))
+	if !ok {
+		return model.RefreshTokenResponse{}, fmt.Errorf("%w: %w", utils.ErrInvalidToken, ErrUnknownClient)
+	}
+	session.Lifetimes = lifetimes
+
+	// the new refresh token replaces the presented one, which is retired
+	// so a stolen copy can't be replayed. Retiring it is what claims it, so
+	// of concurrent refreshes with one token only one gets new tokens.
+	if s.denylist != nil {
+		retired, err := s.denylist.RevokeOnce(ctx, claims.ID, claims.ExpiresAt.Time)
+		if err != nil {
+			return model.RefreshTokenResponse{}, err
+		}
+		if !retired {
+			return model.RefreshTokenResponse{}, fmt.Errorf("%w: token revoked", utils.ErrInvalidToken)
+		}
+	}
+	accessToken, refreshToken, err := utils.GenerateJWT(user, s.jwt, session)
 	if err != nil {
 		return model.RefreshTokenResponse{}, err
 	}
 
 	return model.RefreshTokenResponse{
-		AccessToken: accessToken,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
 	}, nil
 }
 
-func (s *userService) UpdatePassword(ctx context.Context, userID string, req model.UpdatePasswordRequest) error {
+// Logout ends the session of a refresh token by revoking it. Without a
+// denylist it only checks the token, and the session lasts until it expires.
+func (s *userService) Logout(ctx context.Context, req model.RefreshTokenRequest) (err error) {
+	ctx, span := tracer.Start(ctx, "userService.Logout")
+	defer func() { tracing.End(span, err) }()
+
+	event := newAuditEvent(ctx, model.AuditLogout)
+	defer func() { s.recordAudit(ctx, event, err) }()
+
+	claims, err := utils.ValidateRefreshToken(req.RefreshToken, s.jwt)
+	if err != nil {
+		return err
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return err
+	}
+	event.ActorID, event.TargetID = &userID, &userID
+
+	if s.denylist == nil {
+		return nil
+	}
+	if err := s.denylist.Revoke(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return err
+	}
+	metrics.TokenRevocations.WithLabelValues("logout").Inc()
+	return nil
+}
+
+// Introspect reports whether token is an active access or refresh token and
+// whose it is. Invalid tokens are inactive rather than an error; errors are
+// only returned when the answer can't be known.
+func (s *userService) Introspect(ctx context.Context, token string) (_ model.IntrospectionResponse, err error) {
+	ctx, span := tracer.Start(ctx, "userService.Introspect")
+	defer func() { tracing.End(span, err) }()
+
+	var res model.IntrospectionResponse
+	var claims *jwt.RegisteredClaims
+	var orgID uuid.UUID
+	if access, err := utils.ValidateAccessToken(token, s.jwt); err == nil {
+		res.TokenType, res.ClientID, res.Org, claims = utils.TokenTypeAccess, access.ClientID, access.Org, &access.RegisteredClaims
+		if orgID, err = access.OrgID(); err != nil {
+			return model.IntrospectionResponse{}, nil
+		}
+	} else if refresh, err := utils.ValidateRefreshToken(token, s.jwt); err == nil {
+		res.TokenType, res.ClientID, res.Org, claims = utils.TokenTypeRefresh, refresh.ClientID, refresh.Org, &refresh.RegisteredClaims
+		if orgID, err = utils.OrgID(refresh.TenantID); err != nil {
+			return model.IntrospectionResponse{}, nil
+		}
+		if s.denylist != nil {
+			revoked, err := s.denylist.IsRevoked(ctx, claims.ID)
+			if err != nil {
+				return model.IntrospectionResponse{}, err
+			}
+			if revoked {
+				return model.IntrospectionResponse{}, nil
+			}
+		}
+	} else {
+		return model.IntrospectionResponse{}, nil
+	}
+
+	if res.Org == "" && orgID == model.DefaultOrganizationID {
+		res.Org = model.DefaultOrganizationSlug
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return model.IntrospectionResponse{}, nil
+	}
+	// tokens of users no longer in the organization are inactive
+	user, err := s.userRepo.FindUserByID(tenant.WithTenant(ctx, tenant.Tenant{ID: orgID, Slug: res.Org}), userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.IntrospectionResponse{}, nil
+	}
+	if err != nil {
+		return model.IntrospectionResponse{}, err
+	}
+	if user.DisabledAt != nil {
+		return model.IntrospectionResponse{}, nil
+	}
+
+	res.Active = true
+	res.Subject = user.ID.String()
+	res.Username = user.Username
+	res.IsAdmin = user.IsAdmin
+	res.TenantID = orgID.String()
+	res.OrgRole = user.OrgRole
+	res.TokenID = claims.ID
+	res.IssuedAt = claims.IssuedAt.Unix()
+	res.ExpiresAt = claims.ExpiresAt.Unix()
+	return res, nil
+}
+
+func (s *userService) UpdatePassword(ctx context.Context, userID string, req model.UpdatePasswordRequest) (err error) {
+	ctx, span := tracer.Start(ctx, "userService.UpdatePassword")
+	defer func() { tracing.End(span, err) }()
+
 	// Implement password update logic here
 	// get user by email
+	event := newAuditEvent(ctx, model.AuditPasswordChange)
+	defer func() { s.finishAudit(ctx, event, err) }()
+
 	uuid, err := uuid.Parse(userID)
 	if err != nil {
 		return err
 	}
-	user, err := s.userRepo.FindUserByID(uuid)
+	event.ActorID, event.TargetID = &uuid, &uuid
+
+	// hash new password before the transaction so bcrypt does not hold it open
+	hashedPassword, err := hashPassword(ctx, req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		user, err := repos.Users.FindUserByID(ctx, uuid)
+		if err != nil {
+			return err
+		}
+
+		// check old password
+		if !checkPassword(ctx, req.OldPassword, user.PasswordHash) {
+			return utils.ErrInvalidPassword
+		}
+		// update user password
+		user.PasswordHash = hashedPassword
+		if err := repos.Users.UpdateUserById(ctx, user.ID, user); err != nil {
+			return err
+		}
+		if err := repos.Audit.CreateEvent(ctx, event); err != nil {
+			return err
+		}
+		return enqueueUserEvent(ctx, repos, model.WebhookUserPasswordChanged, user)
+	})
+}
+
+func (s *userService) PromoteUser(ctx context.Context, email string) (err error) {
+	ctx, span := tracer.Start(ctx, "userService.PromoteUser")
+	defer func() { tracing.End(span, err) }()
+
+	event := newAuditEvent(ctx, model.AuditUserPromote)
+	defer func() { s.finishAudit(ctx, event, err) }()
+
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		user, err := s.findTarget(ctx, repos.Users, email, &event)
+		if err != nil {
+			return err
+		}
+		if err := repos.Users.UpdateUserById(ctx, user.ID, model.User{IsAdmin: true}); err != nil {
+			return err
+		}
+		return repos.Audit.CreateEvent(ctx, event)
+	})
+}
+
+func (s *userService) ResetPassword(ctx context.Context, email string, newPassword string) (err error) {
+	ctx, span := tracer.Start(ctx, "userService.ResetPassword")
+	defer func() { tracing.End(span, err) }()
+
+	event := newAuditEvent(ctx, model.AuditPasswordReset)
+	defer func() { s.finishAudit(ctx, event, err) }()
+
+	hashedPassword, err := hashPassword(ctx, newPassword)
 	if err != nil {
 		return err
 	}
 
-	// check old password
-	if !utils.CheckPasswordHash(req.OldPassword, user.PasswordHash) {
-		return utils.ErrInvalidPassword
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		user, err := s.findTarget(ctx, repos.Users, email, &event)
+		if err != nil {
+			return err
+		}
+		if err := repos.Users.UpdateUserById(ctx, user.ID, model.User{PasswordHash: hashedPassword}); err != nil {
+			return err
+		}
+		if err := repos.Audit.CreateEvent(ctx, event); err != nil {
+			return err
+		}
+		return enqueueUserEvent(ctx, repos, model.WebhookUserPasswordChanged, user)
+	})
+}
+
+func (s *userService) DisableUser(ctx context.Context, email string) (err error) {
+	ctx, span := tracer.Start(ctx, "userService.DisableUser")
+	defer func() { tracing.End(span, err) }()
+
+	event := newAuditEvent(ctx, model.AuditUserDisable)
+	defer func() { s.finishAudit(ctx, event, err) }()
+
+	err = s.txManager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		user, err := s.findTarget(ctx, repos.Users, email, &event)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := repos.Users.UpdateUserById(ctx, user.ID, model.User{DisabledAt: &now}); err != nil {
+			return err
+		}
+		if err := repos.Audit.CreateEvent(ctx, event); err != nil {
+			return err
+		}
+		return enqueueUserEvent(ctx, repos, model.WebhookUserDisabled, user)
+	})
+	if err != nil {
+		return err
 	}
-	// hash new password
-	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	// disabled users can no longer refresh, which ends all of their sessions
+	metrics.TokenRevocations.WithLabelValues("user_disabled").Inc()
+	return nil
+}
+
+// DeleteUser soft-deletes the user, which ends all of their sessions like
+// DisableUser does. The username and email stay taken.
+func (s *userService) DeleteUser(ctx context.Context, email string) (err error) {
+	ctx, span := tracer.Start(ctx, "userService.DeleteUser")
+	defer func() { tracing.End(span, err) }()
+
+	event := newAuditEvent(ctx, model.AuditUserDelete)
+	defer func() { s.finishAudit(ctx, event, err) }()
+
+	err = s.txManager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		user, err := s.findTarget(ctx, repos.Users, email, &event)
+		if err != nil {
+			return err
+		}
+		if err := repos.Users.DeleteUser(ctx, user.ID); err != nil {
+			return err
+		}
+		if err := repos.Audit.CreateEvent(ctx, event); err != nil {
+			return err
+		}
+		return enqueueUserEvent(ctx, repos, model.WebhookUserDeleted, user)
+	})
 	if err != nil {
 		return err
 	}
-	// update user password
-	user.PasswordHash = hashedPassword
-	err = s.userRepo.UpdateUserById(user.ID, user)
+	metrics.TokenRevocations.WithLabelValues("user_deleted").Inc()
+	return nil
+}
+
+// newUser returns a user registering in the organization of the request.
+func (s *userService) newUser(ctx context.Context, username, email, passwordHash string) model.User {
+	user := model.User{
+		ID:           uuid.New(),
+		Username:     utils.NormalizeUsername(username),
+		Email:        email,
+		PasswordHash: passwordHash,
+	}
+	if s.tenancy.Uniqueness == "tenant" {
+		user.UniquenessScope = requestTenant(ctx).ID
+	}
+	return user
+}
+
+// register creates user as a member of the organization of the request
+// with role, and announces them to webhook subscribers.
+func register(ctx context.Context, repos repository.Repositories, user model.User, role string) error {
+	if err := repos.Users.CreateUser(ctx, user); err != nil {
+		return err
+	}
+	membership := model.Membership{OrgID: requestTenant(ctx).ID, UserID: user.ID, Role: role}
+	if err := repos.Organizations.SetMember(ctx, membership); err != nil {
+		return err
+	}
+	return enqueueUserEvent(ctx, repos, model.WebhookUserRegistered, user)
+}
+
+// requestTenant returns the organization of the request, which is the
+// default one outside tenant-scoped routes.
+func requestTenant(ctx context.Context) tenant.Tenant {
+	if t, ok := tenant.FromContext(ctx); ok {
+		return t
+	}
+	return tenant.Default
+}
+
+func userRole(user model.User) string {
+	if user.IsAdmin {
+		return config.RoleAdmin
+	}
+	return config.RoleUser
+}
+
+// log returns the request's logger, falling back to the injected one.
+func (s *userService) log(ctx context.Context) *slog.Logger {
+	return logging.FromContext(ctx, s.logger)
+}
+
+// findTarget looks up the user an operator action applies to and records
+// them as the event's target. Without an organization in ctx all users are
+// searched, and ErrAmbiguousUser is returned if several have email rather
+// than acting on any one of them.
+func (s *userService) findTarget(ctx context.Context, users repository.UserRepository, email string, event *model.AuditEvent) (model.User, error) {
+	event.TargetEmail = truncate(email, maxAuditEmail)
+	var user model.User
+	if _, ok := tenant.FromContext(ctx); ok {
+		var err error
+		if user, err = s.findByEmail(ctx, users, email); err != nil {
+			return model.User{}, err
+		}
+	} else {
+		normalized, err := utils.NormalizeEmail(email, s.emailPolicy)
+		if err != nil {
+			return model.User{}, err
+		}
+		matches, err := users.ListUsersByEmail(ctx, normalized, 2)
+		switch {
+		case err != nil:
+			return model.User{}, err
+		case len(matches) == 0:
+			return model.User{}, gorm.ErrRecordNotFound
+		case len(matches) > 1:
+			return model.User{}, ErrAmbiguousUser
+		}
+		user = matches[0]
+	}
+	event.TargetID = &user.ID
+	return user, nil
+}
+
+// checkEmailFree returns ErrEmailInOrganization if a member of the
+// organization in ctx other than userID has email. Logins look users up by
+// email among the members, so there must be one at most; with
+// TENANCY_UNIQUENESS=tenant a user registered in one organization could
+// otherwise join another where someone registered with the same email.
+func checkEmailFree(ctx context.Context, users repository.UserRepository, email string, userID uuid.UUID) error {
+	member, err := users.GetUserByEmail(ctx, email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
+	if member.ID != userID {
+		return ErrEmailInOrganization
+	}
 	return nil
 }
+
+func (s *userService) findByEmail(ctx context.Context, users repository.UserRepository, email string) (model.User, error) {
+	email, err := utils.NormalizeEmail(email, s.emailPolicy)
+	if err != nil {
+		return model.User{}, err
+	}
+	return users.GetUserByEmail(ctx, email)
+}
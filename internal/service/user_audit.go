@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/audit"
+	"github.com/kevinmarcellius/go-simple-auth/internal/auth"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository"
+)
+
+// Column limits of audit_event; request headers are client controlled.
+const (
+	maxAuditUserAgent = 512
+	maxAuditRequestID = 128
+	maxAuditEmail     = 255
+)
+
+// newAuditEvent starts a successful event for action, filled in with the
+// details of the HTTP request in ctx and its authenticated user, if any.
+func newAuditEvent(ctx context.Context, action string) model.AuditEvent {
+	info := audit.RequestInfoFromContext(ctx)
+	var actorID *uuid.UUID
+	if p, ok := auth.FromContext(ctx); ok {
+		actorID = &p.UserID
+	}
+	return model.AuditEvent{
+		ID: uuid.New(),
+		// Postgres keeps microseconds; match it so cursors round-trip
+		OccurredAt: time.Now().UTC().Truncate(time.Microsecond),
+		Action:     action,
+		Outcome:    model.AuditSuccess,
+		IP:         info.IP,
+		UserAgent:  truncate(info.UserAgent, maxAuditUserAgent),
+		RequestID:  truncate(info.RequestID, maxAuditRequestID),
+		ActorID:    actorID,
+	}
+}
+
+// recordAudit stores event with the outcome of err on its own. It is used
+// for events that change no data, such as logins, and for failures, whose
+// transaction has been rolled back. A failure to record is logged rather
+// than returned so the audited operation reports its own result.
+func (s *userService) recordAudit(ctx context.Context, event model.AuditEvent, err error) {
+	if err != nil {
+		event.Outcome = model.AuditFailure
+		event.Reason = failureReason(err)
+	}
+	werr := s.txManager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		return repos.Audit.CreateEvent(ctx, event)
+	})
+	if werr != nil {
+		s.log(ctx).Error("failed to record audit event", slog.String("action", event.Action), slog.Any("error", werr))
+		return
+	}
+	s.publishAudit(ctx, event)
+}
+
+// finishAudit completes an operation whose success event was written in its
+// own transaction: the committed event is copied to the sinks, or, when the
+// transaction failed, a failure event is recorded instead.
+func (s *userService) finishAudit(ctx context.Context, event model.AuditEvent, err error) {
+	if err != nil {
+		s.recordAudit(ctx, event, err)
+		return
+	}
+	s.publishAudit(ctx, event)
+}
+
+func (s *userService) publishAudit(ctx context.Context, event model.AuditEvent) {
+	for _, sink := range s.auditSinks {
+		if err := sink.Write(ctx, event); err != nil {
+			s.log(ctx).Error("failed to write audit event to sink", slog.String("action", event.Action), slog.Any("error", err))
+		}
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	// don't cut a multi-byte character in half
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
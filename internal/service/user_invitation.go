@@ -0,0 +1,341 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/auth"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository"
+	"github.com/kevinmarcellius/go-simple-auth/internal/tenant"
+	"github.com/kevinmarcellius/go-simple-auth/internal/tracing"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+var (
+	// ErrForbidden is returned when the caller may not manage the
+	// invitation, e.g. an organization admin inviting an owner.
+	ErrForbidden = errors.New("forbidden")
+	// ErrInvalidInvitation is returned when accepting with a token that is
+	// invalid, expired, superseded by a resend, or for an invitation that is
+	// no longer pending.
+	ErrInvalidInvitation = errors.New("invalid invitation")
+	// ErrInvitationNotPending is returned when resending or revoking an
+	// accepted or revoked invitation.
+	ErrInvitationNotPending = errors.New("invitation is no longer pending")
+	// ErrAlreadyMember is returned when accepting an invitation to an
+	// organization the invitee is a member of already.
+	ErrAlreadyMember = errors.New("already a member")
+	// ErrUsernameRequired is returned when accepting an invitation creates
+	// an account but no username was given.
+	ErrUsernameRequired = errors.New("username is required")
+)
+
+// maxInvitations bounds ListInvitations.
+const maxInvitations = 100
+
+// CreateInvitation invites req.Email to the organization of the request.
+// Service admins may invite anyone, organization owners anyone but service
+// admins, and organization admins admins and members.
+func (s *userService) CreateInvitation(ctx context.Context, req model.InvitationRequest) (_ model.InvitationResponse, err error) {
+	ctx, span := tracer.Start(ctx, "userService.CreateInvitation")
+	defer func() { tracing.End(span, err) }()
+
+	event := newAuditEvent(ctx, model.AuditInvitationCreate)
+	event.TargetEmail = truncate(req.Email, maxAuditEmail)
+	defer func() { s.finishAudit(ctx, event, err) }()
+
+	if req.Role == "" {
+		req.Role = model.OrgRoleMember
+	}
+	if !slices.Contains(model.OrgRoles, req.Role) {
+		return model.InvitationResponse{}, ErrInvalidOrgRole
+	}
+	if !canInvite(ctx, req.Role, req.IsAdmin) {
+		return model.InvitationResponse{}, ErrForbidden
+	}
+	email, err := utils.NormalizeEmail(req.Email, s.emailPolicy)
+	if err != nil {
+		return model.InvitationResponse{}, err
+	}
+	event.TargetEmail = email
+
+	inv := model.Invitation{
+		ID:        uuid.New(),
+		OrgID:     requestTenant(ctx).ID,
+		Email:     email,
+		Role:      req.Role,
+		IsAdmin:   req.IsAdmin,
+		InvitedBy: event.ActorID,
+		TokenID:   uuid.New(),
+		ExpiresAt: time.Now().Add(s.registration.InvitationTTL),
+	}
+	token, err := utils.GenerateInvitationToken(inv, s.jwt)
+	if err != nil {
+		return model.InvitationResponse{}, err
+	}
+
+	err = s.txManager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := repos.Invitations.CreateInvitation(ctx, inv); err != nil {
+			return err
+		}
+		return repos.Audit.CreateEvent(ctx, event)
+	})
+	if err != nil {
+		return model.InvitationResponse{}, err
+	}
+	return s.invitationResponse(inv, token), nil
+}
+
+// ListInvitations returns the latest invitations to the organization of the
+// request, to those who may invite members.
+func (s *userService) ListInvitations(ctx context.Context) (_ []model.Invitation, err error) {
+	ctx, span := tracer.Start(ctx, "userService.ListInvitations")
+	defer func() { tracing.End(span, err) }()
+
+	if !canInvite(ctx, model.OrgRoleMember, false) {
+		return nil, ErrForbidden
+	}
+	var invs []model.Invitation
+	err = s.txManager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		var err error
+		invs, err = repos.Invitations.ListInvitations(ctx, requestTenant(ctx).ID, maxInvitations)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range invs {
+		invs[i].Status = invs[i].StatusAt(now)
+	}
+	return invs, nil
+}
+
+// ResendInvitation issues a new token for a pending or expired invitation,
+// valid for a full InvitationTTL again. Earlier tokens stop working.
+func (s *userService) ResendInvitation(ctx context.Context, id uuid.UUID) (_ model.InvitationResponse, err error) {
+	ctx, span := tracer.Start(ctx, "userService.ResendInvitation")
+	defer func() { tracing.End(span, err) }()
+
+	event := newAuditEvent(ctx, model.AuditInvitationResend)
+	defer func() { s.finishAudit(ctx, event, err) }()
+
+	var inv model.Invitation
+	var token string
+	err = s.txManager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		var err error
+		inv, err = s.findInvitation(ctx, repos, id, &event)
+		if err != nil {
+			return err
+		}
+		oldTokenID := inv.TokenID
+		inv.TokenID = uuid.New()
+		inv.ExpiresAt = time.Now().Add(s.registration.InvitationTTL)
+		if token, err = utils.GenerateInvitationToken(inv, s.jwt); err != nil {
+			return err
+		}
+		err = repos.Invitations.UpdatePending(ctx, inv.ID, oldTokenID, model.Invitation{TokenID: inv.TokenID, ExpiresAt: inv.ExpiresAt})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvitationNotPending
+		}
+		if err != nil {
+			return err
+		}
+		return repos.Audit.CreateEvent(ctx, event)
+	})
+	if err != nil {
+		return model.InvitationResponse{}, err
+	}
+	return s.invitationResponse(inv, token), nil
+}
+
+func (s *userService) RevokeInvitation(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := tracer.Start(ctx, "userService.RevokeInvitation")
+	defer func() { tracing.End(span, err) }()
+
+	event := newAuditEvent(ctx, model.AuditInvitationRevoke)
+	defer func() { s.finishAudit(ctx, event, err) }()
+
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		inv, err := s.findInvitation(ctx, repos, id, &event)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		err = repos.Invitations.UpdatePending(ctx, inv.ID, inv.TokenID, model.Invitation{RevokedAt: &now})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvitationNotPending
+		}
+		if err != nil {
+			return err
+		}
+		return repos.Audit.CreateEvent(ctx, event)
+	})
+}
+
+// AcceptInvitation creates the invitee's account with the roles they were
+// invited with. When emails are unique across organizations and the invitee
+// has an account already, they join with it instead, proving it's theirs
+// with its password.
+func (s *userService) AcceptInvitation(ctx context.Context, req model.AcceptInvitationRequest) (_ model.UserResponse, err error) {
+	ctx, span := tracer.Start(ctx, "userService.AcceptInvitation")
+	defer func() { tracing.End(span, err) }()
+
+	event := newAuditEvent(ctx, model.AuditInvitationAccept)
+	defer func() { s.finishAudit(ctx, event, err) }()
+
+	claims, err := utils.ValidateInvitationToken(req.Token, s.jwt)
+	if err != nil {
+		return model.UserResponse{Message: "Invalid invitation"}, fmt.Errorf("%w: %w", ErrInvalidInvitation, err)
+	}
+	event.TargetEmail = truncate(claims.Email, maxAuditEmail)
+	id, idErr := uuid.Parse(claims.Subject)
+	tokenID, tokenErr := uuid.Parse(claims.ID)
+	org := requestTenant(ctx)
+	if idErr != nil || tokenErr != nil || claims.TenantID != org.ID.String() {
+		return model.UserResponse{Message: "Invalid invitation"}, ErrInvalidInvitation
+	}
+
+	var joined bool
+	err = s.txManager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		inv, err := repos.Invitations.FindInvitation(ctx, org.ID, id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidInvitation
+		}
+		if err != nil {
+			return err
+		}
+		if inv.TokenID != tokenID || inv.StatusAt(time.Now()) != model.InvitationPending {
+			return ErrInvalidInvitation
+		}
+
+		// lookups in ctx only see members of the organization
+		if _, err := repos.Users.GetUserByEmail(ctx, inv.Email); err == nil {
+			return ErrAlreadyMember
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		var user model.User
+		if s.tenancy.Uniqueness == "global" {
+			user, err = repos.Users.GetUserByEmail(tenant.WithoutTenant(ctx), inv.Email)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			joined = err == nil
+		}
+
+		if joined {
+			if !checkPassword(ctx, req.Password, user.PasswordHash) {
+				return utils.ErrInvalidPassword
+			}
+			if err := repos.Organizations.SetMember(ctx, model.Membership{OrgID: org.ID, UserID: user.ID, Role: inv.Role}); err != nil {
+				return err
+			}
+			// after joining, as updates in ctx only apply to members
+			if inv.IsAdmin && !user.IsAdmin {
+				if err := repos.Users.UpdateUserById(ctx, user.ID, model.User{IsAdmin: true}); err != nil {
+					return err
+				}
+			}
+		} else {
+			if s.registration.Mode == "closed" {
+				return ErrRegistrationClosed
+			}
+			if req.Username == "" {
+				return ErrUsernameRequired
+			}
+			hashedPassword, err := hashPassword(ctx, req.Password)
+			if err != nil {
+				return err
+			}
+			user = s.newUser(ctx, req.Username, inv.Email, hashedPassword)
+			user.IsAdmin = inv.IsAdmin
+			if err := register(ctx, repos, user, inv.Role); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		err = repos.Invitations.UpdatePending(ctx, inv.ID, inv.TokenID, model.Invitation{AcceptedAt: &now, UserID: &user.ID})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// accepted, revoked or resent concurrently
+			return ErrInvalidInvitation
+		}
+		if err != nil {
+			return err
+		}
+		event.ActorID, event.TargetID = &user.ID, &user.ID
+		return repos.Audit.CreateEvent(ctx, event)
+	})
+	if err != nil {
+		return model.UserResponse{Message: "Failed to accept invitation"}, err
+	}
+	if joined {
+		return model.UserResponse{Message: "Joined organization successfully"}, nil
+	}
+	return model.UserResponse{Message: "User created successfully"}, nil
+}
+
+// findInvitation looks up an invitation of the request's organization that
+// the caller may manage, and records its invitee as the event's target.
+func (s *userService) findInvitation(ctx context.Context, repos repository.Repositories, id uuid.UUID, event *model.AuditEvent) (model.Invitation, error) {
+	if !canInvite(ctx, model.OrgRoleMember, false) {
+		return model.Invitation{}, ErrForbidden
+	}
+	inv, err := repos.Invitations.FindInvitation(ctx, requestTenant(ctx).ID, id)
+	if err != nil {
+		return model.Invitation{}, err
+	}
+	event.TargetEmail = inv.Email
+	if !canInvite(ctx, inv.Role, inv.IsAdmin) {
+		return model.Invitation{}, ErrForbidden
+	}
+	return inv, nil
+}
+
+func (s *userService) invitationResponse(inv model.Invitation, token string) model.InvitationResponse {
+	inv.Status = inv.StatusAt(time.Now())
+	res := model.InvitationResponse{Invitation: inv, Token: token}
+	if s.registration.InvitationURL != "" {
+		res.AcceptURL = linkWithToken(s.registration.InvitationURL, token)
+	}
+	return res
+}
+
+// linkWithToken returns the page at rawURL, which config validation ensures
+// parses, with token as its token query parameter.
+func linkWithToken(rawURL, token string) string {
+	u, _ := url.Parse(rawURL)
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// canInvite reports whether the authenticated user may invite someone to
+// the organization of the request with role and, if isAdmin, as a service
+// admin.
+func canInvite(ctx context.Context, role string, isAdmin bool) bool {
+	p, ok := auth.FromContext(ctx)
+	switch {
+	case !ok:
+		return false
+	case p.IsAdmin:
+		return true
+	case isAdmin:
+		return false
+	case p.OrgRole == model.OrgRoleOwner:
+		return true
+	case p.OrgRole == model.OrgRoleAdmin:
+		return role != model.OrgRoleOwner
+	}
+	return false
+}
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/auth"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository/mocks"
+	"github.com/kevinmarcellius/go-simple-auth/internal/tenant"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+func TestUserService_CreateInvitation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		principal   auth.Principal
+		req         model.InvitationRequest
+		expectedErr error
+	}{
+		{name: "Owner Invites Member", principal: auth.Principal{OrgRole: model.OrgRoleOwner}, req: model.InvitationRequest{Email: "New@Example.com"}},
+		{name: "Owner Invites Owner", principal: auth.Principal{OrgRole: model.OrgRoleOwner}, req: model.InvitationRequest{Email: "new@example.com", Role: model.OrgRoleOwner}},
+		{name: "Admin Invites Owner", principal: auth.Principal{OrgRole: model.OrgRoleAdmin}, req: model.InvitationRequest{Email: "new@example.com", Role: model.OrgRoleOwner}, expectedErr: ErrForbidden},
+		{name: "Owner Invites Service Admin", principal: auth.Principal{OrgRole: model.OrgRoleOwner}, req: model.InvitationRequest{Email: "new@example.com", IsAdmin: true}, expectedErr: ErrForbidden},
+		{name: "Service Admin Invites Service Admin", principal: auth.Principal{IsAdmin: true}, req: model.InvitationRequest{Email: "new@example.com", IsAdmin: true}},
+		{name: "Member", principal: auth.Principal{OrgRole: model.OrgRoleMember}, req: model.InvitationRequest{Email: "new@example.com"}, expectedErr: ErrForbidden},
+		{name: "Invalid Role", principal: auth.Principal{IsAdmin: true}, req: model.InvitationRequest{Email: "new@example.com", Role: "superuser"}, expectedErr: ErrInvalidOrgRole},
+		{name: "Invalid Email", principal: auth.Principal{IsAdmin: true}, req: model.InvitationRequest{Email: "new@"}, expectedErr: utils.ErrInvalidEmail},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			var created model.Invitation
+			mockInvitationRepo := mocks.NewMockInvitationRepository(ctrl)
+			if tc.expectedErr == nil {
+				mockInvitationRepo.EXPECT().CreateInvitation(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, inv model.Invitation) error {
+					created = inv
+					return nil
+				})
+			}
+			mockUserRepo := mocks.NewMockUserRepository(ctrl)
+			txManager := newTxManager(ctrl, mockUserRepo)
+			txManager.Repos.Invitations = mockInvitationRepo
+			registration := config.Default().Registration
+			registration.InvitationURL = "https://app.example.com/invite?lang=en"
+			userService := NewUserService(mockUserRepo, txManager, testJWT, WithRegistration(registration))
+
+			tc.principal.UserID = uuid.New()
+			ctx := auth.WithPrincipal(tenant.WithTenant(context.Background(), testOrg), tc.principal)
+			res, err := userService.CreateInvitation(ctx, tc.req)
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "new@example.com", created.Email)
+			assert.Equal(t, testOrg.ID, created.OrgID)
+			assert.Equal(t, &tc.principal.UserID, created.InvitedBy)
+			assert.Equal(t, model.InvitationPending, res.Status)
+			assert.Equal(t, "https://app.example.com/invite?lang=en&token="+res.Token, res.AcceptURL)
+
+			claims, err := utils.ValidateInvitationToken(res.Token, testJWT)
+			assert.NoError(t, err)
+			assert.Equal(t, created.ID.String(), claims.Subject)
+			assert.Equal(t, created.TokenID.String(), claims.ID)
+			assert.WithinDuration(t, time.Now().Add(72*time.Hour), claims.ExpiresAt.Time, 2*time.Second)
+		})
+	}
+}
+
+func TestUserService_AcceptInvitation(t *testing.T) {
+	hashedPassword, _ := utils.HashPassword("password123")
+	existing := model.User{ID: uuid.New(), Email: "new@example.com", PasswordHash: hashedPassword}
+	pending := func() model.Invitation {
+		return model.Invitation{
+			ID:        uuid.New(),
+			OrgID:     testOrg.ID,
+			Email:     "new@example.com",
+			Role:      model.OrgRoleAdmin,
+			IsAdmin:   true,
+			TokenID:   uuid.New(),
+			ExpiresAt: time.Now().Add(time.Hour),
+		}
+	}
+	// tenantIs matches contexts scoped to testOrg, or to none
+	tenantIs := func(scoped bool) gomock.Matcher {
+		return gomock.Cond(func(ctx context.Context) bool {
+			_, ok := tenant.FromContext(ctx)
+			return ok == scoped
+		})
+	}
+
+	testCases := []struct {
+		name            string
+		inv             model.Invitation
+		token           func(inv model.Invitation) string
+		req             model.AcceptInvitationRequest
+		mockRepo        func(inv model.Invitation, users *mocks.MockUserRepository, invs *mocks.MockInvitationRepository, orgs *mocks.MockOrganizationRepository)
+		expectedMessage string
+		expectedErr     error
+	}{
+		{
+			name: "Creates User With Roles",
+			inv:  pending(),
+			req:  model.AcceptInvitationRequest{Username: "NewUser", Password: "password123"},
+			mockRepo: func(inv model.Invitation, users *mocks.MockUserRepository, invs *mocks.MockInvitationRepository, orgs *mocks.MockOrganizationRepository) {
+				invs.EXPECT().FindInvitation(gomock.Any(), testOrg.ID, inv.ID).Return(inv, nil)
+				users.EXPECT().GetUserByEmail(tenantIs(true), "new@example.com").Return(model.User{}, gorm.ErrRecordNotFound)
+				users.EXPECT().GetUserByEmail(tenantIs(false), "new@example.com").Return(model.User{}, gorm.ErrRecordNotFound)
+				var created model.User
+				users.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, user model.User) error {
+					assert.Equal(t, "newuser", user.Username)
+					assert.True(t, user.IsAdmin)
+					created = user
+					return nil
+				})
+				orgs.EXPECT().SetMember(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, m model.Membership) error {
+					assert.Equal(t, model.Membership{OrgID: testOrg.ID, UserID: created.ID, Role: model.OrgRoleAdmin}, m)
+					return nil
+				})
+				invs.EXPECT().UpdatePending(gomock.Any(), inv.ID, inv.TokenID, gomock.Any()).DoAndReturn(func(ctx context.Context, id, tokenID uuid.UUID, updates model.Invitation) error {
+					assert.NotNil(t, updates.AcceptedAt)
+					assert.Equal(t, &created.ID, updates.UserID)
+					return nil
+				})
+			},
+			expectedMessage: "User created successfully",
+		},
+		{
+			name: "Existing Account Joins As Admin",
+			inv:  pending(),
+			req:  model.AcceptInvitationRequest{Password: "password123"},
+			mockRepo: func(inv model.Invitation, users *mocks.MockUserRepository, invs *mocks.MockInvitationRepository, orgs *mocks.MockOrganizationRepository) {
+				invs.EXPECT().FindInvitation(gomock.Any(), testOrg.ID, inv.ID).Return(inv, nil)
+				users.EXPECT().GetUserByEmail(tenantIs(true), "new@example.com").Return(model.User{}, gorm.ErrRecordNotFound)
+				users.EXPECT().GetUserByEmail(tenantIs(false), "new@example.com").Return(existing, nil)
+				// the update is scoped to the organization, so it only
+				// matches the user once they are a member
+				gomock.InOrder(
+					orgs.EXPECT().SetMember(gomock.Any(), model.Membership{OrgID: testOrg.ID, UserID: existing.ID, Role: model.OrgRoleAdmin}).Return(nil),
+					users.EXPECT().UpdateUserById(tenantIs(true), existing.ID, model.User{IsAdmin: true}).Return(nil),
+				)
+				invs.EXPECT().UpdatePending(gomock.Any(), inv.ID, inv.TokenID, gomock.Any()).Return(nil)
+			},
+			expectedMessage: "Joined organization successfully",
+		},
+		{
+			name: "Admin Grant Fails",
+			inv:  pending(),
+			req:  model.AcceptInvitationRequest{Password: "password123"},
+			mockRepo: func(inv model.Invitation, users *mocks.MockUserRepository, invs *mocks.MockInvitationRepository, orgs *mocks.MockOrganizationRepository) {
+				invs.EXPECT().FindInvitation(gomock.Any(), testOrg.ID, inv.ID).Return(inv, nil)
+				users.EXPECT().GetUserByEmail(tenantIs(true), "new@example.com").Return(model.User{}, gorm.ErrRecordNotFound)
+				users.EXPECT().GetUserByEmail(tenantIs(false), "new@example.com").Return(existing, nil)
+				orgs.EXPECT().SetMember(gomock.Any(), gomock.Any()).Return(nil)
+				users.EXPECT().UpdateUserById(gomock.Any(), existing.ID, model.User{IsAdmin: true}).Return(gorm.ErrRecordNotFound)
+			},
+			expectedErr: gorm.ErrRecordNotFound,
+		},
+		{
+			name: "Existing Account Wrong Password",
+			inv:  pending(),
+			req:  model.AcceptInvitationRequest{Password: "wrongpassword"},
+			mockRepo: func(inv model.Invitation, users *mocks.MockUserRepository, invs *mocks.MockInvitationRepository, orgs *mocks.MockOrganizationRepository) {
+				invs.EXPECT().FindInvitation(gomock.Any(), testOrg.ID, inv.ID).Return(inv, nil)
+				users.EXPECT().GetUserByEmail(tenantIs(true), "new@example.com").Return(model.User{}, gorm.ErrRecordNotFound)
+				users.EXPECT().GetUserByEmail(tenantIs(false), "new@example.com").Return(existing, nil)
+			},
+			expectedErr: utils.ErrInvalidPassword,
+		},
+		{
+			name: "Already Member",
+			inv:  pending(),
+			req:  model.AcceptInvitationRequest{Password: "password123"},
+			mockRepo: func(inv model.Invitation, users *mocks.MockUserRepository, invs *mocks.MockInvitationRepository, orgs *mocks.MockOrganizationRepository) {
+				invs.EXPECT().FindInvitation(gomock.Any(), testOrg.ID, inv.ID).Return(inv, nil)
+				users.EXPECT().GetUserByEmail(tenantIs(true), "new@example.com").Return(existing, nil)
+			},
+			expectedErr: ErrAlreadyMember,
+		},
+		{
+			name: "Superseded By Resend",
+			inv:  pending(),
+			token: func(inv model.Invitation) string {
+				inv.TokenID = uuid.New()
+				token, _ := utils.GenerateInvitationToken(inv, testJWT)
+				return token
+			},
+			req: model.AcceptInvitationRequest{Username: "newuser", Password: "password123"},
+			mockRepo: func(inv model.Invitation, users *mocks.MockUserRepository, invs *mocks.MockInvitationRepository, orgs *mocks.MockOrganizationRepository) {
+				invs.EXPECT().FindInvitation(gomock.Any(), testOrg.ID, inv.ID).Return(inv, nil)
+			},
+			expectedErr: ErrInvalidInvitation,
+		},
+		{
+			name: "Revoked",
+			inv: func() model.Invitation {
+				inv := pending()
+				now := time.Now()
+				inv.RevokedAt = &now
+				return inv
+			}(),
+			req: model.AcceptInvitationRequest{Username: "newuser", Password: "password123"},
+			mockRepo: func(inv model.Invitation, users *mocks.MockUserRepository, invs *mocks.MockInvitationRepository, orgs *mocks.MockOrganizationRepository) {
+				invs.EXPECT().FindInvitation(gomock.Any(), testOrg.ID, inv.ID).Return(inv, nil)
+			},
+			expectedErr: ErrInvalidInvitation,
+		},
+		{
+			name: "Other Organization",
+			inv: func() model.Invitation {
+				inv := pending()
+				inv.OrgID = uuid.New()
+				return inv
+			}(),
+			req: model.AcceptInvitationRequest{Username: "newuser", Password: "password123"},
+			mockRepo: func(inv model.Invitation, users *mocks.MockUserRepository, invs *mocks.MockInvitationRepository, orgs *mocks.MockOrganizationRepository) {
+			},
+			expectedErr: ErrInvalidInvitation,
+		},
+		{
+			name: "Access Token",
+			inv:  pending(),
+			token: func(inv model.Invitation) string {
+				access, _, _ := utils.GenerateJWT(existing, testJWT, testSession())
+				return access
+			},
+			req: model.AcceptInvitationRequest{Username: "newuser", Password: "password123"},
+			mockRepo: func(inv model.Invitation, users *mocks.MockUserRepository, invs *mocks.MockInvitationRepository, orgs *mocks.MockOrganizationRepository) {
+			},
+			expectedErr: ErrInvalidInvitation,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockUserRepo := mocks.NewMockUserRepository(ctrl)
+			mockInvitationRepo := mocks.NewMockInvitationRepository(ctrl)
+			mockOrgRepo := mocks.NewMockOrganizationRepository(ctrl)
+			tc.mockRepo(tc.inv, mockUserRepo, mockInvitationRepo, mockOrgRepo)
+			txManager := newTxManager(ctrl, mockUserRepo)
+			txManager.Repos.Invitations = mockInvitationRepo
+			txManager.Repos.Organizations = mockOrgRepo
+			userService := NewUserService(mockUserRepo, txManager, testJWT)
+
+			req := tc.req
+			if tc.token != nil {
+				req.Token = tc.token(tc.inv)
+			} else {
+				req.Token, _ = utils.GenerateInvitationToken(tc.inv, testJWT)
+			}
+			res, err := userService.AcceptInvitation(tenant.WithTenant(context.Background(), testOrg), req)
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedMessage, res.Message)
+			}
+		})
+	}
+}
+
+func TestUserService_ManageInvitations(t *testing.T) {
+	owner := auth.Principal{UserID: uuid.New(), OrgRole: model.OrgRoleOwner}
+	inv := model.Invitation{ID: uuid.New(), OrgID: testOrg.ID, Email: "new@example.com", Role: model.OrgRoleMember, TokenID: uuid.New(), ExpiresAt: time.Now().Add(-time.Hour)}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockInvitationRepo := mocks.NewMockInvitationRepository(ctrl)
+	txManager := newTxManager(ctrl, mockUserRepo)
+	txManager.Repos.Invitations = mockInvitationRepo
+	userService := NewUserService(mockUserRepo, txManager, testJWT)
+	ctx := auth.WithPrincipal(tenant.WithTenant(context.Background(), testOrg), owner)
+
+	// resending an expired invitation issues a new token, superseding the old
+	mockInvitationRepo.EXPECT().FindInvitation(gomock.Any(), testOrg.ID, inv.ID).Return(inv, nil)
+	mockInvitationRepo.EXPECT().UpdatePending(gomock.Any(), inv.ID, inv.TokenID, gomock.Any()).DoAndReturn(func(ctx context.Context, id, tokenID uuid.UUID, updates model.Invitation) error {
+		assert.NotEqual(t, inv.TokenID, updates.TokenID)
+		assert.True(t, updates.ExpiresAt.After(time.Now()))
+		return nil
+	})
+	res, err := userService.ResendInvitation(ctx, inv.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.InvitationPending, res.Status)
+	claims, err := utils.ValidateInvitationToken(res.Token, testJWT)
+	assert.NoError(t, err)
+	assert.NotEqual(t, inv.TokenID.String(), claims.ID)
+
+	// revoking one accepted meanwhile fails
+	mockInvitationRepo.EXPECT().FindInvitation(gomock.Any(), testOrg.ID, inv.ID).Return(inv, nil)
+	mockInvitationRepo.EXPECT().UpdatePending(gomock.Any(), inv.ID, inv.TokenID, gomock.Any()).Return(gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, userService.RevokeInvitation(ctx, inv.ID), ErrInvitationNotPending)
+
+	// members can't manage invitations
+	member := auth.WithPrincipal(tenant.WithTenant(context.Background(), testOrg), auth.Principal{UserID: uuid.New(), OrgRole: model.OrgRoleMember})
+	assert.ErrorIs(t, userService.RevokeInvitation(member, inv.ID), ErrForbidden)
+	_, err = userService.ListInvitations(member)
+	assert.ErrorIs(t, err, ErrForbidden)
+}
+
+func TestUserService_CreateUser_InviteOnly(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	registration := config.Default().Registration
+	registration.Mode = "invite_only"
+	userService := NewUserService(mockUserRepo, newTxManager(ctrl, mockUserRepo), testJWT, WithRegistration(registration))
+
+	_, err := userService.CreateUser(context.Background(), model.UserRequest{Username: "newuser", Email: "new@example.com", Password: "password123"})
+	assert.ErrorIs(t, err, ErrRegistrationClosed)
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/mailer"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository"
+	"github.com/kevinmarcellius/go-simple-auth/internal/tenant"
+	"github.com/kevinmarcellius/go-simple-auth/internal/tracing"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+// ErrInvalidMagicLink is returned when logging in with a link token that is
+// invalid, expired, already used, or for a user no longer in the
+// organization.
+var ErrInvalidMagicLink = errors.New("invalid magic link")
+
+// RequestMagicLink emails a login link to req.Email. Unknown and disabled
+// accounts get no link, but the caller isn't told so the endpoint doesn't
+// reveal which emails have accounts; the audit log records it instead.
+func (s *userService) RequestMagicLink(ctx context.Context, req model.MagicLinkRequest) (err error) {
+	ctx, span := tracer.Start(ctx, "userService.RequestMagicLink")
+	defer func() { tracing.End(span, err) }()
+
+	event := newAuditEvent(ctx, model.AuditMagicLinkRequest)
+	event.TargetEmail = truncate(req.Email, maxAuditEmail)
+	// silent is the failure audited for accounts that get no link
+	var silent error
+	defer func() { s.finishAudit(ctx, event, cmp.Or(err, silent)) }()
+
+	if s.mailer == nil {
+		return errors.New("magic links are not enabled")
+	}
+	if _, ok := s.tokens.Lifetimes(req.ClientID, ""); !ok {
+		return ErrUnknownClient
+	}
+	user, err := s.findByEmail(ctx, s.userRepo, req.Email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		silent = err
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	event.TargetEmail = user.Email
+	event.TargetID = &user.ID
+	if user.DisabledAt != nil {
+		silent = utils.ErrUserDisabled
+		return nil
+	}
+
+	now := time.Now()
+	link := model.MagicLink{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		OrgID:      requestTenant(ctx).ID,
+		ClientID:   req.ClientID,
+		RememberMe: req.RememberMe,
+		ExpiresAt:  now.Add(s.magicLink.TTL),
+		CreatedAt:  now,
+	}
+	token, err := utils.GenerateMagicLinkToken(link, s.jwt)
+	if err != nil {
+		return err
+	}
+
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := repos.MagicLinks.CreateMagicLink(ctx, link); err != nil {
+			return err
+		}
+		if err := repos.Audit.CreateEvent(ctx, event); err != nil {
+			return err
+		}
+		// the link is a credential, so it goes straight to the user's
+		// inbox rather than through the webhook outbox; sent last, a
+		// failure rolls back the link nobody got
+		return s.mailer.SendMagicLink(ctx, mailer.MagicLink{
+			To:        user.Email,
+			Username:  user.Username,
+			Link:      linkWithToken(s.magicLink.URL, token),
+			ExpiresAt: link.ExpiresAt,
+		})
+	})
+}
+
+// VerifyMagicLink logs in with the token of a login link, starting a session
+// like Login does with the client and remember-me choice of the request for
+// the link. Each link logs in once.
+func (s *userService) VerifyMagicLink(ctx context.Context, req model.VerifyMagicLinkRequest) (_ model.LoginResponse, err error) {
+	ctx, span := tracer.Start(ctx, "userService.VerifyMagicLink")
+	defer func() { tracing.End(span, err) }()
+	defer func() { observeLogin(err) }()
+
+	event := newAuditEvent(ctx, model.AuditMagicLinkLogin)
+	defer func() { s.recordAudit(ctx, event, err) }()
+
+	claims, err := utils.ValidateMagicLinkToken(req.Token, s.jwt)
+	if err != nil {
+		return model.LoginResponse{}, fmt.Errorf("%w: %w", ErrInvalidMagicLink, err)
+	}
+	id, idErr := uuid.Parse(claims.ID)
+	userID, userErr := uuid.Parse(claims.Subject)
+	org := requestTenant(ctx)
+	if idErr != nil || userErr != nil || claims.TenantID != org.ID.String() {
+		return model.LoginResponse{}, ErrInvalidMagicLink
+	}
+	event.TargetID = &userID
+
+	// used up before anything else is checked, so a link can't be tried
+	// twice
+	var link model.MagicLink
+	err = s.txManager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		link, err = repos.MagicLinks.UseMagicLink(ctx, id, time.Now())
+		return err
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && link.UserID != userID) {
+		return model.LoginResponse{}, ErrInvalidMagicLink
+	}
+	if err != nil {
+		return model.LoginResponse{}, err
+	}
+
+	// users removed from the organization are no longer found in it
+	user, err := s.userRepo.FindUserByID(tenant.WithTenant(ctx, org), userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.LoginResponse{}, ErrInvalidMagicLink
+	}
+	if err != nil {
+		return model.LoginResponse{}, err
+	}
+	event.ActorID = &user.ID
+	if user.DisabledAt != nil {
+		return model.LoginResponse{}, utils.ErrUserDisabled
+	}
+	if user.EmailVerifiedAt == nil {
+		// the link was sent to the user's email, which proves they own it
+		if err := s.verifyEmail(tenant.WithTenant(ctx, org), user); err != nil {
+			return model.LoginResponse{}, err
+		}
+	}
+
+	lifetimes, ok := s.tokens.Lifetimes(link.ClientID, userRole(user))
+	if !ok {
+		return model.LoginResponse{}, fmt.Errorf("%w: %w", ErrInvalidMagicLink, ErrUnknownClient)
+	}
+	accessToken, refreshToken, err := utils.GenerateJWT(user, s.jwt, utils.Session{
+		ClientID:   link.ClientID,
+		RememberMe: link.RememberMe,
+		AuthTime:   time.Now(),
+		Lifetimes:  lifetimes,
+		OrgID:      org.ID,
+		Org:        org.Slug,
+		OrgRole:    user.OrgRole,
+	})
+	if err != nil {
+		return model.LoginResponse{}, err
+	}
+
+	return model.LoginResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// verifyEmail records that user owns their email and announces it to
+// webhook subscribers.
+func (s *userService) verifyEmail(ctx context.Context, user model.User) error {
+	now := time.Now()
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := repos.Users.UpdateUserById(ctx, user.ID, model.User{EmailVerifiedAt: &now}); err != nil {
+			return err
+		}
+		return enqueueUserEvent(ctx, repos, model.WebhookUserVerified, user)
+	})
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/mailer"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository/mocks"
+	"github.com/kevinmarcellius/go-simple-auth/internal/tenant"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+var testMagicLink = config.MagicLinkConfig{Enabled: true, TTL: 15 * time.Minute, URL: "https://app.example.com/magic?src=email"}
+
+// fakeMailer records the links it is asked to send.
+type fakeMailer struct {
+	sent []mailer.MagicLink
+	err  error
+}
+
+func (m *fakeMailer) SendMagicLink(ctx context.Context, msg mailer.MagicLink) error {
+	m.sent = append(m.sent, msg)
+	return m.err
+}
+
+func TestUserService_RequestMagicLink(t *testing.T) {
+	user := model.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com"}
+	disabledAt := time.Now()
+	disabled := model.User{ID: uuid.New(), Email: "gone@example.com", DisabledAt: &disabledAt}
+
+	testCases := []struct {
+		name        string
+		req         model.MagicLinkRequest
+		mailErr     error
+		mockRepo    func(users *mocks.MockUserRepository, links *mocks.MockMagicLinkRepository)
+		checkSent   func(t *testing.T, sent []mailer.MagicLink)
+		expectedErr error
+	}{
+		{
+			name: "Sends Link",
+			req:  model.MagicLinkRequest{Email: " Bob@Example.com", RememberMe: true},
+			mockRepo: func(users *mocks.MockUserRepository, links *mocks.MockMagicLinkRepository) {
+				users.EXPECT().GetUserByEmail(gomock.Any(), "bob@example.com").Return(user, nil)
+				links.EXPECT().CreateMagicLink(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, link model.MagicLink) error {
+					assert.Equal(t, user.ID, link.UserID)
+					assert.Equal(t, testOrg.ID, link.OrgID)
+					assert.True(t, link.RememberMe)
+					assert.WithinDuration(t, time.Now().Add(15*time.Minute), link.ExpiresAt, time.Second)
+					return nil
+				})
+			},
+			checkSent: func(t *testing.T, sent []mailer.MagicLink) {
+				if !assert.Len(t, sent, 1) {
+					return
+				}
+				msg := sent[0]
+				assert.Equal(t, "bob@example.com", msg.To)
+				assert.Equal(t, "bob", msg.Username)
+				assert.WithinDuration(t, time.Now().Add(15*time.Minute), msg.ExpiresAt, time.Second)
+
+				u, err := url.Parse(msg.Link)
+				assert.NoError(t, err)
+				assert.Equal(t, "app.example.com", u.Host)
+				assert.Equal(t, "email", u.Query().Get("src"))
+				claims, err := utils.ValidateMagicLinkToken(u.Query().Get("token"), testJWT)
+				assert.NoError(t, err)
+				assert.Equal(t, user.ID.String(), claims.Subject)
+			},
+		},
+		{
+			name: "Unknown Email Is Not Revealed",
+			req:  model.MagicLinkRequest{Email: "nobody@example.com"},
+			mockRepo: func(users *mocks.MockUserRepository, links *mocks.MockMagicLinkRepository) {
+				users.EXPECT().GetUserByEmail(gomock.Any(), "nobody@example.com").Return(model.User{}, gorm.ErrRecordNotFound)
+			},
+		},
+		{
+			name: "Disabled User Is Not Revealed",
+			req:  model.MagicLinkRequest{Email: "gone@example.com"},
+			mockRepo: func(users *mocks.MockUserRepository, links *mocks.MockMagicLinkRepository) {
+				users.EXPECT().GetUserByEmail(gomock.Any(), "gone@example.com").Return(disabled, nil)
+			},
+		},
+		{
+			name: "Invalid Email",
+			req:  model.MagicLinkRequest{Email: "bob@"},
+			mockRepo: func(users *mocks.MockUserRepository, links *mocks.MockMagicLinkRepository) {
+			},
+			expectedErr: utils.ErrInvalidEmail,
+		},
+		{
+			name: "Unknown Client",
+			req:  model.MagicLinkRequest{Email: "bob@example.com", ClientID: "nope"},
+			mockRepo: func(users *mocks.MockUserRepository, links *mocks.MockMagicLinkRepository) {
+			},
+			expectedErr: ErrUnknownClient,
+		},
+		{
+			name: "Database Error",
+			req:  model.MagicLinkRequest{Email: "bob@example.com"},
+			mockRepo: func(users *mocks.MockUserRepository, links *mocks.MockMagicLinkRepository) {
+				users.EXPECT().GetUserByEmail(gomock.Any(), "bob@example.com").Return(user, nil)
+				links.EXPECT().CreateMagicLink(gomock.Any(), gomock.Any()).Return(assert.AnError)
+			},
+			expectedErr: assert.AnError,
+		},
+		{
+			name:    "Mail Error",
+			req:     model.MagicLinkRequest{Email: "bob@example.com"},
+			mailErr: assert.AnError,
+			mockRepo: func(users *mocks.MockUserRepository, links *mocks.MockMagicLinkRepository) {
+				users.EXPECT().GetUserByEmail(gomock.Any(), "bob@example.com").Return(user, nil)
+				links.EXPECT().CreateMagicLink(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			checkSent: func(t *testing.T, sent []mailer.MagicLink) {
+				assert.Len(t, sent, 1)
+			},
+			expectedErr: assert.AnError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockUserRepo := mocks.NewMockUserRepository(ctrl)
+			mockMagicLinkRepo := mocks.NewMockMagicLinkRepository(ctrl)
+			tc.mockRepo(mockUserRepo, mockMagicLinkRepo)
+			txManager := newTxManager(ctrl, mockUserRepo)
+			txManager.Repos.MagicLinks = mockMagicLinkRepo
+			// no webhook event carries the link
+			txManager.Repos.Webhooks = mocks.NewMockWebhookRepository(ctrl)
+			sender := &fakeMailer{err: tc.mailErr}
+			userService := NewUserService(mockUserRepo, txManager, testJWT, WithMagicLink(sender, testMagicLink))
+
+			err := userService.RequestMagicLink(tenant.WithTenant(context.Background(), testOrg), tc.req)
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			if tc.checkSent != nil {
+				tc.checkSent(t, sender.sent)
+			} else {
+				assert.Empty(t, sender.sent)
+			}
+		})
+	}
+}
+
+func TestUserService_VerifyMagicLink(t *testing.T) {
+	user := model.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com", OrgRole: model.OrgRoleMember}
+	newLink := func() model.MagicLink {
+		return model.MagicLink{ID: uuid.New(), UserID: user.ID, OrgID: testOrg.ID, RememberMe: true, ExpiresAt: time.Now().Add(15 * time.Minute)}
+	}
+	linkToken := func(link model.MagicLink) string {
+		token, _ := utils.GenerateMagicLinkToken(link, testJWT)
+		return token
+	}
+
+	testCases := []struct {
+		name        string
+		link        model.MagicLink
+		token       func(link model.MagicLink) string
+		mockRepo    func(link model.MagicLink, users *mocks.MockUserRepository, links *mocks.MockMagicLinkRepository)
+		expectedErr error
+	}{
+		{
+			name: "Success",
+			link: newLink(),
+			mockRepo: func(link model.MagicLink, users *mocks.MockUserRepository, links *mocks.MockMagicLinkRepository) {
+				links.EXPECT().UseMagicLink(gomock.Any(), link.ID, gomock.Any()).Return(link, nil)
+				users.EXPECT().FindUserByID(gomock.Any(), user.ID).Return(user, nil)
+				// the first link login verifies the email
+				users.EXPECT().UpdateUserById(gomock.Any(), user.ID, gomock.Cond(func(u model.User) bool {
+					return u.EmailVerifiedAt != nil
+				})).Return(nil)
+			},
+		},
+		{
+			name: "Already Verified",
+			link: newLink(),
+			mockRepo: func(link model.MagicLink, users *mocks.MockUserRepository, links *mocks.MockMagicLinkRepository) {
+				links.EXPECT().UseMagicLink(gomock.Any(), link.ID, gomock.Any()).Return(link, nil)
+				verifiedAt := time.Now().Add(-time.Hour)
+				verified := user
+				verified.EmailVerifiedAt = &verifiedAt
+				users.EXPECT().FindUserByID(gomock.Any(), user.ID).Return(verified, nil)
+			},
+		},
+		{
+			name: "Already Used",
+			link: newLink(),
+			mockRepo: func(link model.MagicLink, users *mocks.MockUserRepository, links *mocks.MockMagicLinkRepository) {
+				links.EXPECT().UseMagicLink(gomock.Any(), link.ID, gomock.Any()).Return(model.MagicLink{}, gorm.ErrRecordNotFound)
+			},
+			expectedErr: ErrInvalidMagicLink,
+		},
+		{
+			name: "Other Organization",
+			link: func() model.MagicLink {
+				link := newLink()
+				link.OrgID = uuid.New()
+				return link
+			}(),
+			mockRepo:    func(link model.MagicLink, users *mocks.MockUserRepository, links *mocks.MockMagicLinkRepository) {},
+			expectedErr: ErrInvalidMagicLink,
+		},
+		{
+			name: "Not A Link Token",
+			link: newLink(),
+			token: func(link model.MagicLink) string {
+				token, _, _ := utils.GenerateJWT(user, testJWT, testSession())
+				return token
+			},
+			mockRepo:    func(link model.MagicLink, users *mocks.MockUserRepository, links *mocks.MockMagicLinkRepository) {},
+			expectedErr: ErrInvalidMagicLink,
+		},
+		{
+			name: "Removed From Organization",
+			link: newLink(),
+			mockRepo: func(link model.MagicLink, users *mocks.MockUserRepository, links *mocks.MockMagicLinkRepository) {
+				links.EXPECT().UseMagicLink(gomock.Any(), link.ID, gomock.Any()).Return(link, nil)
+				users.EXPECT().FindUserByID(gomock.Any(), user.ID).Return(model.User{}, gorm.ErrRecordNotFound)
+			},
+			expectedErr: ErrInvalidMagicLink,
+		},
+		{
+			name: "Disabled User",
+			link: newLink(),
+			mockRepo: func(link model.MagicLink, users *mocks.MockUserRepository, links *mocks.MockMagicLinkRepository) {
+				links.EXPECT().UseMagicLink(gomock.Any(), link.ID, gomock.Any()).Return(link, nil)
+				disabledAt := time.Now()
+				disabled := user
+				disabled.DisabledAt = &disabledAt
+				users.EXPECT().FindUserByID(gomock.Any(), user.ID).Return(disabled, nil)
+			},
+			expectedErr: utils.ErrUserDisabled,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockUserRepo := mocks.NewMockUserRepository(ctrl)
+			mockMagicLinkRepo := mocks.NewMockMagicLinkRepository(ctrl)
+			tc.mockRepo(tc.link, mockUserRepo, mockMagicLinkRepo)
+			txManager := newTxManager(ctrl, mockUserRepo)
+			txManager.Repos.MagicLinks = mockMagicLinkRepo
+			userService := NewUserService(mockUserRepo, txManager, testJWT, WithMagicLink(&fakeMailer{}, testMagicLink))
+
+			token := linkToken(tc.link)
+			if tc.token != nil {
+				token = tc.token(tc.link)
+			}
+			res, err := userService.VerifyMagicLink(tenant.WithTenant(context.Background(), testOrg), model.VerifyMagicLinkRequest{Token: token})
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			claims, err := utils.ValidateAccessToken(res.AccessToken, testJWT)
+			assert.NoError(t, err)
+			assert.Equal(t, user.ID.String(), claims.UserID)
+			assert.Equal(t, testOrg.Slug, claims.Org)
+			refresh, err := utils.ValidateRefreshToken(res.RefreshToken, testJWT)
+			assert.NoError(t, err)
+			assert.True(t, refresh.RememberMe, "the session keeps the choice made when requesting the link")
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository"
+	"github.com/kevinmarcellius/go-simple-auth/internal/tenant"
+	"github.com/kevinmarcellius/go-simple-auth/internal/tracing"
+)
+
+var (
+	ErrInvalidSlug    = errors.New("slug must be lowercase letters, digits and dashes, at most 63 characters")
+	ErrInvalidOrgRole = fmt.Errorf("role must be one of %v", model.OrgRoles)
+)
+
+func (s *userService) CreateOrganization(ctx context.Context, slug, name string) (_ model.Organization, err error) {
+	ctx, span := tracer.Start(ctx, "userService.CreateOrganization")
+	defer func() { tracing.End(span, err) }()
+
+	event := newAuditEvent(ctx, model.AuditOrgCreate)
+	defer func() { s.finishAudit(ctx, event, err) }()
+
+	if !model.ValidSlug(slug) {
+		return model.Organization{}, ErrInvalidSlug
+	}
+	if name == "" {
+		name = slug
+	}
+	org := model.Organization{ID: uuid.New(), Slug: slug, Name: name}
+
+	err = s.txManager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := repos.Organizations.CreateOrganization(ctx, org); err != nil {
+			return err
+		}
+		return repos.Audit.CreateEvent(ctx, event)
+	})
+	if err != nil {
+		return model.Organization{}, err
+	}
+	return org, nil
+}
+
+// AddMember gives the user with email role in the organization, whether or
+// not they were a member already. It fails if another member has the same
+// email, which would make logging in to the organization ambiguous.
+func (s *userService) AddMember(ctx context.Context, slug, email, role string) (err error) {
+	ctx, span := tracer.Start(ctx, "userService.AddMember")
+	defer func() { tracing.End(span, err) }()
+
+	event := newAuditEvent(ctx, model.AuditMemberAdd)
+	defer func() { s.finishAudit(ctx, event, err) }()
+
+	if !slices.Contains(model.OrgRoles, role) {
+		event.TargetEmail = truncate(email, maxAuditEmail)
+		return ErrInvalidOrgRole
+	}
+
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		org, err := repos.Organizations.FindBySlug(ctx, slug)
+		if err != nil {
+			return err
+		}
+		user, err := s.findTarget(ctx, repos.Users, email, &event)
+		if err != nil {
+			return err
+		}
+		if err := checkEmailFree(tenant.WithTenant(ctx, tenant.Tenant{ID: org.ID, Slug: org.Slug}), repos.Users, user.Email, user.ID); err != nil {
+			return err
+		}
+		if err := repos.Organizations.SetMember(ctx, model.Membership{OrgID: org.ID, UserID: user.ID, Role: role}); err != nil {
+			return err
+		}
+		return repos.Audit.CreateEvent(ctx, event)
+	})
+}
+
+// RemoveMember takes the user with email out of the organization. Their
+// tokens for it stop refreshing at once. The user is looked up among the
+// organization's members, of which only one has email.
+func (s *userService) RemoveMember(ctx context.Context, slug, email string) (err error) {
+	ctx, span := tracer.Start(ctx, "userService.RemoveMember")
+	defer func() { tracing.End(span, err) }()
+
+	event := newAuditEvent(ctx, model.AuditMemberRemove)
+	defer func() { s.finishAudit(ctx, event, err) }()
+
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		org, err := repos.Organizations.FindBySlug(ctx, slug)
+		if err != nil {
+			return err
+		}
+		user, err := s.findTarget(tenant.WithTenant(ctx, tenant.Tenant{ID: org.ID, Slug: org.Slug}), repos.Users, email, &event)
+		if err != nil {
+			return err
+		}
+		if err := repos.Organizations.RemoveMember(ctx, org.ID, user.ID); err != nil {
+			return err
+		}
+		return repos.Audit.CreateEvent(ctx, event)
+	})
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository/mocks"
+	"github.com/kevinmarcellius/go-simple-auth/internal/tenant"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+var testOrg = tenant.Tenant{ID: uuid.New(), Slug: "acme"}
+
+func TestUserService_CreateUser_Tenant(t *testing.T) {
+	testCases := []struct {
+		name          string
+		uniqueness    string
+		expectedScope uuid.UUID
+	}{
+		{name: "Global Uniqueness", uniqueness: "global", expectedScope: uuid.Nil},
+		{name: "Tenant Uniqueness", uniqueness: "tenant", expectedScope: testOrg.ID},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			var created model.User
+			mockUserRepo := mocks.NewMockUserRepository(ctrl)
+			mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), "new@example.com").Return(model.User{}, gorm.ErrRecordNotFound)
+			mockUserRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, user model.User) error {
+				created = user
+				return nil
+			})
+			mockOrgRepo := mocks.NewMockOrganizationRepository(ctrl)
+			mockOrgRepo.EXPECT().SetMember(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, membership model.Membership) error {
+				assert.Equal(t, model.Membership{OrgID: testOrg.ID, UserID: created.ID, Role: model.OrgRoleMember}, membership)
+				return nil
+			})
+			txManager := newTxManager(ctrl, mockUserRepo)
+			txManager.Repos.Organizations = mockOrgRepo
+			tenancy := config.Default().Tenancy
+			tenancy.Uniqueness = tc.uniqueness
+			userService := NewUserService(mockUserRepo, txManager, testJWT, WithTenancy(tenancy))
+
+			ctx := tenant.WithTenant(context.Background(), testOrg)
+			_, err := userService.CreateUser(ctx, model.UserRequest{Username: "newuser", Email: "new@example.com", Password: "password123"})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedScope, created.UniquenessScope)
+		})
+	}
+}
+
+func TestUserService_CreateUser_EmailInOrganization(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// with tenant uniqueness, a user registered elsewhere is a member already
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), "new@example.com").Return(model.User{ID: uuid.New(), Email: "new@example.com"}, nil)
+	tenancy := config.Default().Tenancy
+	tenancy.Uniqueness = "tenant"
+	userService := NewUserService(mockUserRepo, newTxManager(ctrl, mockUserRepo), testJWT, WithTenancy(tenancy))
+
+	ctx := tenant.WithTenant(context.Background(), testOrg)
+	_, err := userService.CreateUser(ctx, model.UserRequest{Username: "newuser", Email: "new@example.com", Password: "password123"})
+	assert.ErrorIs(t, err, ErrEmailInOrganization)
+}
+
+func TestUserService_Tenant_Tokens(t *testing.T) {
+	hashedPassword, _ := utils.HashPassword("password123")
+	testUser := model.User{ID: uuid.New(), Username: "testuser", Email: "test@example.com", PasswordHash: hashedPassword, OrgRole: model.OrgRoleAdmin}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), "test@example.com").Return(testUser, nil)
+	userService := NewUserService(mockUserRepo, newTxManager(ctrl, mockUserRepo), testJWT)
+
+	// tokens are issued for the organization logged in to
+	ctx := tenant.WithTenant(context.Background(), testOrg)
+	login, err := userService.Login(ctx, model.LoginRequest{Email: "test@example.com", Password: "password123"})
+	assert.NoError(t, err)
+	claims, err := utils.ValidateAccessToken(login.AccessToken, testJWT)
+	assert.NoError(t, err)
+	assert.Equal(t, testOrg.ID.String(), claims.TenantID)
+	assert.Equal(t, "acme", claims.Org)
+	assert.Equal(t, model.OrgRoleAdmin, claims.OrgRole)
+
+	// and can't be refreshed in another one
+	other := tenant.WithTenant(context.Background(), tenant.Default)
+	_, err = userService.Refresh(other, model.RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	assert.ErrorIs(t, err, utils.ErrInvalidToken)
+
+	// refreshing looks the user up in the token's organization
+	mockUserRepo.EXPECT().FindUserByID(gomock.Any(), testUser.ID).DoAndReturn(func(ctx context.Context, id uuid.UUID) (model.User, error) {
+		got, ok := tenant.FromContext(ctx)
+		assert.True(t, ok)
+		assert.Equal(t, testOrg.ID, got.ID)
+		return model.User{}, gorm.ErrRecordNotFound
+	})
+	_, err = userService.Refresh(context.Background(), model.RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestUserService_Memberships(t *testing.T) {
+	testUser := model.User{ID: uuid.New(), Email: "ops@example.com"}
+	org := model.Organization{ID: testOrg.ID, Slug: testOrg.Slug}
+
+	testCases := []struct {
+		name        string
+		run         func(s UserService) error
+		mockRepo    func(users *mocks.MockUserRepository, orgs *mocks.MockOrganizationRepository)
+		expectedErr error
+	}{
+		{
+			name: "Create Organization",
+			run: func(s UserService) error {
+				created, err := s.CreateOrganization(context.Background(), "acme", "Acme Inc.")
+				assert.Equal(t, "Acme Inc.", created.Name)
+				return err
+			},
+			mockRepo: func(users *mocks.MockUserRepository, orgs *mocks.MockOrganizationRepository) {
+				orgs.EXPECT().CreateOrganization(gomock.Any(), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "Invalid Slug",
+			run: func(s UserService) error {
+				_, err := s.CreateOrganization(context.Background(), "Acme Inc.", "")
+				return err
+			},
+			mockRepo:    func(users *mocks.MockUserRepository, orgs *mocks.MockOrganizationRepository) {},
+			expectedErr: ErrInvalidSlug,
+		},
+		{
+			name: "Add Member",
+			run: func(s UserService) error {
+				return s.AddMember(context.Background(), "acme", "Ops@Example.com", model.OrgRoleOwner)
+			},
+			mockRepo: func(users *mocks.MockUserRepository, orgs *mocks.MockOrganizationRepository) {
+				orgs.EXPECT().FindBySlug(gomock.Any(), "acme").Return(org, nil)
+				users.EXPECT().ListUsersByEmail(gomock.Any(), "ops@example.com", 2).Return([]model.User{testUser}, nil)
+				users.EXPECT().GetUserByEmail(gomock.Any(), "ops@example.com").Return(model.User{}, gorm.ErrRecordNotFound)
+				orgs.EXPECT().SetMember(gomock.Any(), model.Membership{OrgID: org.ID, UserID: testUser.ID, Role: model.OrgRoleOwner}).Return(nil)
+			},
+		},
+		{
+			name: "Add Member With Email Of Another Member",
+			run: func(s UserService) error {
+				return s.AddMember(context.Background(), "acme", "ops@example.com", model.OrgRoleMember)
+			},
+			mockRepo: func(users *mocks.MockUserRepository, orgs *mocks.MockOrganizationRepository) {
+				orgs.EXPECT().FindBySlug(gomock.Any(), "acme").Return(org, nil)
+				users.EXPECT().ListUsersByEmail(gomock.Any(), "ops@example.com", 2).Return([]model.User{testUser}, nil)
+				// registered in acme while testUser registered elsewhere
+				users.EXPECT().GetUserByEmail(gomock.Any(), "ops@example.com").Return(model.User{ID: uuid.New(), Email: "ops@example.com"}, nil)
+			},
+			expectedErr: ErrEmailInOrganization,
+		},
+		{
+			name: "Ambiguous Email",
+			run: func(s UserService) error {
+				return s.AddMember(context.Background(), "acme", "ops@example.com", model.OrgRoleMember)
+			},
+			mockRepo: func(users *mocks.MockUserRepository, orgs *mocks.MockOrganizationRepository) {
+				orgs.EXPECT().FindBySlug(gomock.Any(), "acme").Return(org, nil)
+				users.EXPECT().ListUsersByEmail(gomock.Any(), "ops@example.com", 2).Return([]model.User{testUser, {ID: uuid.New(), Email: "ops@example.com"}}, nil)
+			},
+			expectedErr: ErrAmbiguousUser,
+		},
+		{
+			name: "Invalid Role",
+			run: func(s UserService) error {
+				return s.AddMember(context.Background(), "acme", "ops@example.com", "superuser")
+			},
+			mockRepo:    func(users *mocks.MockUserRepository, orgs *mocks.MockOrganizationRepository) {},
+			expectedErr: ErrInvalidOrgRole,
+		},
+		{
+			name: "Remove Member",
+			run: func(s UserService) error {
+				return s.RemoveMember(context.Background(), "acme", "ops@example.com")
+			},
+			mockRepo: func(users *mocks.MockUserRepository, orgs *mocks.MockOrganizationRepository) {
+				orgs.EXPECT().FindBySlug(gomock.Any(), "acme").Return(org, nil)
+				// looked up among the members of acme
+				inOrg := gomock.Cond(func(ctx context.Context) bool {
+					t, ok := tenant.FromContext(ctx)
+					return ok && t.ID == org.ID
+				})
+				users.EXPECT().GetUserByEmail(inOrg, "ops@example.com").Return(testUser, nil)
+				orgs.EXPECT().RemoveMember(gomock.Any(), org.ID, testUser.ID).Return(gorm.ErrRecordNotFound)
+			},
+			expectedErr: gorm.ErrRecordNotFound,
+		},
+		{
+			name: "Unknown Organization",
+			run: func(s UserService) error {
+				return s.RemoveMember(context.Background(), "globex", "ops@example.com")
+			},
+			mockRepo: func(users *mocks.MockUserRepository, orgs *mocks.MockOrganizationRepository) {
+				orgs.EXPECT().FindBySlug(gomock.Any(), "globex").Return(model.Organization{}, gorm.ErrRecordNotFound)
+			},
+			expectedErr: gorm.ErrRecordNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockUserRepo := mocks.NewMockUserRepository(ctrl)
+			mockOrgRepo := mocks.NewMockOrganizationRepository(ctrl)
+			tc.mockRepo(mockUserRepo, mockOrgRepo)
+			txManager := newTxManager(ctrl, mockUserRepo)
+			txManager.Repos.Organizations = mockOrgRepo
+
+			userService := NewUserService(mockUserRepo, txManager, testJWT)
+
+			err := tc.run(userService)
+
+			if tc.expectedErr != nil {
+				assert.Equal(t, tc.expectedErr, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
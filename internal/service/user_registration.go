@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/audit"
+	"github.com/kevinmarcellius/go-simple-auth/internal/captcha"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+	"gorm.io/gorm"
+)
+
+func (s *userService) domainPolicy() utils.DomainPolicy {
+	return utils.DomainPolicy{
+		Allowed:         s.registration.AllowedDomains,
+		Blocked:         s.registration.BlockedDomains,
+		BlockDisposable: s.registration.BlockDisposable,
+	}
+}
+
+// verifyCaptcha returns ErrCaptchaRequired unless token is a solved captcha
+// or captchas are disabled. Errors reaching the provider are returned as
+// they are.
+func (s *userService) verifyCaptcha(ctx context.Context, token string) error {
+	if s.captcha == nil {
+		return nil
+	}
+	err := s.captcha.Verify(ctx, token, audit.RequestInfoFromContext(ctx).IP)
+	if errors.Is(err, captcha.ErrInvalid) {
+		return fmt.Errorf("%w: %w", ErrCaptchaRequired, err)
+	}
+	return err
+}
+
+// loginFailuresKey identifies the account logged in to with email in the
+// organization of the request, or is empty when email is invalid.
+func (s *userService) loginFailuresKey(ctx context.Context, email string) string {
+	if s.captcha == nil || s.loginFailures == nil || s.captchaConfig.LoginFailures == 0 {
+		return ""
+	}
+	email, err := utils.NormalizeEmail(email, s.emailPolicy)
+	if err != nil {
+		return ""
+	}
+	return "login:" + requestTenant(ctx).ID.String() + ":" + email
+}
+
+// checkLoginCaptcha asks for a captcha once the account under key has had
+// too many failed logins. Failing to count them lets the login through
+// rather than locking everyone out while the cache is down.
+func (s *userService) checkLoginCaptcha(ctx context.Context, key, token string) error {
+	if key == "" {
+		return nil
+	}
+	n, err := s.loginFailures.Count(ctx, key)
+	if err != nil {
+		s.log(ctx).Warn("count login failures failed", slog.Any("error", err))
+		return nil
+	}
+	if n < s.captchaConfig.LoginFailures {
+		return nil
+	}
+	return s.verifyCaptcha(ctx, token)
+}
+
+// countLoginFailure records a login to the account under key that failed
+// with err for a wrong email or password, and forgets the failures once a
+// login succeeds.
+func (s *userService) countLoginFailure(ctx context.Context, key string, err error) {
+	if key == "" {
+		return
+	}
+	switch {
+	case err == nil:
+		err = s.loginFailures.Reset(ctx, key)
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, utils.ErrInvalidPassword):
+		_, err = s.loginFailures.Add(ctx, key, s.captchaConfig.FailureWindow)
+	default:
+		return
+	}
+	if err != nil {
+		s.log(ctx).Warn("record login failure failed", slog.Any("error", err))
+	}
+}
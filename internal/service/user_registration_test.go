@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/cache"
+	"github.com/kevinmarcellius/go-simple-auth/internal/captcha"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository/mocks"
+	"github.com/kevinmarcellius/go-simple-auth/internal/tenant"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+func TestUserService_CreateUser_RegistrationControls(t *testing.T) {
+	testCases := []struct {
+		name         string
+		registration func(r *config.RegistrationConfig)
+		email        string
+		captchaToken string
+		created      bool
+		expectedMsg  string
+		expectedErr  error
+	}{
+		{
+			name:         "Closed",
+			registration: func(r *config.RegistrationConfig) { r.Mode = "closed" },
+			email:        "new@example.com",
+			captchaToken: "solved",
+			expectedMsg:  "Registration is closed",
+			expectedErr:  ErrRegistrationClosed,
+		},
+		{
+			name:        "Missing Captcha",
+			email:       "new@example.com",
+			expectedMsg: "Captcha verification failed",
+			expectedErr: ErrCaptchaRequired,
+		},
+		{
+			name:         "Wrong Captcha",
+			email:        "new@example.com",
+			captchaToken: "guessed",
+			expectedMsg:  "Captcha verification failed",
+			expectedErr:  captcha.ErrInvalid,
+		},
+		{
+			name:         "Blocked Domain",
+			registration: func(r *config.RegistrationConfig) { r.BlockedDomains = []string{"example.com"} },
+			email:        "new@Mail.Example.com",
+			captchaToken: "solved",
+			expectedMsg:  "Email domain is not allowed",
+			expectedErr:  utils.ErrEmailDomainNotAllowed,
+		},
+		{
+			name:         "Domain Not Allowed",
+			registration: func(r *config.RegistrationConfig) { r.AllowedDomains = []string{"acme.com"} },
+			email:        "new@example.com",
+			captchaToken: "solved",
+			expectedMsg:  "Email domain is not allowed",
+			expectedErr:  utils.ErrEmailDomainNotAllowed,
+		},
+		{
+			name:         "Disposable Domain",
+			registration: func(r *config.RegistrationConfig) { r.BlockDisposable = true },
+			email:        "new@yopmail.com",
+			captchaToken: "solved",
+			expectedMsg:  "Email domain is not allowed",
+			expectedErr:  utils.ErrEmailDomainNotAllowed,
+		},
+		{
+			name:         "Allowed",
+			registration: func(r *config.RegistrationConfig) { r.AllowedDomains = []string{"acme.com"}; r.BlockDisposable = true },
+			email:        "new@acme.com",
+			captchaToken: "solved",
+			created:      true,
+			expectedMsg:  "User created successfully",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockUserRepo := mocks.NewMockUserRepository(ctrl)
+			if tc.created {
+				mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Return(model.User{}, gorm.ErrRecordNotFound)
+				mockUserRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(nil)
+			}
+			registration := config.Default().Registration
+			if tc.registration != nil {
+				tc.registration(&registration)
+			}
+			userService := NewUserService(mockUserRepo, newTxManager(ctrl, mockUserRepo), testJWT,
+				WithRegistration(registration),
+				WithCaptcha(captcha.Fake{Token: "solved"}, nil, config.Default().Captcha))
+
+			res, err := userService.CreateUser(context.Background(), model.UserRequest{
+				Username:     "newuser",
+				Email:        tc.email,
+				Password:     "password123",
+				CaptchaToken: tc.captchaToken,
+			})
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedMsg, res.Message)
+		})
+	}
+}
+
+func TestUserService_Login_Captcha(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	hashedPassword, _ := utils.HashPassword("password123")
+	user := model.User{ID: uuid.New(), Email: "test@mail.id", PasswordHash: hashedPassword, Username: "testuser"}
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), "test@mail.id").Return(user, nil).AnyTimes()
+	mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), "nobody@mail.id").Return(model.User{}, gorm.ErrRecordNotFound).AnyTimes()
+
+	cfg := config.Default().Captcha
+	cfg.LoginFailures = 2
+	failures := cache.NewFailures(cache.NewLRU(10))
+	userService := NewUserService(mockUserRepo, newTxManager(ctrl, mockUserRepo), testJWT,
+		WithCaptcha(captcha.Fake{Token: "solved"}, failures, cfg))
+	ctx := context.Background()
+	login := func(password, captchaToken string) error {
+		_, err := userService.Login(ctx, model.LoginRequest{Email: "Test@mail.id", Password: password, CaptchaToken: captchaToken})
+		return err
+	}
+
+	// no captcha until the account has failed twice
+	assert.ErrorIs(t, login("wrongpassword", ""), utils.ErrInvalidPassword)
+	assert.ErrorIs(t, login("wrongpassword", ""), utils.ErrInvalidPassword)
+	assert.ErrorIs(t, login("password123", ""), ErrCaptchaRequired)
+	assert.ErrorIs(t, login("password123", "guessed"), ErrCaptchaRequired)
+
+	// other accounts are counted on their own, unknown ones too
+	_, err := userService.Login(ctx, model.LoginRequest{Email: "nobody@mail.id", Password: "password123"})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	n, _ := failures.Count(ctx, "login:"+tenant.Default.ID.String()+":nobody@mail.id")
+	assert.Equal(t, 1, n)
+
+	// a login with a solved captcha succeeds and starts the count over
+	assert.NoError(t, login("password123", "solved"))
+	assert.ErrorIs(t, login("wrongpassword", ""), utils.ErrInvalidPassword)
+	assert.NoError(t, login("password123", ""))
+}
+
+func TestUserService_AcceptInvitation_Closed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	inv := model.Invitation{ID: uuid.New(), OrgID: testOrg.ID, Email: "new@example.com", Role: model.OrgRoleMember, TokenID: uuid.New(), ExpiresAt: time.Now().Add(time.Hour)}
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), "new@example.com").Return(model.User{}, gorm.ErrRecordNotFound).Times(2)
+	mockInvitationRepo := mocks.NewMockInvitationRepository(ctrl)
+	mockInvitationRepo.EXPECT().FindInvitation(gomock.Any(), testOrg.ID, inv.ID).Return(inv, nil)
+	txManager := newTxManager(ctrl, mockUserRepo)
+	txManager.Repos.Invitations = mockInvitationRepo
+	registration := config.Default().Registration
+	registration.Mode = "closed"
+	userService := NewUserService(mockUserRepo, txManager, testJWT, WithRegistration(registration))
+
+	// closed registration creates no accounts, even for invitations
+	token, _ := utils.GenerateInvitationToken(inv, testJWT)
+	_, err := userService.AcceptInvitation(tenant.WithTenant(context.Background(), testOrg), model.AcceptInvitationRequest{Token: token, Username: "newuser", Password: "password123"})
+	assert.ErrorIs(t, err, ErrRegistrationClosed)
+}
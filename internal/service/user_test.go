@@ -2,17 +2,42 @@ package service
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
 
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/cache"
 	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository"
 	"github.com/kevinmarcellius/go-simple-auth/internal/repository/mocks"
 	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
 )
 
+var testJWT = utils.JWTOptions{Key: "test-secret-key", Issuer: "test-issuer", Audience: "test-audience"}
+
+func testSession() utils.Session {
+	return utils.Session{AuthTime: time.Now(), Lifetimes: config.Default().Token.TokenLifetimes}
+}
+
+// newTxManager runs units of work against users and accepts any audit or
+// webhook event and membership change.
+func newTxManager(ctrl *gomock.Controller, users repository.UserRepository) mocks.TxManager {
+	auditRepo := mocks.NewMockAuditRepository(ctrl)
+	auditRepo.EXPECT().CreateEvent(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	webhookRepo := mocks.NewMockWebhookRepository(ctrl)
+	webhookRepo.EXPECT().Enqueue(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	orgRepo := mocks.NewMockOrganizationRepository(ctrl)
+	orgRepo.EXPECT().SetMember(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	return mocks.TxManager{Repos: repository.Repositories{Users: users, Audit: auditRepo, Webhooks: webhookRepo, Organizations: orgRepo}}
+}
+
 func TestUserService_Login(t *testing.T) {
 	hashedPassword, _ := utils.HashPassword("password123")
 	testCases := []struct {
@@ -29,7 +54,7 @@ func TestUserService_Login(t *testing.T) {
 				Password: "password123",
 			},
 			mockRepo: func(mock *mocks.MockUserRepository) {
-				mock.EXPECT().GetUserByEmail("test@mail.id").Return(model.User{
+				mock.EXPECT().GetUserByEmail(gomock.Any(), "test@mail.id").Return(model.User{
 					ID:           uuid.New(),
 					Email:        "test@mail.id",
 					PasswordHash: hashedPassword,
@@ -39,7 +64,59 @@ func TestUserService_Login(t *testing.T) {
 			},
 			expectedMsg: "Login successful",
 			expectedErr: nil,
-	},}
+	},
+		{
+			name: "Email Is Normalized",
+			req: model.LoginRequest{
+				Email:    "  Test@Mail.ID ",
+				Password: "password123",
+			},
+			mockRepo: func(mock *mocks.MockUserRepository) {
+				mock.EXPECT().GetUserByEmail(gomock.Any(), "test@mail.id").Return(model.User{
+					ID:           uuid.New(),
+					Email:        "test@mail.id",
+					PasswordHash: hashedPassword,
+					Username:     "testuser",
+				}, nil)
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "Disabled User",
+			req: model.LoginRequest{
+				Email:    "test@mail.id",
+				Password: "password123",
+			},
+			mockRepo: func(mock *mocks.MockUserRepository) {
+				disabledAt := time.Now()
+				mock.EXPECT().GetUserByEmail(gomock.Any(), "test@mail.id").Return(model.User{
+					ID:           uuid.New(),
+					Email:        "test@mail.id",
+					PasswordHash: hashedPassword,
+					DisabledAt:   &disabledAt,
+				}, nil)
+			},
+			expectedErr: utils.ErrUserDisabled,
+		},
+		{
+			name: "Invalid Email",
+			req: model.LoginRequest{
+				Email:    "not-an-email",
+				Password: "password123",
+			},
+			mockRepo:    func(mock *mocks.MockUserRepository) {},
+			expectedErr: utils.ErrInvalidEmail,
+		},
+		{
+			name: "Unknown Client",
+			req: model.LoginRequest{
+				Email:    "test@mail.id",
+				Password: "password123",
+				ClientID: "unknown-app",
+			},
+			mockRepo:    func(mock *mocks.MockUserRepository) {},
+			expectedErr: ErrUnknownClient,
+		},}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -49,7 +126,7 @@ func TestUserService_Login(t *testing.T) {
 			mockUserRepo := mocks.NewMockUserRepository(ctrl)
 			tc.mockRepo(mockUserRepo)
 			
-			userService := NewUserService(mockUserRepo, "test-secret-key")
+			userService := NewUserService(mockUserRepo, newTxManager(ctrl, mockUserRepo), testJWT)
 			_, err := userService.Login(context.Background(), tc.req)
 			
 			if tc.expectedErr != nil {
@@ -81,12 +158,42 @@ func TestUserService_CreateUser(t *testing.T) {
 				Password: "password123",
 			},
 			mockRepo: func(mock *mocks.MockUserRepository) {
+				mock.EXPECT().GetUserByEmail(gomock.Any(), "new@example.com").Return(model.User{}, gorm.ErrRecordNotFound)
 				// We expect CreateUser to be called with any user object, since the ID and hashed password are created inside the service
-				mock.EXPECT().CreateUser(gomock.Any()).Return(nil)
+				mock.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(nil)
 			},
 			expectedMsg: "User created successfully",
 			expectedErr: nil,
 		},
+		{
+			name: "Username And Email Are Normalized",
+			req: model.UserRequest{
+				Username: "NewUser",
+				Email:    "New@Example.com",
+				Password: "password123",
+			},
+			mockRepo: func(mock *mocks.MockUserRepository) {
+				mock.EXPECT().GetUserByEmail(gomock.Any(), "new@example.com").Return(model.User{}, gorm.ErrRecordNotFound)
+				mock.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, user model.User) error {
+					assert.Equal(t, "newuser", user.Username)
+					assert.Equal(t, "new@example.com", user.Email)
+					return nil
+				})
+			},
+			expectedMsg: "User created successfully",
+			expectedErr: nil,
+		},
+		{
+			name: "Invalid Email",
+			req: model.UserRequest{
+				Username: "newuser",
+				Email:    "new@",
+				Password: "password123",
+			},
+			mockRepo:    func(mock *mocks.MockUserRepository) {},
+			expectedMsg: "Invalid email",
+			expectedErr: utils.ErrInvalidEmail,
+		},
 		{
 			name: "Database Error",
 			req: model.UserRequest{
@@ -95,7 +202,8 @@ func TestUserService_CreateUser(t *testing.T) {
 				Password: "password123",
 			},
 			mockRepo: func(mock *mocks.MockUserRepository) {
-				mock.EXPECT().CreateUser(gomock.Any()).Return(assert.AnError)
+				mock.EXPECT().GetUserByEmail(gomock.Any(), "new@example.com").Return(model.User{}, gorm.ErrRecordNotFound)
+				mock.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(assert.AnError)
 			},
 			expectedMsg: "Failed to create user",
 			expectedErr: assert.AnError,
@@ -110,7 +218,7 @@ func TestUserService_CreateUser(t *testing.T) {
 			mockUserRepo := mocks.NewMockUserRepository(ctrl)
 			tc.mockRepo(mockUserRepo)
 
-			userService := NewUserService(mockUserRepo, "test-secret-key")
+			userService := NewUserService(mockUserRepo, newTxManager(ctrl, mockUserRepo), testJWT)
 
 			res, err := userService.CreateUser(context.Background(), tc.req)
 
@@ -151,9 +259,9 @@ func TestUserService_UpdatePassword(t *testing.T) {
 				NewPassword: "newpassword",
 			},
 			mockRepo: func(mock *mocks.MockUserRepository) {
-				mock.EXPECT().FindUserByID(testUser.ID).Return(testUser, nil)
+				mock.EXPECT().FindUserByID(gomock.Any(), testUser.ID).Return(testUser, nil)
 				// We expect the update to be called with a user model where the password hash has changed
-				mock.EXPECT().UpdateUserById(testUser.ID, gomock.Any()).Return(nil)
+				mock.EXPECT().UpdateUserById(gomock.Any(), testUser.ID, gomock.Any()).Return(nil)
 			},
 			expectedErr: nil,
 		},
@@ -162,7 +270,7 @@ func TestUserService_UpdatePassword(t *testing.T) {
 			userID: uuid.New().String(),
 			req:    model.UpdatePasswordRequest{},
 			mockRepo: func(mock *mocks.MockUserRepository) {
-				mock.EXPECT().FindUserByID(gomock.Any()).Return(model.User{}, assert.AnError)
+				mock.EXPECT().FindUserByID(gomock.Any(), gomock.Any()).Return(model.User{}, assert.AnError)
 			},
 			expectedErr: assert.AnError,
 		},
@@ -174,7 +282,7 @@ func TestUserService_UpdatePassword(t *testing.T) {
 				NewPassword: "newpassword",
 			},
 			mockRepo: func(mock *mocks.MockUserRepository) {
-				mock.EXPECT().FindUserByID(testUser.ID).Return(testUser, nil)
+				mock.EXPECT().FindUserByID(gomock.Any(), testUser.ID).Return(testUser, nil)
 			},
 			expectedErr: utils.ErrInvalidPassword,
 		},
@@ -188,7 +296,7 @@ func TestUserService_UpdatePassword(t *testing.T) {
 			mockUserRepo := mocks.NewMockUserRepository(ctrl)
 			tc.mockRepo(mockUserRepo)
 
-			userService := NewUserService(mockUserRepo, "test-secret-key")
+			userService := NewUserService(mockUserRepo, newTxManager(ctrl, mockUserRepo), testJWT)
 
 			err := userService.UpdatePassword(context.Background(), tc.userID, tc.req)
 
@@ -203,7 +311,6 @@ func TestUserService_UpdatePassword(t *testing.T) {
 }
 
 func TestUserService_Refresh(t *testing.T) {
-    jwtKey := "test-secret-key"
     testUser := model.User{
         ID:       uuid.New(),
         Username: "testuser",
@@ -211,7 +318,7 @@ func TestUserService_Refresh(t *testing.T) {
     }
 
     // Generate a valid refresh token for the test user
-    _, refreshToken, err := utils.GenerateJWT(testUser, jwtKey)
+    accessToken, refreshToken, err := utils.GenerateJWT(testUser, testJWT, testSession())
     assert.NoError(t, err)
 
     testCases := []struct {
@@ -226,7 +333,7 @@ func TestUserService_Refresh(t *testing.T) {
             req:  model.RefreshTokenRequest{RefreshToken: refreshToken},
             mockRepo: func(mock *mocks.MockUserRepository) {
                 // The user ID inside the token should be used to find the user
-                mock.EXPECT().FindUserByID(testUser.ID).Return(testUser, nil)
+                mock.EXPECT().FindUserByID(gomock.Any(), testUser.ID).Return(testUser, nil)
             },
             expectedToken: true,
             expectedErr:   nil,
@@ -240,11 +347,20 @@ func TestUserService_Refresh(t *testing.T) {
             expectedToken: false,
             expectedErr:   assert.AnError, // Expect a generic error from the JWT library
         },
+        {
+            name: "Access Token",
+            req:  model.RefreshTokenRequest{RefreshToken: accessToken},
+            mockRepo: func(mock *mocks.MockUserRepository) {
+                // an access token must not be accepted in place of a refresh token
+            },
+            expectedToken: false,
+            expectedErr:   assert.AnError,
+        },
         {
             name: "User Not Found From Token",
             req:  model.RefreshTokenRequest{RefreshToken: refreshToken},
             mockRepo: func(mock *mocks.MockUserRepository) {
-                mock.EXPECT().FindUserByID(testUser.ID).Return(model.User{}, assert.AnError)
+                mock.EXPECT().FindUserByID(gomock.Any(), testUser.ID).Return(model.User{}, assert.AnError)
             },
             expectedToken: false,
             expectedErr:   assert.AnError,
@@ -259,7 +375,7 @@ func TestUserService_Refresh(t *testing.T) {
             mockUserRepo := mocks.NewMockUserRepository(ctrl)
             tc.mockRepo(mockUserRepo)
 
-            userService := NewUserService(mockUserRepo, jwtKey)
+            userService := NewUserService(mockUserRepo, newTxManager(ctrl, mockUserRepo), testJWT)
 
             res, err := userService.Refresh(context.Background(), tc.req)
 
@@ -274,8 +390,319 @@ func TestUserService_Refresh(t *testing.T) {
                 assert.NoError(t, err)
                 if tc.expectedToken {
                     assert.NotEmpty(t, res.AccessToken)
+                    assert.NotEmpty(t, res.RefreshToken)
                 }
             }
         })
     }
 }
+
+func TestUserService_TokenLifetimes(t *testing.T) {
+	tokens := config.TokenConfig{
+		TokenLifetimes: config.TokenLifetimes{Access: 15 * time.Minute, Refresh: 24 * time.Hour, RememberMe: 30 * 24 * time.Hour},
+		Clients:        map[string]config.TokenLifetimes{"mobile": {Refresh: 7 * 24 * time.Hour}},
+		Roles:          map[string]config.TokenLifetimes{config.RoleAdmin: {Access: 5 * time.Minute, Refresh: time.Hour}},
+	}
+	hashedPassword, _ := utils.HashPassword("password123")
+	user := model.User{ID: uuid.New(), Email: "test@mail.id", PasswordHash: hashedPassword}
+	admin := model.User{ID: uuid.New(), Email: "admin@mail.id", PasswordHash: hashedPassword, IsAdmin: true}
+
+	testCases := []struct {
+		name            string
+		user            model.User
+		req             model.LoginRequest
+		expectedAccess  time.Duration
+		expectedRefresh time.Duration
+	}{
+		{name: "Default", user: user, expectedAccess: 15 * time.Minute, expectedRefresh: 24 * time.Hour},
+		{name: "Remember Me", user: user, req: model.LoginRequest{RememberMe: true}, expectedAccess: 15 * time.Minute, expectedRefresh: 30 * 24 * time.Hour},
+		{name: "Client", user: user, req: model.LoginRequest{ClientID: "mobile"}, expectedAccess: 15 * time.Minute, expectedRefresh: 7 * 24 * time.Hour},
+		{name: "Role Overrides Client", user: admin, req: model.LoginRequest{ClientID: "mobile"}, expectedAccess: 5 * time.Minute, expectedRefresh: time.Hour},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockUserRepo := mocks.NewMockUserRepository(ctrl)
+			mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), tc.user.Email).Return(tc.user, nil)
+			mockUserRepo.EXPECT().FindUserByID(gomock.Any(), tc.user.ID).Return(tc.user, nil)
+			userService := NewUserService(mockUserRepo, newTxManager(ctrl, mockUserRepo), testJWT, WithTokenConfig(tokens))
+
+			tc.req.Email, tc.req.Password = tc.user.Email, "password123"
+			login, err := userService.Login(context.Background(), tc.req)
+			assert.NoError(t, err)
+			accessClaims, err := utils.ValidateAccessToken(login.AccessToken, testJWT)
+			assert.NoError(t, err)
+			assert.WithinDuration(t, time.Now().Add(tc.expectedAccess), accessClaims.ExpiresAt.Time, 2*time.Second)
+			refreshClaims, err := utils.ValidateRefreshToken(login.RefreshToken, testJWT)
+			assert.NoError(t, err)
+			assert.WithinDuration(t, time.Now().Add(tc.expectedRefresh), refreshClaims.ExpiresAt.Time, 2*time.Second)
+
+			// refreshing keeps the client and remember me choice
+			refreshed, err := userService.Refresh(context.Background(), model.RefreshTokenRequest{RefreshToken: login.RefreshToken})
+			assert.NoError(t, err)
+			newClaims, err := utils.ValidateRefreshToken(refreshed.RefreshToken, testJWT)
+			assert.NoError(t, err)
+			oldSession, err := refreshClaims.Session()
+			assert.NoError(t, err)
+			newSession, err := newClaims.Session()
+			assert.NoError(t, err)
+			assert.Equal(t, oldSession, newSession)
+			assert.NotEqual(t, refreshClaims.ID, newClaims.ID)
+		})
+	}
+}
+
+func TestUserService_Refresh_Replay(t *testing.T) {
+	testUser := model.User{ID: uuid.New(), Username: "testuser"}
+	_, refreshToken, err := utils.GenerateJWT(testUser, testJWT, testSession())
+	assert.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockUserRepo.EXPECT().FindUserByID(gomock.Any(), testUser.ID).Return(testUser, nil).Times(1)
+	userService := NewUserService(mockUserRepo, newTxManager(ctrl, mockUserRepo), testJWT, WithDenylist(cache.NewDenylist(cache.NewLRU(10))))
+
+	res, err := userService.Refresh(context.Background(), model.RefreshTokenRequest{RefreshToken: refreshToken})
+	assert.NoError(t, err)
+
+	// the used token is retired, while its replacement works
+	_, err = userService.Refresh(context.Background(), model.RefreshTokenRequest{RefreshToken: refreshToken})
+	assert.ErrorIs(t, err, utils.ErrInvalidToken)
+	mockUserRepo.EXPECT().FindUserByID(gomock.Any(), testUser.ID).Return(testUser, nil)
+	_, err = userService.Refresh(context.Background(), model.RefreshTokenRequest{RefreshToken: res.RefreshToken})
+	assert.NoError(t, err)
+}
+
+func TestUserService_Refresh_Concurrent(t *testing.T) {
+	testUser := model.User{ID: uuid.New(), Username: "testuser"}
+	_, refreshToken, err := utils.GenerateJWT(testUser, testJWT, testSession())
+	assert.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockUserRepo.EXPECT().FindUserByID(gomock.Any(), testUser.ID).Return(testUser, nil).AnyTimes()
+	userService := NewUserService(mockUserRepo, newTxManager(ctrl, mockUserRepo), testJWT, WithDenylist(cache.NewDenylist(cache.NewLRU(10))))
+
+	// concurrent refreshes may all pass the revocation check, yet only one
+	// gets new tokens
+	const refreshes = 20
+	var wg sync.WaitGroup
+	var succeeded atomic.Int32
+	for range refreshes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := userService.Refresh(context.Background(), model.RefreshTokenRequest{RefreshToken: refreshToken})
+			if err == nil {
+				succeeded.Add(1)
+			} else {
+				assert.ErrorIs(t, err, utils.ErrInvalidToken)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), succeeded.Load())
+}
+
+func TestUserService_Refresh_Revoked(t *testing.T) {
+	testUser := model.User{ID: uuid.New(), Username: "testuser"}
+	_, refreshToken, err := utils.GenerateJWT(testUser, testJWT, testSession())
+	assert.NoError(t, err)
+	claims, err := utils.ValidateRefreshToken(refreshToken, testJWT)
+	assert.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	denylist := cache.NewDenylist(cache.NewLRU(10))
+	userService := NewUserService(mockUserRepo, newTxManager(ctrl, mockUserRepo), testJWT, WithDenylist(denylist))
+
+	assert.NoError(t, denylist.Revoke(context.Background(), claims.ID, claims.ExpiresAt.Time))
+
+	_, err = userService.Refresh(context.Background(), model.RefreshTokenRequest{RefreshToken: refreshToken})
+	assert.ErrorIs(t, err, utils.ErrInvalidToken)
+}
+
+func TestUserService_Logout(t *testing.T) {
+	testUser := model.User{ID: uuid.New(), Username: "testuser"}
+	accessToken, refreshToken, err := utils.GenerateJWT(testUser, testJWT, testSession())
+	assert.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	denylist := cache.NewDenylist(cache.NewLRU(10))
+	userService := NewUserService(mockUserRepo, newTxManager(ctrl, mockUserRepo), testJWT, WithDenylist(denylist))
+
+	err = userService.Logout(context.Background(), model.RefreshTokenRequest{RefreshToken: accessToken})
+	assert.ErrorIs(t, err, utils.ErrInvalidToken)
+
+	assert.NoError(t, userService.Logout(context.Background(), model.RefreshTokenRequest{RefreshToken: refreshToken}))
+
+	// the session can no longer be refreshed
+	_, err = userService.Refresh(context.Background(), model.RefreshTokenRequest{RefreshToken: refreshToken})
+	assert.ErrorIs(t, err, utils.ErrInvalidToken)
+}
+
+func TestUserService_Introspect(t *testing.T) {
+	testUser := model.User{ID: uuid.New(), Username: "testuser"}
+	accessToken, refreshToken, err := utils.GenerateJWT(testUser, testJWT, testSession())
+	assert.NoError(t, err)
+	_, revokedToken, err := utils.GenerateJWT(testUser, testJWT, testSession())
+	assert.NoError(t, err)
+	revoked, err := utils.ValidateRefreshToken(revokedToken, testJWT)
+	assert.NoError(t, err)
+	disabledAt := time.Now()
+
+	testCases := []struct {
+		name         string
+		token        string
+		mockRepo     func(mock *mocks.MockUserRepository)
+		expectedType string
+	}{
+		{
+			name:  "Access Token",
+			token: accessToken,
+			mockRepo: func(mock *mocks.MockUserRepository) {
+				mock.EXPECT().FindUserByID(gomock.Any(), testUser.ID).Return(testUser, nil)
+			},
+			expectedType: utils.TokenTypeAccess,
+		},
+		{
+			name:  "Refresh Token",
+			token: refreshToken,
+			mockRepo: func(mock *mocks.MockUserRepository) {
+				mock.EXPECT().FindUserByID(gomock.Any(), testUser.ID).Return(testUser, nil)
+			},
+			expectedType: utils.TokenTypeRefresh,
+		},
+		{name: "Revoked Refresh Token", token: revokedToken, mockRepo: func(mock *mocks.MockUserRepository) {}},
+		{name: "Garbage", token: "not-a-jwt", mockRepo: func(mock *mocks.MockUserRepository) {}},
+		{
+			name:  "Disabled User",
+			token: accessToken,
+			mockRepo: func(mock *mocks.MockUserRepository) {
+				mock.EXPECT().FindUserByID(gomock.Any(), testUser.ID).Return(model.User{ID: testUser.ID, DisabledAt: &disabledAt}, nil)
+			},
+		},
+		{
+			name:  "Deleted User",
+			token: accessToken,
+			mockRepo: func(mock *mocks.MockUserRepository) {
+				mock.EXPECT().FindUserByID(gomock.Any(), testUser.ID).Return(model.User{}, gorm.ErrRecordNotFound)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockUserRepo := mocks.NewMockUserRepository(ctrl)
+			tc.mockRepo(mockUserRepo)
+			denylist := cache.NewDenylist(cache.NewLRU(10))
+			assert.NoError(t, denylist.Revoke(context.Background(), revoked.ID, revoked.ExpiresAt.Time))
+			userService := NewUserService(mockUserRepo, newTxManager(ctrl, mockUserRepo), testJWT, WithDenylist(denylist))
+
+			res, err := userService.Introspect(context.Background(), tc.token)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedType != "", res.Active)
+			assert.Equal(t, tc.expectedType, res.TokenType)
+			if res.Active {
+				assert.Equal(t, testUser.ID.String(), res.Subject)
+				assert.Equal(t, "testuser", res.Username)
+				assert.NotZero(t, res.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestUserService_OperatorActions(t *testing.T) {
+	testUser := model.User{
+		ID:    uuid.New(),
+		Email: "ops@example.com",
+	}
+
+	testCases := []struct {
+		name        string
+		run         func(s UserService) error
+		mockRepo    func(mock *mocks.MockUserRepository)
+		expectedErr error
+	}{
+		{
+			name: "Promote",
+			run: func(s UserService) error {
+				return s.PromoteUser(context.Background(), "Ops@Example.com")
+			},
+			mockRepo: func(mock *mocks.MockUserRepository) {
+				mock.EXPECT().ListUsersByEmail(gomock.Any(), "ops@example.com", 2).Return([]model.User{testUser}, nil)
+				mock.EXPECT().UpdateUserById(gomock.Any(), testUser.ID, model.User{IsAdmin: true}).Return(nil)
+			},
+		},
+		{
+			name: "Reset Password",
+			run: func(s UserService) error {
+				return s.ResetPassword(context.Background(), "ops@example.com", "newpassword")
+			},
+			mockRepo: func(mock *mocks.MockUserRepository) {
+				mock.EXPECT().ListUsersByEmail(gomock.Any(), "ops@example.com", 2).Return([]model.User{testUser}, nil)
+				mock.EXPECT().UpdateUserById(gomock.Any(), testUser.ID, gomock.Any()).DoAndReturn(func(ctx context.Context, id uuid.UUID, user model.User) error {
+					assert.True(t, utils.CheckPasswordHash("newpassword", user.PasswordHash))
+					return nil
+				})
+			},
+		},
+		{
+			name: "Disable",
+			run: func(s UserService) error {
+				return s.DisableUser(context.Background(), "ops@example.com")
+			},
+			mockRepo: func(mock *mocks.MockUserRepository) {
+				mock.EXPECT().ListUsersByEmail(gomock.Any(), "ops@example.com", 2).Return([]model.User{testUser}, nil)
+				mock.EXPECT().UpdateUserById(gomock.Any(), testUser.ID, gomock.Any()).DoAndReturn(func(ctx context.Context, id uuid.UUID, user model.User) error {
+					assert.NotNil(t, user.DisabledAt)
+					return nil
+				})
+			},
+		},
+		{
+			name: "Delete",
+			run: func(s UserService) error {
+				return s.DeleteUser(context.Background(), "ops@example.com")
+			},
+			mockRepo: func(mock *mocks.MockUserRepository) {
+				mock.EXPECT().ListUsersByEmail(gomock.Any(), "ops@example.com", 2).Return([]model.User{testUser}, nil)
+				mock.EXPECT().DeleteUser(gomock.Any(), testUser.ID).Return(nil)
+			},
+		},
+		{
+			name: "User Not Found",
+			run: func(s UserService) error {
+				return s.DisableUser(context.Background(), "missing@example.com")
+			},
+			mockRepo: func(mock *mocks.MockUserRepository) {
+				mock.EXPECT().ListUsersByEmail(gomock.Any(), "missing@example.com", 2).Return(nil, assert.AnError)
+			},
+			expectedErr: assert.AnError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockUserRepo := mocks.NewMockUserRepository(ctrl)
+			tc.mockRepo(mockUserRepo)
+
+			userService := NewUserService(mockUserRepo, newTxManager(ctrl, mockUserRepo), testJWT)
+
+			err := tc.run(userService)
+
+			if tc.expectedErr != nil {
+				assert.Equal(t, tc.expectedErr, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository"
+	"github.com/kevinmarcellius/go-simple-auth/internal/tracing"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+	"github.com/kevinmarcellius/go-simple-auth/internal/webhook"
+)
+
+const maxWebhookDeliveries = 100
+
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+type WebhookService interface {
+	CreateSubscription(ctx context.Context, req model.CreateWebhookRequest) (model.CreateWebhookResponse, error)
+	ListSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id uuid.UUID) error
+	// ListDeliveries returns the latest delivery attempts to a subscription.
+	ListDeliveries(ctx context.Context, id uuid.UUID) ([]model.WebhookDelivery, error)
+}
+
+type webhookService struct {
+	webhookRepo repository.WebhookRepository
+	// resolver checks subscriber hosts unless allowPrivate is set
+	resolver     webhook.Resolver
+	allowPrivate bool
+}
+
+func NewWebhookService(webhookRepo repository.WebhookRepository, cfg config.WebhookConfig) WebhookService {
+	return &webhookService{
+		webhookRepo:  webhookRepo,
+		resolver:     net.DefaultResolver,
+		allowPrivate: cfg.AllowPrivateNetworks,
+	}
+}
+
+func (s *webhookService) CreateSubscription(ctx context.Context, req model.CreateWebhookRequest) (_ model.CreateWebhookResponse, err error) {
+	ctx, span := tracer.Start(ctx, "webhookService.CreateSubscription")
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.validateWebhook(ctx, req); err != nil {
+		return model.CreateWebhookResponse{}, err
+	}
+	secret, err := utils.RandomToken(32)
+	if err != nil {
+		return model.CreateWebhookResponse{}, err
+	}
+
+	now := time.Now()
+	sub := model.WebhookSubscription{
+		ID:         uuid.New(),
+		URL:        req.URL,
+		Secret:     secret,
+		EventTypes: req.EventTypes,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := s.webhookRepo.CreateSubscription(ctx, sub); err != nil {
+		return model.CreateWebhookResponse{}, err
+	}
+	return model.CreateWebhookResponse{WebhookSubscription: sub, Secret: secret}, nil
+}
+
+func (s *webhookService) ListSubscriptions(ctx context.Context) (_ []model.WebhookSubscription, err error) {
+	ctx, span := tracer.Start(ctx, "webhookService.ListSubscriptions")
+	defer func() { tracing.End(span, err) }()
+
+	return s.webhookRepo.ListSubscriptions(ctx)
+}
+
+func (s *webhookService) DeleteSubscription(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := tracer.Start(ctx, "webhookService.DeleteSubscription")
+	defer func() { tracing.End(span, err) }()
+
+	return s.webhookRepo.DeleteSubscription(ctx, id)
+}
+
+func (s *webhookService) ListDeliveries(ctx context.Context, id uuid.UUID) (_ []model.WebhookDelivery, err error) {
+	ctx, span := tracer.Start(ctx, "webhookService.ListDeliveries")
+	defer func() { tracing.End(span, err) }()
+
+	return s.webhookRepo.ListDeliveries(ctx, id, maxWebhookDeliveries)
+}
+
+func (s *webhookService) validateWebhook(ctx context.Context, req model.CreateWebhookRequest) error {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+	if !s.allowPrivate {
+		if err := webhook.CheckHost(ctx, s.resolver, u.Hostname()); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidWebhook, err)
+		}
+	}
+	if len(req.EventTypes) == 0 {
+		return fmt.Errorf("%w: event_types must not be empty", ErrInvalidWebhook)
+	}
+	for _, t := range req.EventTypes {
+		if !slices.Contains(model.WebhookEventTypes, t) {
+			return fmt.Errorf("%w: unknown event type %q", ErrInvalidWebhook, t)
+		}
+	}
+	return nil
+}
+
+// enqueueUserEvent adds a user event to the webhook outbox in the
+// transaction of repos, so it is only delivered if the change commits.
+func enqueueUserEvent(ctx context.Context, repos repository.Repositories, eventType string, user model.User) error {
+	return repos.Webhooks.Enqueue(ctx, model.WebhookEvent{
+		ID:         uuid.New(),
+		Type:       eventType,
+		OccurredAt: time.Now().UTC().Truncate(time.Microsecond),
+		Data:       model.WebhookUserData{UserID: user.ID, Username: user.Username, Email: user.Email},
+	})
+}
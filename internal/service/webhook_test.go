@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository/mocks"
+	"github.com/kevinmarcellius/go-simple-auth/internal/webhook"
+)
+
+// staticResolver resolves the hosts it knows, like a DNS server would.
+type staticResolver map[string]string
+
+func (r staticResolver) LookupNetIP(_ context.Context, _, host string) ([]netip.Addr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr}, nil
+	}
+	addr, ok := r[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return []netip.Addr{netip.MustParseAddr(addr)}, nil
+}
+
+func TestWebhookService_CreateSubscription(t *testing.T) {
+	testCases := []struct {
+		name        string
+		req         model.CreateWebhookRequest
+		mockRepo    func(mock *mocks.MockWebhookRepository)
+		expectedErr error
+	}{
+		{
+			name: "Success",
+			req:  model.CreateWebhookRequest{URL: "https://hooks.example.com/auth", EventTypes: []string{model.WebhookUserRegistered}},
+			mockRepo: func(mock *mocks.MockWebhookRepository) {
+				mock.EXPECT().CreateSubscription(gomock.Any(), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name:        "Invalid URL",
+			req:         model.CreateWebhookRequest{URL: "ftp://hooks.example.com", EventTypes: []string{model.WebhookUserRegistered}},
+			mockRepo:    func(mock *mocks.MockWebhookRepository) {},
+			expectedErr: ErrInvalidWebhook,
+		},
+		{
+			name:        "Loopback Address",
+			req:         model.CreateWebhookRequest{URL: "http://127.0.0.1:8080/auth", EventTypes: []string{model.WebhookUserRegistered}},
+			mockRepo:    func(mock *mocks.MockWebhookRepository) {},
+			expectedErr: webhook.ErrForbiddenAddress,
+		},
+		{
+			name:        "Cloud Metadata Address",
+			req:         model.CreateWebhookRequest{URL: "http://169.254.169.254/latest/meta-data", EventTypes: []string{model.WebhookUserRegistered}},
+			mockRepo:    func(mock *mocks.MockWebhookRepository) {},
+			expectedErr: webhook.ErrForbiddenAddress,
+		},
+		{
+			name:        "Host Resolving To Private Address",
+			req:         model.CreateWebhookRequest{URL: "https://internal.example.com/auth", EventTypes: []string{model.WebhookUserRegistered}},
+			mockRepo:    func(mock *mocks.MockWebhookRepository) {},
+			expectedErr: webhook.ErrForbiddenAddress,
+		},
+		{
+			name:        "Unresolvable Host",
+			req:         model.CreateWebhookRequest{URL: "https://nowhere.example.com/auth", EventTypes: []string{model.WebhookUserRegistered}},
+			mockRepo:    func(mock *mocks.MockWebhookRepository) {},
+			expectedErr: ErrInvalidWebhook,
+		},
+		{
+			name:        "Unknown Event Type",
+			req:         model.CreateWebhookRequest{URL: "https://hooks.example.com/auth", EventTypes: []string{"user.exploded"}},
+			mockRepo:    func(mock *mocks.MockWebhookRepository) {},
+			expectedErr: ErrInvalidWebhook,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockWebhookRepository(ctrl)
+			tc.mockRepo(mockRepo)
+
+			webhookService := NewWebhookService(mockRepo, config.Default().Webhook).(*webhookService)
+			webhookService.resolver = staticResolver{
+				"hooks.example.com":    "93.184.215.14",
+				"internal.example.com": "10.0.3.7",
+			}
+			res, err := webhookService.CreateSubscription(context.Background(), tc.req)
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, res.Secret)
+				assert.Equal(t, res.Secret, res.WebhookSubscription.Secret)
+			}
+		})
+	}
+}
+
+func TestUserService_EnqueuesWebhookOnRegistration(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), "alice@example.com").Return(model.User{}, gorm.ErrRecordNotFound)
+	mockUserRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(nil)
+	txManager := newTxManager(ctrl, mockUserRepo)
+
+	var events []model.WebhookEvent
+	mockWebhookRepo := mocks.NewMockWebhookRepository(ctrl)
+	mockWebhookRepo.EXPECT().Enqueue(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, event model.WebhookEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	txManager.Repos.Webhooks = mockWebhookRepo
+
+	userService := NewUserService(mockUserRepo, txManager, testJWT)
+	_, err := userService.CreateUser(context.Background(), model.UserRequest{Username: "Alice", Email: "Alice@Example.com", Password: "password123"})
+
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, model.WebhookUserRegistered, events[0].Type)
+		data := events[0].Data.(model.WebhookUserData)
+		assert.Equal(t, "alice", data.Username)
+		assert.Equal(t, "alice@example.com", data.Email)
+	}
+}
@@ -0,0 +1,102 @@
+// Package tenant resolves the organization a request belongs to and carries
+// it in the request context, where repositories scope their queries by it.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+)
+
+// Tenant is the organization a request belongs to.
+type Tenant struct {
+	ID   uuid.UUID
+	Slug string
+}
+
+// Default is the tenant of single-tenant deployments.
+var Default = Tenant{ID: model.DefaultOrganizationID, Slug: model.DefaultOrganizationSlug}
+
+type contextKey struct{}
+
+func WithTenant(ctx context.Context, t Tenant) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// WithoutTenant returns a copy of ctx carrying no tenant, for lookups
+// across all organizations.
+func WithoutTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKey{}, nil)
+}
+
+// FromContext returns the tenant of ctx. Requests outside tenant-scoped
+// routes and operator commands have none, and see every organization.
+func FromContext(ctx context.Context) (Tenant, bool) {
+	t, ok := ctx.Value(contextKey{}).(Tenant)
+	return t, ok
+}
+
+// FindFunc looks an organization up by slug, returning
+// gorm.ErrRecordNotFound for unknown ones.
+type FindFunc func(ctx context.Context, slug string) (model.Organization, error)
+
+// Middleware resolves the tenant of each request as configured by cfg and
+// stores it in the request context. Requests naming no organization are
+// rejected with 400 and unknown organizations with 404.
+func Middleware(cfg config.TenancyConfig, find FindFunc) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			t := Default
+			if cfg.Mode != "single" {
+				slug := Slug(cfg, req)
+				if slug == "" {
+					return c.JSON(http.StatusBadRequest, map[string]string{"error": "Missing organization"})
+				}
+				org, err := find(req.Context(), slug)
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return c.JSON(http.StatusNotFound, map[string]string{"error": "Unknown organization"})
+				}
+				if err != nil {
+					return err
+				}
+				t = Tenant{ID: org.ID, Slug: org.Slug}
+			}
+			c.SetRequest(req.WithContext(WithTenant(req.Context(), t)))
+			return next(c)
+		}
+	}
+}
+
+// Slug returns the organization slug named by req, or "" if it names none
+// or an invalid one.
+func Slug(cfg config.TenancyConfig, req *http.Request) string {
+	var slug string
+	switch cfg.Mode {
+	case "header":
+		slug = strings.ToLower(strings.TrimSpace(req.Header.Get(cfg.Header)))
+	case "subdomain":
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		suffix := "." + strings.ToLower(cfg.BaseDomain)
+		host = strings.ToLower(host)
+		if strings.HasSuffix(host, suffix) {
+			slug = strings.TrimSuffix(host, suffix)
+		}
+	}
+	if !model.ValidSlug(slug) {
+		return ""
+	}
+	return slug
+}
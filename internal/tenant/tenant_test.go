@@ -0,0 +1,70 @@
+package tenant
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+)
+
+func TestMiddleware(t *testing.T) {
+	acme := model.Organization{ID: uuid.New(), Slug: "acme"}
+	find := func(ctx context.Context, slug string) (model.Organization, error) {
+		if slug == acme.Slug {
+			return acme, nil
+		}
+		return model.Organization{}, gorm.ErrRecordNotFound
+	}
+	header := config.TenancyConfig{Mode: "header", Header: "X-Organization"}
+	subdomain := config.TenancyConfig{Mode: "subdomain", BaseDomain: "auth.example.com"}
+
+	testCases := []struct {
+		name           string
+		cfg            config.TenancyConfig
+		host           string
+		header         string
+		expectedCode   int
+		expectedTenant Tenant
+	}{
+		{name: "Single", cfg: config.TenancyConfig{Mode: "single"}, header: "acme", expectedCode: http.StatusOK, expectedTenant: Default},
+		{name: "Header", cfg: header, header: "Acme", expectedCode: http.StatusOK, expectedTenant: Tenant{ID: acme.ID, Slug: "acme"}},
+		{name: "Header Missing", cfg: header, expectedCode: http.StatusBadRequest},
+		{name: "Header Invalid", cfg: header, header: "acme.evil", expectedCode: http.StatusBadRequest},
+		{name: "Header Unknown", cfg: header, header: "globex", expectedCode: http.StatusNotFound},
+		{name: "Subdomain", cfg: subdomain, host: "acme.auth.example.com:8443", expectedCode: http.StatusOK, expectedTenant: Tenant{ID: acme.ID, Slug: "acme"}},
+		{name: "Subdomain Nested", cfg: subdomain, host: "a.acme.auth.example.com", expectedCode: http.StatusBadRequest},
+		{name: "Base Domain", cfg: subdomain, host: "auth.example.com", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.host != "" {
+				req.Host = tc.host
+			}
+			if tc.header != "" {
+				req.Header.Set("X-Organization", tc.header)
+			}
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+
+			err := Middleware(tc.cfg, find)(func(c echo.Context) error {
+				got, ok := FromContext(c.Request().Context())
+				assert.True(t, ok)
+				assert.Equal(t, tc.expectedTenant, got)
+				return c.NoContent(http.StatusOK)
+			})(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	_ "embed"
+	"errors"
+	"strings"
+)
+
+var ErrEmailDomainNotAllowed = errors.New("EMAIL_DOMAIN_NOT_ALLOWED")
+
+//go:embed disposable_domains.txt
+var disposableList string
+
+// disposableDomains are the domains of well-known disposable email providers.
+var disposableDomains = func() map[string]bool {
+	domains := make(map[string]bool)
+	for _, line := range strings.Split(disposableList, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			domains[strings.ToLower(line)] = true
+		}
+	}
+	return domains
+}()
+
+// DomainPolicy decides which email domains may register. A domain listed in
+// Allowed or Blocked also covers its subdomains.
+type DomainPolicy struct {
+	// Allowed, if not empty, are the only domains accepted.
+	Allowed []string
+	Blocked []string
+	// BlockDisposable rejects the domains of disposable email providers.
+	BlockDisposable bool
+}
+
+// Check returns ErrEmailDomainNotAllowed unless the domain of email, which
+// must be normalized, may register.
+func (p DomainPolicy) Check(email string) error {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ErrInvalidEmail
+	}
+	domain := email[at+1:]
+
+	if matchDomain(domain, p.Blocked) {
+		return ErrEmailDomainNotAllowed
+	}
+	if p.BlockDisposable && IsDisposableDomain(domain) {
+		return ErrEmailDomainNotAllowed
+	}
+	if len(p.Allowed) > 0 && !matchDomain(domain, p.Allowed) {
+		return ErrEmailDomainNotAllowed
+	}
+	return nil
+}
+
+// IsDisposableDomain reports whether domain, or a domain it is a subdomain
+// of, belongs to a disposable email provider.
+func IsDisposableDomain(domain string) bool {
+	for d := strings.ToLower(domain); d != ""; {
+		if disposableDomains[d] {
+			return true
+		}
+		_, parent, ok := strings.Cut(d, ".")
+		if !ok {
+			return false
+		}
+		d = parent
+	}
+	return false
+}
+
+func matchDomain(domain string, list []string) bool {
+	for _, d := range list {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
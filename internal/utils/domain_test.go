@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDomainPolicy_Check(t *testing.T) {
+	testCases := []struct {
+		name        string
+		email       string
+		policy      DomainPolicy
+		expectedErr error
+	}{
+		{
+			name:  "No Rules",
+			email: "bob@mailinator.com",
+		},
+		{
+			name:        "Blocked",
+			email:       "bob@spam.example",
+			policy:      DomainPolicy{Blocked: []string{"spam.example"}},
+			expectedErr: ErrEmailDomainNotAllowed,
+		},
+		{
+			name:        "Blocked Subdomain",
+			email:       "bob@eu.spam.example",
+			policy:      DomainPolicy{Blocked: []string{"spam.example"}},
+			expectedErr: ErrEmailDomainNotAllowed,
+		},
+		{
+			name:   "Suffix Is Not A Subdomain",
+			email:  "bob@notspam.example",
+			policy: DomainPolicy{Blocked: []string{"spam.example"}},
+		},
+		{
+			name:   "Allowed",
+			email:  "bob@acme.com",
+			policy: DomainPolicy{Allowed: []string{"Acme.com"}},
+		},
+		{
+			name:        "Not Allowed",
+			email:       "bob@x.com",
+			policy:      DomainPolicy{Allowed: []string{"acme.com"}},
+			expectedErr: ErrEmailDomainNotAllowed,
+		},
+		{
+			name:        "Blocked Wins Over Allowed",
+			email:       "bob@contractors.acme.com",
+			policy:      DomainPolicy{Allowed: []string{"acme.com"}, Blocked: []string{"contractors.acme.com"}},
+			expectedErr: ErrEmailDomainNotAllowed,
+		},
+		{
+			name:        "Disposable",
+			email:       "bob@mailinator.com",
+			policy:      DomainPolicy{BlockDisposable: true},
+			expectedErr: ErrEmailDomainNotAllowed,
+		},
+		{
+			name:   "Not Disposable",
+			email:  "bob@x.com",
+			policy: DomainPolicy{BlockDisposable: true},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedErr, tc.policy.Check(tc.email))
+		})
+	}
+}
+
+func TestIsDisposableDomain(t *testing.T) {
+	assert.True(t, IsDisposableDomain("yopmail.com"))
+	assert.True(t, IsDisposableDomain("inbox.Guerrillamail.com"))
+	assert.False(t, IsDisposableDomain("gmail.com"))
+	assert.False(t, IsDisposableDomain("com"))
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+)
+
+// TokenTypeInvitation is the typ of invitation tokens, which can't be used
+// as access or refresh tokens.
+const TokenTypeInvitation = "invitation"
+
+// InvitationClaims identify an invitation: its ID is the subject and its
+// current TokenID the jti.
+type InvitationClaims struct {
+	Type     string `json:"typ"`
+	Email    string `json:"email"`
+	TenantID string `json:"tenant_id"`
+	jwt.RegisteredClaims
+}
+
+// GenerateInvitationToken signs a token accepting inv until it expires.
+func GenerateInvitationToken(inv model.Invitation, opts JWTOptions) (string, error) {
+	now := time.Now()
+	return sign(&InvitationClaims{
+		Type:     TokenTypeInvitation,
+		Email:    inv.Email,
+		TenantID: inv.OrgID.String(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        inv.TokenID.String(),
+			Issuer:    opts.Issuer,
+			Subject:   inv.ID.String(),
+			Audience:  jwt.ClaimStrings{opts.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(inv.ExpiresAt),
+		},
+	}, opts)
+}
+
+// ValidateInvitationToken is ValidateAccessToken for invitation tokens. It
+// doesn't check that the invitation is still pending.
+func ValidateInvitationToken(tokenString string, opts JWTOptions) (*InvitationClaims, error) {
+	claims := &InvitationClaims{}
+	if err := parseToken(tokenString, claims, opts); err != nil {
+		return nil, err
+	}
+	if claims.Type != TokenTypeInvitation {
+		return nil, fmt.Errorf("%w: not an invitation token", ErrInvalidToken)
+	}
+	if err := requireClaims(&claims.RegisteredClaims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
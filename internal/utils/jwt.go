@@ -1,34 +1,195 @@
 package utils
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/kevinmarcellius/go-simple-auth/config"
 	"github.com/kevinmarcellius/go-simple-auth/internal/model"
 )
 
+// Token types, carried in the typ claim so that one kind of token can't be
+// used in place of the other.
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+// JWTOptions are the settings shared by issuing and validating tokens.
+type JWTOptions struct {
+	// Key is the HS256 signing secret.
+	Key string
+	// Issuer and Audience are set on every token and required on validation.
+	Issuer   string
+	Audience string
+	// Leeway tolerates clock skew between servers when checking exp, nbf
+	// and iat.
+	Leeway time.Duration
+}
+
 type Claims struct {
 	UserID   string `json:"user_id"`
 	Username string `json:"username"`
 	Email    string `json:"email"`
 	IsAdmin  bool   `json:"isAdmin"`
+	Type     string `json:"typ"`
+	ClientID string `json:"client_id,omitempty"`
+	// TenantID and Org are the ID and slug of the organization the token
+	// was issued for; OrgRole is the user's role in it.
+	TenantID string `json:"tenant_id,omitempty"`
+	Org      string `json:"org,omitempty"`
+	OrgRole  string `json:"org_role,omitempty"`
+	// SessionID is the sid of the session the token belongs to.
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// OrgID returns the organization the token was issued for. Tokens from
+// before organizations existed belong to the default one.
+func (c *Claims) OrgID() (uuid.UUID, error) {
+	return OrgID(c.TenantID)
+}
+
+// RefreshClaims carry the session a refresh token belongs to, so that the
+// tokens issued on refresh keep its client, remember me choice and start.
 type RefreshClaims struct {
-	UserID string `json:"user_id"`
+	UserID     string           `json:"user_id"`
+	Type       string           `json:"typ"`
+	ClientID   string           `json:"client_id,omitempty"`
+	RememberMe bool             `json:"remember_me,omitempty"`
+	AuthTime   *jwt.NumericDate `json:"auth_time,omitempty"`
+	TenantID   string           `json:"tenant_id,omitempty"`
+	Org        string           `json:"org,omitempty"`
+	SessionID  string           `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(user model.User, jwtKey string) (string, string, error) {
+// OrgID parses a tenant_id claim, which is empty in tokens issued for the
+// default organization before organizations existed.
+func OrgID(tenantID string) (uuid.UUID, error) {
+	if tenantID == "" {
+		return model.DefaultOrganizationID, nil
+	}
+	id, err := uuid.Parse(tenantID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: malformed tenant_id claim", ErrInvalidToken)
+	}
+	return id, nil
+}
+
+// Session describes the login a token pair belongs to.
+type Session struct {
+	// ID identifies the session across refreshes. GenerateJWT starts a new
+	// one when it is empty.
+	ID string
+	// ClientID is the client_id given at login, empty for none.
+	ClientID   string
+	RememberMe bool
+	// AuthTime is when the user logged in.
+	AuthTime  time.Time
+	Lifetimes config.TokenLifetimes
+	// OrgID and Org identify the organization logged in to, and OrgRole is
+	// the user's role in it.
+	OrgID   uuid.UUID
+	Org     string
+	OrgRole string
+}
+
+// Session returns the session the refresh token belongs to, without its
+// lifetimes or role. Tokens without auth_time started their session when
+// issued, and those without tenant_id in the default organization.
+func (c *RefreshClaims) Session() (Session, error) {
+	session := Session{ID: c.SessionID, ClientID: c.ClientID, RememberMe: c.RememberMe, Org: c.Org}
+	if c.AuthTime != nil {
+		session.AuthTime = c.AuthTime.Time
+	} else if c.IssuedAt != nil {
+		session.AuthTime = c.IssuedAt.Time
+	}
+	id, err := OrgID(c.TenantID)
+	if err != nil {
+		return Session{}, err
+	}
+	session.OrgID = id
+	if c.TenantID == "" {
+		session.Org = model.DefaultOrganizationSlug
+	}
+	return session, nil
+}
+
+// tenantID is the tenant_id claim of the session's tokens.
+func (s Session) tenantID() string {
+	if s.OrgID == uuid.Nil {
+		return ""
+	}
+	return s.OrgID.String()
+}
+
+// refreshExpiry returns when a refresh token issued at now expires: after
+// the refresh or remember me lifetime, but no later than the session's
+// maximum.
+func (s Session) refreshExpiry(now time.Time) time.Time {
+	lifetime := s.Lifetimes.Refresh
+	if s.RememberMe && s.Lifetimes.RememberMe > 0 {
+		lifetime = s.Lifetimes.RememberMe
+	}
+	expiry := now.Add(lifetime)
+	if s.Lifetimes.MaxSession > 0 {
+		if end := s.AuthTime.Add(s.Lifetimes.MaxSession); end.Before(expiry) {
+			expiry = end
+		}
+	}
+	return expiry
+}
+
+// GenerateJWT issues an access and a refresh token for user in session. The
+// access token never outlives the refresh token. It fails with
+// ErrInvalidToken once the session has reached its maximum lifetime.
+func GenerateJWT(user model.User, opts JWTOptions, session Session) (string, string, error) {
+	now := time.Now()
+	refreshExpiry := session.refreshExpiry(now)
+	if !refreshExpiry.After(now) {
+		return "", "", fmt.Errorf("%w: session expired", ErrInvalidToken)
+	}
+	accessExpiry := now.Add(session.Lifetimes.Access)
+	if refreshExpiry.Before(accessExpiry) {
+		accessExpiry = refreshExpiry
+	}
+	if session.ID == "" {
+		session.ID = uuid.NewString()
+	}
+
 	// Generate access token
-	accessToken, err := generateAccessToken(user, jwtKey)
+	accessToken, err := sign(&Claims{
+		UserID:           user.ID.String(),
+		Username:         user.Username,
+		Email:            user.Email,
+		IsAdmin:          user.IsAdmin,
+		Type:             TokenTypeAccess,
+		ClientID:         session.ClientID,
+		TenantID:         session.tenantID(),
+		Org:              session.Org,
+		OrgRole:          session.OrgRole,
+		SessionID:        session.ID,
+		RegisteredClaims: registeredClaims(user, opts, now, accessExpiry),
+	}, opts)
 	if err != nil {
 		return "", "", err
 	}
 
 	// Generate refresh token
-	refreshToken, err := generateRefreshToken(user, jwtKey)
+	refreshToken, err := sign(&RefreshClaims{
+		UserID:           user.ID.String(),
+		Type:             TokenTypeRefresh,
+		ClientID:         session.ClientID,
+		RememberMe:       session.RememberMe,
+		AuthTime:         jwt.NewNumericDate(session.AuthTime),
+		TenantID:         session.tenantID(),
+		Org:              session.Org,
+		SessionID:        session.ID,
+		RegisteredClaims: registeredClaims(user, opts, now, refreshExpiry),
+	}, opts)
 	if err != nil {
 		return "", "", err
 	}
@@ -36,53 +197,87 @@ func GenerateJWT(user model.User, jwtKey string) (string, string, error) {
 	return accessToken, refreshToken, nil
 }
 
-func generateAccessToken(user model.User, jwtKey string) (string, error) {
-	expirationTime := time.Now().Add(15 * time.Minute)
-	claims := &Claims{
-		UserID:   user.ID.String(),
-		Username: user.Username,
-		Email:    user.Email,
-		IsAdmin:  user.IsAdmin,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-		},
-	}
-
+func sign(claims jwt.Claims, opts JWTOptions) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(jwtKey))
+	return token.SignedString([]byte(opts.Key))
 }
 
-func generateRefreshToken(user model.User, jwtKey string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
-	claims := &RefreshClaims{
-		UserID: user.ID.String(),
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-		},
+func registeredClaims(user model.User, opts JWTOptions, now, expiry time.Time) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		ID:        uuid.NewString(),
+		Issuer:    opts.Issuer,
+		Subject:   user.ID.String(),
+		Audience:  jwt.ClaimStrings{opts.Audience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiry),
 	}
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(jwtKey))
+// ValidateAccessToken checks the signature, algorithm, issuer, audience,
+// type and validity period of an access token.
+func ValidateAccessToken(tokenString string, opts JWTOptions) (*Claims, error) {
+	claims := &Claims{}
+	if err := parseToken(tokenString, claims, opts); err != nil {
+		return nil, err
+	}
+	if claims.Type != TokenTypeAccess {
+		return nil, fmt.Errorf("%w: not an access token", ErrInvalidToken)
+	}
+	if err := requireClaims(&claims.RegisteredClaims); err != nil {
+		return nil, err
+	}
+	return claims, nil
 }
 
-func ValidateRefreshToken(tokenString string, jwtKey string) (*RefreshClaims, error) {
+// ValidateRefreshToken is ValidateAccessToken for refresh tokens.
+func ValidateRefreshToken(tokenString string, opts JWTOptions) (*RefreshClaims, error) {
 	claims := &RefreshClaims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(jwtKey), nil
-	})
-
-	if err != nil {
+	if err := parseToken(tokenString, claims, opts); err != nil {
 		return nil, err
 	}
-
-	if !token.Valid {
+	if claims.Type != TokenTypeRefresh {
+		return nil, fmt.Errorf("%w: not a refresh token", ErrInvalidToken)
+	}
+	if err := requireClaims(&claims.RegisteredClaims); err != nil {
 		return nil, err
 	}
-
 	return claims, nil
 }
 
-func GenerateNewAccessToken(user model.User, jwtKey string) (string, error) {
-	return generateAccessToken(user, jwtKey)
+func parseToken(tokenString string, claims jwt.Claims, opts JWTOptions) error {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(opts.Issuer),
+		jwt.WithAudience(opts.Audience),
+		jwt.WithLeeway(opts.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	token, err := parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(opts.Key), nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	if !token.Valid {
+		return ErrInvalidToken
+	}
+	return nil
 }
 
+// requireClaims rejects tokens lacking registered claims that the parser
+// only checks when present.
+func requireClaims(claims *jwt.RegisteredClaims) error {
+	switch {
+	case claims.Subject == "":
+		return fmt.Errorf("%w: missing sub claim", ErrInvalidToken)
+	case claims.ID == "":
+		return fmt.Errorf("%w: missing jti claim", ErrInvalidToken)
+	case claims.IssuedAt == nil:
+		return fmt.Errorf("%w: missing iat claim", ErrInvalidToken)
+	case claims.NotBefore == nil:
+		return fmt.Errorf("%w: missing nbf claim", ErrInvalidToken)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+)
+
+var testJWT = JWTOptions{Key: "test-secret-key", Issuer: "test-issuer", Audience: "test-audience", Leeway: 30 * time.Second}
+
+var testLifetimes = config.TokenLifetimes{
+	Access:     15 * time.Minute,
+	Refresh:    24 * time.Hour,
+	RememberMe: 30 * 24 * time.Hour,
+	MaxSession: 60 * 24 * time.Hour,
+}
+
+func TestGenerateJWT(t *testing.T) {
+	user := model.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", IsAdmin: true}
+
+	access, refresh, err := GenerateJWT(user, testJWT, Session{AuthTime: time.Now(), Lifetimes: testLifetimes})
+	assert.NoError(t, err)
+
+	claims, err := ValidateAccessToken(access, testJWT)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID.String(), claims.Subject)
+	assert.Equal(t, "test-issuer", claims.Issuer)
+	assert.Equal(t, jwt.ClaimStrings{"test-audience"}, claims.Audience)
+	assert.Equal(t, TokenTypeAccess, claims.Type)
+	assert.True(t, claims.IsAdmin)
+	assert.NotEmpty(t, claims.ID)
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), claims.ExpiresAt.Time, 5*time.Second)
+
+	refreshClaims, err := ValidateRefreshToken(refresh, testJWT)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID.String(), refreshClaims.Subject)
+	assert.NotEqual(t, claims.ID, refreshClaims.ID)
+
+	// each token is only accepted as its own type
+	_, err = ValidateAccessToken(refresh, testJWT)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = ValidateRefreshToken(access, testJWT)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestGenerateJWT_Session(t *testing.T) {
+	user := model.User{ID: uuid.New()}
+	now := time.Now()
+
+	testCases := []struct {
+		name            string
+		session         Session
+		expectedAccess  time.Duration
+		expectedRefresh time.Duration
+		expectedErr     error
+	}{
+		{
+			name:            "Default",
+			session:         Session{AuthTime: now, Lifetimes: testLifetimes},
+			expectedAccess:  15 * time.Minute,
+			expectedRefresh: 24 * time.Hour,
+		},
+		{
+			name:            "Remember Me",
+			session:         Session{AuthTime: now, RememberMe: true, Lifetimes: testLifetimes},
+			expectedAccess:  15 * time.Minute,
+			expectedRefresh: 30 * 24 * time.Hour,
+		},
+		{
+			name:            "Remember Me Disabled",
+			session:         Session{AuthTime: now, RememberMe: true, Lifetimes: config.TokenLifetimes{Access: time.Minute, Refresh: time.Hour}},
+			expectedAccess:  time.Minute,
+			expectedRefresh: time.Hour,
+		},
+		{
+			name:            "Capped By Max Session",
+			session:         Session{AuthTime: now.Add(-60*24*time.Hour + time.Hour), Lifetimes: testLifetimes},
+			expectedAccess:  15 * time.Minute,
+			expectedRefresh: time.Hour,
+		},
+		{
+			name:            "Access Capped By Session",
+			session:         Session{AuthTime: now.Add(-60*24*time.Hour + 5*time.Minute), Lifetimes: testLifetimes},
+			expectedAccess:  5 * time.Minute,
+			expectedRefresh: 5 * time.Minute,
+		},
+		{
+			name:        "Session Expired",
+			session:     Session{AuthTime: now.Add(-61 * 24 * time.Hour), Lifetimes: testLifetimes},
+			expectedErr: ErrInvalidToken,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			access, refresh, err := GenerateJWT(user, testJWT, tc.session)
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+
+			accessClaims, err := ValidateAccessToken(access, testJWT)
+			assert.NoError(t, err)
+			assert.WithinDuration(t, now.Add(tc.expectedAccess), accessClaims.ExpiresAt.Time, 2*time.Second)
+
+			refreshClaims, err := ValidateRefreshToken(refresh, testJWT)
+			assert.NoError(t, err)
+			assert.WithinDuration(t, now.Add(tc.expectedRefresh), refreshClaims.ExpiresAt.Time, 2*time.Second)
+
+			// refreshing keeps the session
+			session, err := refreshClaims.Session()
+			assert.NoError(t, err)
+			assert.Equal(t, model.DefaultOrganizationID, session.OrgID)
+			assert.Equal(t, tc.session.RememberMe, session.RememberMe)
+			assert.WithinDuration(t, tc.session.AuthTime, session.AuthTime, time.Second)
+			assert.NotEmpty(t, session.ID)
+			assert.Equal(t, session.ID, accessClaims.SessionID)
+		})
+	}
+}
+
+func TestValidateAccessToken(t *testing.T) {
+	now := time.Now()
+	valid := func() *Claims {
+		return &Claims{
+			UserID: "user-1",
+			Type:   TokenTypeAccess,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        "jti-1",
+				Issuer:    "test-issuer",
+				Subject:   "user-1",
+				Audience:  jwt.ClaimStrings{"test-audience"},
+				IssuedAt:  jwt.NewNumericDate(now),
+				NotBefore: jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			},
+		}
+	}
+	sign := func(method jwt.SigningMethod, claims *Claims, key string) string {
+		token, err := jwt.NewWithClaims(method, claims).SignedString([]byte(key))
+		assert.NoError(t, err)
+		return token
+	}
+
+	testCases := []struct {
+		name    string
+		claims  func(c *Claims)
+		method  jwt.SigningMethod
+		key     string
+		wantErr bool
+	}{
+		{name: "Valid", claims: func(c *Claims) {}},
+		{name: "Wrong Key", claims: func(c *Claims) {}, key: "other-secret-key", wantErr: true},
+		{name: "Wrong Algorithm", claims: func(c *Claims) {}, method: jwt.SigningMethodHS512, wantErr: true},
+		{name: "Wrong Issuer", claims: func(c *Claims) { c.Issuer = "someone-else" }, wantErr: true},
+		{name: "Wrong Audience", claims: func(c *Claims) { c.Audience = jwt.ClaimStrings{"other-app"} }, wantErr: true},
+		{name: "Missing Type", claims: func(c *Claims) { c.Type = "" }, wantErr: true},
+		{name: "Missing Subject", claims: func(c *Claims) { c.Subject = "" }, wantErr: true},
+		{name: "Missing ID", claims: func(c *Claims) { c.ID = "" }, wantErr: true},
+		{name: "Missing Expiry", claims: func(c *Claims) { c.ExpiresAt = nil }, wantErr: true},
+		{name: "Missing Issued At", claims: func(c *Claims) { c.IssuedAt = nil }, wantErr: true},
+		{name: "Missing Not Before", claims: func(c *Claims) { c.NotBefore = nil }, wantErr: true},
+		{
+			name:   "Expired Within Leeway",
+			claims: func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-10 * time.Second)) },
+		},
+		{
+			name:    "Expired",
+			claims:  func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute)) },
+			wantErr: true,
+		},
+		{
+			name:    "Not Yet Valid",
+			claims:  func(c *Claims) { c.NotBefore = jwt.NewNumericDate(now.Add(time.Minute)) },
+			wantErr: true,
+		},
+		{
+			name:    "Issued In The Future",
+			claims:  func(c *Claims) { c.IssuedAt = jwt.NewNumericDate(now.Add(time.Minute)) },
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := valid()
+			tc.claims(claims)
+			method, key := tc.method, tc.key
+			if method == nil {
+				method = jwt.SigningMethodHS256
+			}
+			if key == "" {
+				key = testJWT.Key
+			}
+
+			_, err := ValidateAccessToken(sign(method, claims, key), testJWT)
+
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidToken)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestMagicLinkToken(t *testing.T) {
+	link := model.MagicLink{ID: uuid.New(), UserID: uuid.New(), OrgID: uuid.New(), ExpiresAt: time.Now().Add(15 * time.Minute)}
+
+	token, err := GenerateMagicLinkToken(link, testJWT)
+	assert.NoError(t, err)
+	claims, err := ValidateMagicLinkToken(token, testJWT)
+	assert.NoError(t, err)
+	assert.Equal(t, link.ID.String(), claims.ID)
+	assert.Equal(t, link.UserID.String(), claims.Subject)
+	assert.Equal(t, link.OrgID.String(), claims.TenantID)
+
+	// link tokens and session tokens can't stand in for each other
+	_, err = ValidateAccessToken(token, testJWT)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	accessToken, _, err := GenerateJWT(model.User{ID: link.UserID}, testJWT, Session{AuthTime: time.Now(), Lifetimes: testLifetimes})
+	assert.NoError(t, err)
+	_, err = ValidateMagicLinkToken(accessToken, testJWT)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	link.ExpiresAt = time.Now().Add(-time.Minute)
+	token, _ = GenerateMagicLinkToken(link, testJWT)
+	_, err = ValidateMagicLinkToken(token, testJWT)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+)
+
+// TokenTypeMagicLink is the typ of login link tokens, which can't be used as
+// access or refresh tokens.
+const TokenTypeMagicLink = "magic_link"
+
+// MagicLinkClaims identify a login link: the user is the subject and the
+// link's ID the jti.
+type MagicLinkClaims struct {
+	Type     string `json:"typ"`
+	TenantID string `json:"tenant_id"`
+	jwt.RegisteredClaims
+}
+
+// GenerateMagicLinkToken signs a token using link until it expires.
+func GenerateMagicLinkToken(link model.MagicLink, opts JWTOptions) (string, error) {
+	now := time.Now()
+	return sign(&MagicLinkClaims{
+		Type:     TokenTypeMagicLink,
+		TenantID: link.OrgID.String(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        link.ID.String(),
+			Issuer:    opts.Issuer,
+			Subject:   link.UserID.String(),
+			Audience:  jwt.ClaimStrings{opts.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(link.ExpiresAt),
+		},
+	}, opts)
+}
+
+// ValidateMagicLinkToken is ValidateAccessToken for login link tokens. It
+// doesn't check that the link is still unused.
+func ValidateMagicLinkToken(tokenString string, opts JWTOptions) (*MagicLinkClaims, error) {
+	claims := &MagicLinkClaims{}
+	if err := parseToken(tokenString, claims, opts); err != nil {
+		return nil, err
+	}
+	if claims.Type != TokenTypeMagicLink {
+		return nil, fmt.Errorf("%w: not a magic link token", ErrInvalidToken)
+	}
+	if err := requireClaims(&claims.RegisteredClaims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
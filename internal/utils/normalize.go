@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	ErrInvalidEmail = errors.New("INVALID_EMAIL")
+)
+
+// EmailPolicy controls the optional parts of email normalization.
+type EmailPolicy struct {
+	// StripPlusTag drops the "+tag" suffix from the local part, so
+	// bob+news@x.com and bob@x.com resolve to the same account.
+	StripPlusTag bool
+}
+
+// NormalizeUsername returns the canonical form of a username used for
+// storage and uniqueness checks: trimmed, NFKC-normalized and lowercased.
+func NormalizeUsername(username string) string {
+	return canonical(username)
+}
+
+// NormalizeEmail returns the canonical form of an email address used for
+// storage, lookups and uniqueness checks.
+func NormalizeEmail(email string, policy EmailPolicy) (string, error) {
+	email = canonical(email)
+
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 {
+		return "", ErrInvalidEmail
+	}
+	local, domain := email[:at], email[at+1:]
+
+	if policy.StripPlusTag {
+		if plus := strings.Index(local, "+"); plus >= 0 {
+			local = local[:plus]
+		}
+		if local == "" {
+			return "", ErrInvalidEmail
+		}
+	}
+
+	return local + "@" + domain, nil
+}
+
+func canonical(s string) string {
+	// NFKC first so compatibility characters (e.g. fullwidth letters) fold
+	// into their ASCII forms before lowercasing, then once more in case
+	// lowercasing produced a non-normalized sequence.
+	return norm.NFKC.String(strings.ToLower(norm.NFKC.String(strings.TrimSpace(s))))
+}
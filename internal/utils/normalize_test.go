@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	testCases := []struct {
+		name        string
+		email       string
+		policy      EmailPolicy
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "Trim And Lowercase",
+			email:    "  Bob@X.com ",
+			expected: "bob@x.com",
+		},
+		{
+			name:     "NFKC Fullwidth",
+			email:    "ｂｏｂ@ｘ.com",
+			expected: "bob@x.com",
+		},
+		{
+			name:     "Plus Tag Kept By Default",
+			email:    "Bob+News@x.com",
+			expected: "bob+news@x.com",
+		},
+		{
+			name:     "Plus Tag Stripped",
+			email:    "Bob+News@x.com",
+			policy:   EmailPolicy{StripPlusTag: true},
+			expected: "bob@x.com",
+		},
+		{
+			name:        "Empty Local Part After Strip",
+			email:       "+news@x.com",
+			policy:      EmailPolicy{StripPlusTag: true},
+			expectedErr: ErrInvalidEmail,
+		},
+		{
+			name:        "Missing At",
+			email:       "bob.x.com",
+			expectedErr: ErrInvalidEmail,
+		},
+		{
+			name:        "Missing Domain",
+			email:       "bob@",
+			expectedErr: ErrInvalidEmail,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := NormalizeEmail(tc.email, tc.policy)
+
+			if tc.expectedErr != nil {
+				assert.Equal(t, tc.expectedErr, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, res)
+			}
+		})
+	}
+}
+
+func TestNormalizeUsername(t *testing.T) {
+	assert.Equal(t, "helloworld", NormalizeUsername(" HelloWorld "))
+	assert.Equal(t, "helloworld", NormalizeUsername("ＨｅｌｌｏＷｏｒｌｄ"))
+}
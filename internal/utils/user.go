@@ -8,6 +8,8 @@ import (
 
 var (
 	ErrInvalidPassword = errors.New("INVALID_PASSWORD")
+	ErrUserDisabled    = errors.New("USER_DISABLED")
+	ErrInvalidToken    = errors.New("INVALID_TOKEN")
 )
 
 func HashPassword(password string) (string, error) {
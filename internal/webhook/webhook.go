@@ -0,0 +1,255 @@
+// Package webhook delivers queued user events to subscribers. Each request
+// is signed so receivers can check it came from this service.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository"
+)
+
+// Request headers
+const (
+	HeaderID        = "Webhook-Id"
+	HeaderEvent     = "Webhook-Event"
+	HeaderSignature = "Webhook-Signature"
+)
+
+// maxErrorBody bounds how much of a failed response is kept in the log.
+const maxErrorBody = 512
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Sign returns the Webhook-Signature header value for body sent at t:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<unix seconds>.<body>">".
+// Binding the timestamp lets receivers reject replayed requests.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + signature(secret, ts, body)
+}
+
+// Verify checks a Webhook-Signature header against body and rejects it when
+// it is older than tolerance. Receivers written in Go can use it directly.
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, ts, body))) {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+	return nil
+}
+
+func signature(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Worker polls the outbox and delivers due messages. Failed deliveries are
+// retried with exponential backoff until MaxAttempts is reached, after which
+// the message is marked failed. Several instances may run at once; each
+// message is claimed by one of them at a time.
+type Worker struct {
+	repo   repository.WebhookRepository
+	cfg    config.WebhookConfig
+	client *http.Client
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+func NewWorker(repo repository.WebhookRepository, cfg config.WebhookConfig, logger *slog.Logger) *Worker {
+	return &Worker{
+		repo: repo,
+		cfg:  cfg,
+		// redirects are not followed so a subscriber can't bounce requests
+		// to another host
+		client: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: transport(cfg),
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// transport connects to subscribers directly, never through a proxy, so
+// the dialer's address check applies to the subscriber itself.
+func transport(cfg config.WebhookConfig) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = dialer(cfg.Timeout, cfg.AllowPrivateNetworks).DialContext
+	return t
+}
+
+// Run delivers messages every PollInterval until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		// keep draining while full batches come back
+		for {
+			n, err := w.ProcessBatch(ctx)
+			if err != nil && ctx.Err() == nil {
+				w.logger.Error("webhook batch failed", slog.Any("error", err))
+			}
+			if err != nil || n < w.cfg.BatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recordTimeout bounds saving the outcome of a delivery.
+const recordTimeout = 5 * time.Second
+
+// ProcessBatch claims and delivers one batch of due messages and returns
+// how many were claimed.
+func (w *Worker) ProcessBatch(ctx context.Context) (int, error) {
+	// the batch is delivered one message after another, so the claim must
+	// outlive the longest delivery and record of every message in it
+	perMessage := w.cfg.Timeout + recordTimeout
+	lease := time.Duration(w.cfg.BatchSize)*perMessage + time.Minute
+	claimedAt := w.now()
+	msgs, err := w.repo.ClaimDue(ctx, claimedAt, lease, w.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	expiresAt := claimedAt.Add(lease)
+	var errs []error
+	for i, msg := range msgs {
+		// once another worker may claim them again, the rest are left for
+		// it rather than delivered twice
+		if w.now().Add(perMessage).After(expiresAt) {
+			w.logger.Warn("webhook batch outlived its claim",
+				slog.Int("claimed", len(msgs)),
+				slog.Int("left", len(msgs)-i))
+			break
+		}
+		errs = append(errs, w.process(ctx, msg))
+	}
+	return len(msgs), errors.Join(errs...)
+}
+
+func (w *Worker) process(ctx context.Context, msg model.WebhookMessage) error {
+	start := w.now()
+	delivery := model.WebhookDelivery{
+		ID:             uuid.New(),
+		MessageID:      msg.ID,
+		SubscriptionID: msg.SubscriptionID,
+		EventType:      msg.EventType,
+		Attempt:        msg.Attempts + 1,
+		AttemptedAt:    start,
+	}
+
+	var err error
+	if msg.Subscription == nil {
+		err = errors.New("subscription no longer exists")
+	} else {
+		delivery.StatusCode, err = w.deliver(ctx, msg)
+	}
+	delivery.DurationMS = w.now().Sub(start).Milliseconds()
+
+	msg.Attempts++
+	if err == nil {
+		msg.Status = model.WebhookDelivered
+		msg.DeliveredAt = &start
+		msg.LastError = ""
+	} else {
+		delivery.Error = err.Error()
+		msg.LastError = err.Error()
+		if msg.Attempts >= w.cfg.MaxAttempts || msg.Subscription == nil {
+			msg.Status = model.WebhookFailed
+		} else {
+			msg.NextAttemptAt = start.Add(w.backoff(msg.Attempts))
+		}
+		w.logger.Warn("webhook delivery failed",
+			slog.String("message_id", msg.ID.String()),
+			slog.String("event_type", msg.EventType),
+			slog.Int("attempt", msg.Attempts),
+			slog.String("status", msg.Status),
+			slog.Any("error", err))
+	}
+
+	// record with a fresh context so a shutdown mid-delivery is still saved
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+	return w.repo.RecordAttempt(recordCtx, msg, delivery)
+}
+
+func (w *Worker) deliver(ctx context.Context, msg model.WebhookMessage) (int, error) {
+	body := []byte(msg.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msg.Subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-simple-auth-webhooks")
+	// the event ID stays the same across retries, for receiver deduplication
+	req.Header.Set(HeaderID, msg.EventID.String())
+	req.Header.Set(HeaderEvent, msg.EventType)
+	req.Header.Set(HeaderSignature, Sign(msg.Subscription.Secret, w.now(), body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(snippet))
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
+	return resp.StatusCode, nil
+}
+
+// backoff returns the wait after the given number of failed attempts:
+// BackoffBase doubled after each failure, capped at BackoffMax.
+func (w *Worker) backoff(attempts int) time.Duration {
+	d := w.cfg.BackoffBase
+	for i := 1; i < attempts && d < w.cfg.BackoffMax; i++ {
+		d *= 2
+	}
+	return min(d, w.cfg.BackoffMax)
+}
@@ -0,0 +1,235 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository/mocks"
+)
+
+const testSecret = "whsec-test"
+
+func TestSignVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte(`{"type":"user.registered"}`)
+	header := Sign(testSecret, now, body)
+
+	assert.NoError(t, Verify(testSecret, header, body, now.Add(time.Minute), 5*time.Minute))
+	assert.ErrorIs(t, Verify(testSecret, header, []byte(`{"type":"user.disabled"}`), now, 5*time.Minute), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("other-secret", header, body, now, 5*time.Minute), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify(testSecret, header, body, now.Add(time.Hour), 5*time.Minute), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify(testSecret, "garbage", body, now, 5*time.Minute), ErrInvalidSignature)
+}
+
+func TestWorker_ProcessBatch(t *testing.T) {
+	cfg := config.Default().Webhook
+	// receivers listen on loopback
+	cfg.AllowPrivateNetworks = true
+	now := time.Now().UTC().Truncate(time.Second)
+
+	testCases := []struct {
+		name     string
+		status   int
+		attempts int
+		check    func(t *testing.T, msg model.WebhookMessage, delivery model.WebhookDelivery)
+	}{
+		{
+			name:   "Delivered",
+			status: http.StatusOK,
+			check: func(t *testing.T, msg model.WebhookMessage, delivery model.WebhookDelivery) {
+				assert.Equal(t, model.WebhookDelivered, msg.Status)
+				assert.Equal(t, 1, msg.Attempts)
+				assert.Equal(t, &now, msg.DeliveredAt)
+				assert.Equal(t, http.StatusOK, delivery.StatusCode)
+				assert.Empty(t, delivery.Error)
+			},
+		},
+		{
+			name:     "Retried With Backoff",
+			status:   http.StatusInternalServerError,
+			attempts: 2,
+			check: func(t *testing.T, msg model.WebhookMessage, delivery model.WebhookDelivery) {
+				assert.Equal(t, model.WebhookPending, msg.Status)
+				assert.Equal(t, 3, msg.Attempts)
+				assert.Equal(t, now.Add(4*cfg.BackoffBase), msg.NextAttemptAt)
+				assert.Contains(t, msg.LastError, "unexpected status 500: receiver down")
+				assert.Equal(t, 3, delivery.Attempt)
+				assert.Equal(t, http.StatusInternalServerError, delivery.StatusCode)
+			},
+		},
+		{
+			name:     "Gives Up After Max Attempts",
+			status:   http.StatusBadGateway,
+			attempts: cfg.MaxAttempts - 1,
+			check: func(t *testing.T, msg model.WebhookMessage, delivery model.WebhookDelivery) {
+				assert.Equal(t, model.WebhookFailed, msg.Status)
+				assert.Equal(t, cfg.MaxAttempts, msg.Attempts)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var received *http.Request
+			var receivedBody []byte
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = r
+				receivedBody, _ = io.ReadAll(r.Body)
+				w.WriteHeader(tc.status)
+				if tc.status >= 300 {
+					io.WriteString(w, "receiver down\n")
+				}
+			}))
+			defer receiver.Close()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockRepo := mocks.NewMockWebhookRepository(ctrl)
+
+			msg := model.WebhookMessage{
+				ID:             uuid.New(),
+				SubscriptionID: uuid.New(),
+				EventID:        uuid.New(),
+				EventType:      model.WebhookUserRegistered,
+				Payload:        `{"type":"user.registered"}`,
+				Status:         model.WebhookPending,
+				Attempts:       tc.attempts,
+				Subscription:   &model.WebhookSubscription{URL: receiver.URL, Secret: testSecret},
+			}
+			mockRepo.EXPECT().ClaimDue(gomock.Any(), now, gomock.Any(), cfg.BatchSize).Return([]model.WebhookMessage{msg}, nil)
+			mockRepo.EXPECT().RecordAttempt(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, msg model.WebhookMessage, delivery model.WebhookDelivery) error {
+					assert.Equal(t, msg.ID, delivery.MessageID)
+					tc.check(t, msg, delivery)
+					return nil
+				})
+
+			worker := NewWorker(mockRepo, cfg, slog.New(slog.DiscardHandler))
+			worker.now = func() time.Time { return now }
+
+			n, err := worker.ProcessBatch(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, 1, n)
+
+			if assert.NotNil(t, received) {
+				assert.Equal(t, msg.EventID.String(), received.Header.Get(HeaderID))
+				assert.Equal(t, model.WebhookUserRegistered, received.Header.Get(HeaderEvent))
+				assert.NoError(t, Verify(testSecret, received.Header.Get(HeaderSignature), receivedBody, now, time.Minute))
+			}
+		})
+	}
+}
+
+func TestWorker_ProcessBatch_OutlivesClaim(t *testing.T) {
+	cfg := config.Default().Webhook
+	cfg.AllowPrivateNetworks = true
+	cfg.BatchSize = 3
+	cfg.Timeout = 10 * time.Second
+	// 3 × (10s delivery + 5s record) + 1m
+	lease := 105 * time.Second
+	start := time.Now().UTC().Truncate(time.Second)
+
+	// every delivery takes 50s of the clock, so the third would start
+	// after the claim runs out
+	var elapsed atomic.Int64
+	var deliveries atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deliveries.Add(1)
+		elapsed.Add(int64(50 * time.Second))
+	}))
+	defer receiver.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mocks.NewMockWebhookRepository(ctrl)
+
+	msgs := make([]model.WebhookMessage, cfg.BatchSize)
+	for i := range msgs {
+		msgs[i] = model.WebhookMessage{
+			ID:           uuid.New(),
+			EventType:    model.WebhookUserRegistered,
+			Payload:      `{"type":"user.registered"}`,
+			Status:       model.WebhookPending,
+			Subscription: &model.WebhookSubscription{URL: receiver.URL, Secret: testSecret},
+		}
+	}
+	mockRepo.EXPECT().ClaimDue(gomock.Any(), start, lease, cfg.BatchSize).Return(msgs, nil)
+	mockRepo.EXPECT().RecordAttempt(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, msg model.WebhookMessage, delivery model.WebhookDelivery) error {
+			assert.NotEqual(t, msgs[2].ID, msg.ID)
+			return nil
+		}).Times(2)
+
+	worker := NewWorker(mockRepo, cfg, slog.New(slog.DiscardHandler))
+	worker.now = func() time.Time { return start.Add(time.Duration(elapsed.Load())) }
+
+	n, err := worker.ProcessBatch(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, int32(2), deliveries.Load(), "the last message is left for the next claim")
+}
+
+func TestWorker_RefusesPrivateAddresses(t *testing.T) {
+	cfg := config.Default().Webhook
+	var called bool
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer receiver.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mocks.NewMockWebhookRepository(ctrl)
+
+	msg := model.WebhookMessage{
+		ID:           uuid.New(),
+		EventID:      uuid.New(),
+		EventType:    model.WebhookUserRegistered,
+		Payload:      `{"type":"user.registered"}`,
+		Status:       model.WebhookPending,
+		Subscription: &model.WebhookSubscription{URL: receiver.URL, Secret: testSecret},
+	}
+	mockRepo.EXPECT().ClaimDue(gomock.Any(), gomock.Any(), gomock.Any(), cfg.BatchSize).Return([]model.WebhookMessage{msg}, nil)
+	mockRepo.EXPECT().RecordAttempt(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, msg model.WebhookMessage, delivery model.WebhookDelivery) error {
+			assert.Equal(t, model.WebhookPending, msg.Status)
+			assert.Contains(t, delivery.Error, ErrForbiddenAddress.Error())
+			return nil
+		})
+
+	_, err := NewWorker(mockRepo, cfg, slog.New(slog.DiscardHandler)).ProcessBatch(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, called, "the loopback receiver must not be reached")
+}
+
+func TestCheckHost(t *testing.T) {
+	for _, host := range []string{"127.0.0.1", "::1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "fd00:ec2::254", "::ffff:127.0.0.1", "0.0.0.0", "100.64.0.1"} {
+		assert.ErrorIs(t, CheckHost(context.Background(), net.DefaultResolver, host), ErrForbiddenAddress, host)
+	}
+	for _, host := range []string{"93.184.215.14", "2606:4700::1111"} {
+		assert.NoError(t, CheckHost(context.Background(), net.DefaultResolver, host), host)
+	}
+}
+
+func TestWorker_Backoff(t *testing.T) {
+	w := &Worker{cfg: config.WebhookConfig{BackoffBase: time.Second, BackoffMax: 10 * time.Second}}
+
+	assert.Equal(t, time.Second, w.backoff(1))
+	assert.Equal(t, 2*time.Second, w.backoff(2))
+	assert.Equal(t, 8*time.Second, w.backoff(4))
+	assert.Equal(t, 10*time.Second, w.backoff(5))
+	assert.Equal(t, 10*time.Second, w.backoff(50))
+}
@@ -1,52 +1,268 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"strings"
 
 	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/redis/go-redis/v9"
 
 	"github.com/kevinmarcellius/go-simple-auth/config"
+	"github.com/kevinmarcellius/go-simple-auth/internal/audit"
+	"github.com/kevinmarcellius/go-simple-auth/internal/auth"
+	"github.com/kevinmarcellius/go-simple-auth/internal/captcha"
+	"github.com/kevinmarcellius/go-simple-auth/internal/cli"
+	"github.com/kevinmarcellius/go-simple-auth/internal/clientip"
 	handler "github.com/kevinmarcellius/go-simple-auth/internal/handler"
-
-	repository "github.com/kevinmarcellius/go-simple-auth/internal/repository"
-	service "github.com/kevinmarcellius/go-simple-auth/internal/service"
+	"github.com/kevinmarcellius/go-simple-auth/internal/lifecycle"
+	"github.com/kevinmarcellius/go-simple-auth/internal/logging"
+	"github.com/kevinmarcellius/go-simple-auth/internal/mailer"
+	"github.com/kevinmarcellius/go-simple-auth/internal/metrics"
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/ratelimit"
+	"github.com/kevinmarcellius/go-simple-auth/internal/repository"
+	"github.com/kevinmarcellius/go-simple-auth/internal/server"
+	"github.com/kevinmarcellius/go-simple-auth/internal/service"
+	"github.com/kevinmarcellius/go-simple-auth/internal/tenant"
+	"github.com/kevinmarcellius/go-simple-auth/internal/tracing"
+	"github.com/kevinmarcellius/go-simple-auth/internal/webhook"
+	"github.com/kevinmarcellius/go-simple-auth/migration"
 )
 
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
 func main() {
+	commands := append([]cli.Command{
+		{Name: "serve", Usage: "serve [-migrate-on-boot]", Run: serve},
+	}, cli.Commands...)
+
+	// Running the binary without a command starts the server, as before.
+	args := os.Args[1:]
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		args = append([]string{"serve"}, args...)
+	}
+
+	var cmd *cli.Command
+	for i := range commands {
+		if commands[i].Name == args[0] {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		cli.PrintUsage(os.Stderr, commands)
+		os.Exit(2)
+	}
+
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	slog.SetDefault(logging.New(os.Stdout, cfg.Log))
+
+	if err := cmd.Run(context.Background(), cfg, args[1:]); err != nil {
+		log.Fatalf("%s: %v", cmd.Name, err)
+	}
+}
+
+func serve(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	migrateOnBoot := fs.Bool("migrate-on-boot", false, "apply pending database migrations before starting the server")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
 
 	hello := cfg.Postgres.Host
 
 	output := "Hello " + hello
 
-	log.Println(output)
+	logger := slog.Default()
+	logger.Info(output, slog.String("version", version))
+
+	lc := lifecycle.New(cfg.ShutdownTimeout, cfg.ShutdownDelay)
 
-	db, err := config.ConnectPostgres(cfg.Postgres)
+	// Registered first so it runs last and flushes spans from the shutdown itself.
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing, version)
 	if err != nil {
-		log.Fatalf("Failed to connect to Postgres: %v", err)
+		return fmt.Errorf("failed to set up tracing: %w", err)
 	}
+	lc.OnShutdown("tracer", shutdownTracing)
 
-	err = config.DBHealthCheck(db)
+	db, err := cli.OpenDB(cfg)
 	if err != nil {
-		log.Fatalf("Database health check failed: %v", err)
+		return errors.Join(fmt.Errorf("failed to connect to Postgres: %w", err), lc.Shutdown())
+	}
+	lc.OnShutdown("database", func(ctx context.Context) error {
+		return config.CloseDB(db)
+	})
+
+	if err := tracing.RegisterGormCallbacks(db); err != nil {
+		return errors.Join(err, lc.Shutdown())
 	}
-	log.Println("Database connection is healthy.")
 
-	healthHandler := handler.NewHealthHandler(db)
+	if *migrateOnBoot {
+		migrator, err := migration.NewMigrator(db)
+		if err != nil {
+			return errors.Join(err, lc.Shutdown())
+		}
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return errors.Join(fmt.Errorf("migrate on boot failed: %w", err), lc.Shutdown())
+		}
+		logger.Info("applied migrations on boot", slog.Int("count", len(applied)))
+	}
 
-	userRepository := repository.NewUserRepository(db)
-	userService := service.NewUserService(userRepository, cfg.JWTkey)
-	userHandler := handler.NewUserHandler(userService)
+	metrics.SetBuildInfo(version)
+	if sqlDB, err := db.DB(); err == nil {
+		if err := metrics.RegisterDBStats(sqlDB, "primary"); err != nil {
+			logger.Warn("failed to register DB pool metrics", slog.Any("error", err))
+		}
+	}
+	if cfg.MetricsPort != 0 {
+		metricsAddr := fmt.Sprintf(":%d", cfg.MetricsPort)
+		lc.Go("metrics server", func(ctx context.Context) {
+			metrics.Serve(ctx, metricsAddr)
+		})
+	}
+
+	healthHandler := handler.NewHealthHandler(db, lc)
+
+	var rdb *redis.Client
+	if cfg.UsesRedis() {
+		rdb, err = cli.OpenRedis(ctx, cfg)
+		if err != nil {
+			return errors.Join(err, lc.Shutdown())
+		}
+		lc.OnShutdown("redis", func(ctx context.Context) error {
+			return rdb.Close()
+		})
+	}
+
+	userCache, denylist := cli.NewCaches(cfg, rdb)
+	// registration limits and captchas apply to the API, not to operator
+	// commands
+	userOpts := []service.Option{service.WithRegistration(cfg.Registration)}
+	if cfg.Captcha.Enabled() {
+		userOpts = append(userOpts, service.WithCaptcha(captcha.NewSiteVerify(cfg.Captcha), cli.NewFailures(cfg, rdb), cfg.Captcha))
+	}
+	if cfg.MagicLink.Enabled {
+		sender, err := mailer.NewSMTP(cfg.SMTP)
+		if err != nil {
+			return errors.Join(err, lc.Shutdown())
+		}
+		userOpts = append(userOpts, service.WithMagicLink(sender, cfg.MagicLink))
+	}
+	userService, closeAudit, err := cli.NewUserService(cfg, db, userCache, denylist, userOpts...)
+	if err != nil {
+		return errors.Join(err, lc.Shutdown())
+	}
+	lc.OnShutdown("audit log", func(ctx context.Context) error {
+		return closeAudit()
+	})
+	var cookies *auth.Cookies
+	if cfg.Cookie.Enabled {
+		cookies = auth.NewCookies(cfg.Cookie, cfg.JWTkey)
+	}
+	userHandler := handler.NewUserHandler(userService, cookies, logger)
+	invitationHandler := handler.NewInvitationHandler(userService, logger)
+	auditHandler := handler.NewAuditHandler(service.NewAuditService(repository.NewAuditRepository(db, cfg.Postgres.QueryTimeout)), logger)
+
+	webhookRepository := repository.NewWebhookRepository(db, cfg.Postgres.QueryTimeout)
+	webhookHandler := handler.NewWebhookHandler(service.NewWebhookService(webhookRepository, cfg.Webhook), logger)
+	lc.Go("webhook worker", webhook.NewWorker(webhookRepository, cfg.Webhook, logger).Run)
+
+	var rateStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimit.Enabled && cfg.RateLimit.Store == "redis" {
+		rateStore = ratelimit.NewRedisStore(rdb)
+	}
+	var registerLimit, loginLimit, refreshLimit, logoutLimit, magicLinkLimit, magicLinkVerifyLimit []ratelimit.Rule
+	if cfg.RateLimit.Enabled {
+		// account keys are read from the request types the handlers bind
+		emailPolicy := cli.EmailPolicy(cfg)
+		registerLimit = []ratelimit.Rule{
+			{Name: "register:ip", Limit: cfg.RateLimit.Register.IP, Key: ratelimit.ByIP},
+			{Name: "register:account", Limit: cfg.RateLimit.Register.Account, Key: ratelimit.ByEmail(func(r *model.UserRequest) string { return r.Email }, emailPolicy)},
+		}
+		loginLimit = []ratelimit.Rule{
+			{Name: "login:ip", Limit: cfg.RateLimit.Login.IP, Key: ratelimit.ByIP},
+			{Name: "login:account", Limit: cfg.RateLimit.Login.Account, Key: ratelimit.ByEmail(func(r *model.LoginRequest) string { return r.Email }, emailPolicy)},
+		}
+		refreshLimit = []ratelimit.Rule{
+			{Name: "refresh:ip", Limit: cfg.RateLimit.Refresh, Key: ratelimit.ByIP},
+		}
+		logoutLimit = []ratelimit.Rule{
+			{Name: "logout:ip", Limit: cfg.RateLimit.Logout, Key: ratelimit.ByIP},
+		}
+		magicLinkLimit = []ratelimit.Rule{
+			{Name: "magic_link:ip", Limit: cfg.RateLimit.MagicLink.IP, Key: ratelimit.ByIP},
+			{Name: "magic_link:account", Limit: cfg.RateLimit.MagicLink.Account, Key: ratelimit.ByEmail(func(r *model.MagicLinkRequest) string { return r.Email }, emailPolicy)},
+		}
+		// the body has a token but no email, so per IP only
+		magicLinkVerifyLimit = []ratelimit.Rule{
+			{Name: "magic_link_verify:ip", Limit: cfg.RateLimit.MagicLinkVerify, Key: ratelimit.ByIP},
+		}
+	}
+
+	var certs *server.Certificates
+	if cfg.TLS.Enabled() {
+		certs, err = server.NewCertificates(cfg.TLS)
+		if err != nil {
+			return errors.Join(err, lc.Shutdown())
+		}
+		if cfg.TLS.SelfSigned {
+			logger.Warn("serving a self-signed certificate, for development only")
+		}
+		lc.Go("certificate reloader", certs.Watch)
+	}
+
+	if cfg.Internal.Port != 0 {
+		clientCAs, err := server.LoadClientCAs(cfg.Internal.ClientCAFile)
+		if err != nil {
+			return errors.Join(err, lc.Shutdown())
+		}
+		introspectionHandler := handler.NewIntrospectionHandler(userService, logger)
+
+		internal := echo.New()
+		internal.HideBanner = true
+		internal.HidePort = true
+		internal.Use(middleware.RequestID())
+		internal.Use(tracing.Middleware())
+		internal.Use(logging.Middleware(logger))
+		internal.Use(server.Recover(logger))
+		internal.Use(server.RequireClient(cfg.Internal.AllowedClients))
+		internal.POST("/internal/v1/introspect", introspectionHandler.Introspect)
+
+		internalAddr := fmt.Sprintf(":%d", cfg.Internal.Port)
+		if err := server.ServeInternal(internal, internalAddr, certs.MutualTLSConfig(clientCAs), logger); err != nil {
+			return errors.Join(err, lc.Shutdown())
+		}
+		lc.OnShutdown("internal server", internal.Shutdown)
+	}
 
 	e := echo.New()
-	e.Use(middleware.Logger()) // Add this line to enable the logger middleware
+	e.HideBanner = true
+	e.HidePort = true
+	e.IPExtractor = clientip.Extractor(cfg.TrustedProxies)
+	server.Configure(e, cfg.HTTP)
+	e.Use(middleware.RequestID())
+	e.Use(server.SecurityHeaders(cfg.HTTP))
+	e.Use(server.CORS(cfg.HTTP.CORS))
+	e.Use(tracing.Middleware())
+	e.Use(logging.Middleware(logger))
+	e.Use(audit.Middleware())
+	e.Use(metrics.Middleware())
+	// inside the above, so panics are traced, logged and counted as 500s
+	e.Use(server.Recover(logger))
+	e.Use(middleware.BodyLimit(cfg.HTTP.BodyLimit))
+	e.Use(server.Timeout(cfg.HTTP.RequestTimeout))
 	e.GET("/", func(c echo.Context) error {
 		return c.String(http.StatusOK, output)
 	})
@@ -55,20 +271,44 @@ func main() {
 	v1.GET("/health/ready", healthHandler.ReadinessCheck)
 	v1.GET("/health/live", healthHandler.LivenessCheck)
 
-	// user
-	v1.POST("/user", userHandler.CreateUser)
-	v1.POST("/user/login", userHandler.Login)
-	v1.POST("/user/refresh", userHandler.Refresh)
+	jwtMiddleware := auth.Middleware(cli.JWTOptions(cfg), cookies)
 
-	jwtMiddleware := echojwt.WithConfig(echojwt.Config{
-		SigningKey: []byte(cfg.JWTkey),
-	})
+	// user, scoped to the organization of the request
+	organizationRepository := repository.NewOrganizationRepository(db, cfg.Postgres.QueryTimeout)
+	user := v1.Group("/user", tenant.Middleware(cfg.Tenancy, organizationRepository.FindBySlug))
+	user.POST("", userHandler.CreateUser, ratelimit.Middleware(rateStore, logger, registerLimit...))
+	user.POST("/login", userHandler.Login, ratelimit.Middleware(rateStore, logger, loginLimit...))
+	if cfg.MagicLink.Enabled {
+		user.POST("/login/magic-link", userHandler.RequestMagicLink, ratelimit.Middleware(rateStore, logger, magicLinkLimit...))
+		user.POST("/login/magic-link/verify", userHandler.VerifyMagicLink, ratelimit.Middleware(rateStore, logger, magicLinkVerifyLimit...))
+	}
+	user.POST("/refresh", userHandler.Refresh, ratelimit.Middleware(rateStore, logger, refreshLimit...))
+	user.POST("/logout", userHandler.Logout, ratelimit.Middleware(rateStore, logger, logoutLimit...))
+	user.PUT("/password", userHandler.UpdatePassword, jwtMiddleware)
+	user.POST("/invitations", invitationHandler.CreateInvitation, jwtMiddleware)
+	user.GET("/invitations", invitationHandler.ListInvitations, jwtMiddleware)
+	user.POST("/invitations/:id/resend", invitationHandler.ResendInvitation, jwtMiddleware)
+	user.DELETE("/invitations/:id", invitationHandler.RevokeInvitation, jwtMiddleware)
+	user.POST("/invitations/accept", invitationHandler.AcceptInvitation, ratelimit.Middleware(rateStore, logger, registerLimit...))
 
-	v1.PUT("/user/password", userHandler.UpdatePassword, jwtMiddleware)
+	// admin
+	admin := v1.Group("/admin", jwtMiddleware, handler.RequireAdmin)
+	admin.GET("/audit-events", auditHandler.ListEvents)
+	admin.POST("/webhooks", webhookHandler.CreateSubscription)
+	admin.GET("/webhooks", webhookHandler.ListSubscriptions)
+	admin.DELETE("/webhooks/:id", webhookHandler.DeleteSubscription)
+	admin.GET("/webhooks/:id/deliveries", webhookHandler.ListDeliveries)
 
 	// Start server
 
-	port := fmt.Sprintf(":%d", cfg.Port)
-	log.Printf("Starting server on port %s\n", port)
-	e.Logger.Fatal(e.Start(port))
+	lc.OnShutdown("http server", e.Shutdown)
+
+	e.Server.Addr = fmt.Sprintf(":%d", cfg.Port)
+	if certs != nil {
+		e.Server.TLSConfig = certs.TLSConfig()
+	}
+	logger.Info("starting server", slog.String("addr", e.Server.Addr), slog.Bool("tls", certs != nil))
+	return lc.Run(ctx, func() error {
+		return e.StartServer(e.Server)
+	})
 }
@@ -0,0 +1,97 @@
+package migration
+
+import (
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+// Collision is a group of existing users in the same uniqueness scope whose
+// username or email map to the same normalized value and would violate the
+// case-insensitive indexes.
+type Collision struct {
+	// Scope is the organization the users are unique in, or uuid.Nil for
+	// users unique across all of them.
+	Scope      uuid.UUID
+	Field      string
+	Normalized string
+	Users      []model.User
+}
+
+// FindCollisions scans every user, including soft-deleted ones, and reports
+// usernames and emails that collide within their uniqueness scope once
+// normalized with the given policy. Users of different organizations may
+// share them.
+func FindCollisions(ctx context.Context, db *gorm.DB, policy utils.EmailPolicy) ([]Collision, error) {
+	users, err := loadUsers(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	return findCollisions(users, policy), nil
+}
+
+// loadUsers returns every user with the columns normalization needs.
+func loadUsers(ctx context.Context, db *gorm.DB) ([]model.User, error) {
+	db = db.WithContext(ctx)
+	columns := []string{"id", "username", "email"}
+	// before migration 0007 there are no scopes: every user is unique
+	// across the service, which is the nil scope 0007 puts them in
+	if db.Migrator().HasColumn(&model.User{}, "uniqueness_scope") {
+		columns = append(columns, "uniqueness_scope")
+	}
+	var users []model.User
+	err := db.Unscoped().Select(columns).Order("created_at").Find(&users).Error
+	return users, err
+}
+
+type collisionKey struct {
+	scope      uuid.UUID
+	normalized string
+}
+
+func findCollisions(users []model.User, policy utils.EmailPolicy) []Collision {
+	byUsername := map[collisionKey][]model.User{}
+	byEmail := map[collisionKey][]model.User{}
+	for _, u := range users {
+		username := collisionKey{u.UniquenessScope, utils.NormalizeUsername(u.Username)}
+		byUsername[username] = append(byUsername[username], u)
+
+		normalized, err := utils.NormalizeEmail(u.Email, policy)
+		if err != nil {
+			// keep malformed rows visible instead of silently merging them
+			normalized = u.Email
+		}
+		email := collisionKey{u.UniquenessScope, normalized}
+		byEmail[email] = append(byEmail[email], u)
+	}
+
+	var collisions []Collision
+	collisions = appendCollisions(collisions, "username", byUsername)
+	collisions = appendCollisions(collisions, "email", byEmail)
+	return collisions
+}
+
+func appendCollisions(collisions []Collision, field string, groups map[collisionKey][]model.User) []Collision {
+	keys := make([]collisionKey, 0, len(groups))
+	for k, g := range groups {
+		if len(g) > 1 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].scope != keys[j].scope {
+			return keys[i].scope.String() < keys[j].scope.String()
+		}
+		return keys[i].normalized < keys[j].normalized
+	})
+
+	for _, k := range keys {
+		collisions = append(collisions, Collision{Scope: k.scope, Field: field, Normalized: k.normalized, Users: groups[k]})
+	}
+	return collisions
+}
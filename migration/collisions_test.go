@@ -0,0 +1,135 @@
+package migration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	assert.NoError(t, err)
+	return db, mock
+}
+
+// expectUsers answers the queries of loadUsers with rows, which have a
+// uniqueness_scope column unless the database predates migration 0007.
+func expectUsers(mock sqlmock.Sqlmock, rows *sqlmock.Rows, scoped bool) {
+	count := 0
+	if scoped {
+		count = 1
+	}
+	mock.ExpectQuery(`(?i)INFORMATION_SCHEMA.columns`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+	mock.ExpectQuery(`SELECT .* FROM "go_user"`).WillReturnRows(rows)
+}
+
+func TestFindCollisions(t *testing.T) {
+	acme, globex := uuid.New(), uuid.New()
+
+	testCases := []struct {
+		name     string
+		scoped   bool
+		rows     func() *sqlmock.Rows
+		expected []Collision
+	}{
+		{
+			name:   "Organizations Share An Email",
+			scoped: true,
+			rows: func() *sqlmock.Rows {
+				return sqlmock.NewRows([]string{"id", "username", "email", "uniqueness_scope"}).
+					AddRow(uuid.New(), "bob", "Bob@Example.com", acme).
+					AddRow(uuid.New(), "robert", "bob@example.com", globex)
+			},
+		},
+		{
+			name:   "Same Organization",
+			scoped: true,
+			rows: func() *sqlmock.Rows {
+				return sqlmock.NewRows([]string{"id", "username", "email", "uniqueness_scope"}).
+					AddRow(uuid.New(), "bob", "Bob@Example.com", acme).
+					AddRow(uuid.New(), "robert", "bob@example.com", acme).
+					AddRow(uuid.New(), "bobby", "bob@example.com", globex)
+			},
+			expected: []Collision{{Scope: acme, Field: "email", Normalized: "bob@example.com"}},
+		},
+		{
+			name: "Before Organizations",
+			rows: func() *sqlmock.Rows {
+				return sqlmock.NewRows([]string{"id", "username", "email"}).
+					AddRow(uuid.New(), "Bob", "bob@example.com").
+					AddRow(uuid.New(), "bob", "robert@example.com")
+			},
+			expected: []Collision{{Scope: uuid.Nil, Field: "username", Normalized: "bob"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			expectUsers(mock, tc.rows(), tc.scoped)
+
+			collisions, err := FindCollisions(context.Background(), db, utils.EmailPolicy{})
+
+			assert.NoError(t, err)
+			assert.Len(t, collisions, len(tc.expected))
+			for i := range tc.expected {
+				if i >= len(collisions) {
+					break
+				}
+				assert.Equal(t, tc.expected[i].Scope, collisions[i].Scope)
+				assert.Equal(t, tc.expected[i].Field, collisions[i].Field)
+				assert.Equal(t, tc.expected[i].Normalized, collisions[i].Normalized)
+				assert.Len(t, collisions[i].Users, 2)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	acme, globex := uuid.New(), uuid.New()
+	bob := uuid.New()
+
+	t.Run("Organizations Share An Email", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectUsers(mock, sqlmock.NewRows([]string{"id", "username", "email", "uniqueness_scope"}).
+			AddRow(bob, "bob", "Bob@Example.com", acme).
+			AddRow(uuid.New(), "robert", "bob@example.com", globex), true)
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "go_user" SET "email"=\$1,"username"=\$2,"updated_at"=\$3 WHERE id = \$4`).
+			WithArgs("bob@example.com", "bob", sqlmock.AnyArg(), bob).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		changed, err := Normalize(context.Background(), db, utils.EmailPolicy{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, changed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Collisions Change Nothing", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectUsers(mock, sqlmock.NewRows([]string{"id", "username", "email", "uniqueness_scope"}).
+			AddRow(bob, "bob", "Bob@Example.com", acme).
+			AddRow(uuid.New(), "robert", "bob@example.com", acme), true)
+
+		changed, err := Normalize(context.Background(), db, utils.EmailPolicy{})
+
+		assert.ErrorIs(t, err, ErrCollisions)
+		assert.Zero(t, changed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
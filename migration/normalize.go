@@ -0,0 +1,58 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/kevinmarcellius/go-simple-auth/internal/model"
+	"github.com/kevinmarcellius/go-simple-auth/internal/utils"
+)
+
+// ErrCollisions is returned by Normalize when users of the same uniqueness
+// scope would end up with the same username or email.
+var ErrCollisions = errors.New("normalized usernames or emails collide")
+
+// Normalize rewrites stored usernames and emails, including soft-deleted
+// rows, to the form produced by utils.NormalizeUsername and
+// utils.NormalizeEmail under the given policy. Migration 0002 can only trim,
+// NFKC-normalize and lowercase in SQL; this applies the rest of the policy,
+// such as plus-tag stripping. Nothing is changed while FindCollisions
+// reports collisions. It returns the number of users changed.
+func Normalize(ctx context.Context, db *gorm.DB, policy utils.EmailPolicy) (int, error) {
+	users, err := loadUsers(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	if collisions := findCollisions(users, policy); len(collisions) > 0 {
+		return 0, fmt.Errorf("%w: %d found, list them with check-collisions", ErrCollisions, len(collisions))
+	}
+
+	changed := 0
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, u := range users {
+			username := utils.NormalizeUsername(u.Username)
+			email, err := utils.NormalizeEmail(u.Email, policy)
+			if err != nil {
+				// leave malformed rows as they are, like FindCollisions
+				email = u.Email
+			}
+			if username == u.Username && email == u.Email {
+				continue
+			}
+			err = tx.Unscoped().Model(&model.User{}).Where("id = ?", u.ID).
+				Updates(map[string]any{"username": username, "email": email}).Error
+			if err != nil {
+				return err
+			}
+			changed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return changed, nil
+}
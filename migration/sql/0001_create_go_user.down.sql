@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS update_users_updated_at ON "go_user";
+DROP FUNCTION IF EXISTS update_updated_at_column();
+DROP TABLE IF EXISTS "go_user";
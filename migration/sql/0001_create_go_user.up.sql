@@ -1,8 +1,11 @@
+-- Initial schema. Statements are idempotent so databases that were set up by
+-- hand from the old db_schema.sql can adopt versioned migrations as-is.
+
 -- Enable the uuid-ossp extension to generate UUIDs
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 
 -- Create the users table
-CREATE TABLE "go_user" (
+CREATE TABLE IF NOT EXISTS "go_user" (
     -- id: UUID primary key, automatically generated on creation
     id UUID PRIMARY KEY,
 
@@ -28,7 +31,7 @@ CREATE TABLE "go_user" (
 );
 
 -- Create an index on the email column for faster lookups during login
-CREATE INDEX idx_users_email ON "go_user"(email);
+CREATE INDEX IF NOT EXISTS idx_users_email ON "go_user"(email);
 
 -- Create a trigger function to automatically update the updated_at timestamp on any row modification
 CREATE OR REPLACE FUNCTION update_updated_at_column()
@@ -40,6 +43,7 @@ END;
 $$ language 'plpgsql';
 
 -- Attach the trigger to the users table
+DROP TRIGGER IF EXISTS update_users_updated_at ON "go_user";
 CREATE TRIGGER update_users_updated_at
 BEFORE UPDATE ON "go_user"
 FOR EACH ROW
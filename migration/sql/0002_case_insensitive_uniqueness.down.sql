@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_go_user_username_lower;
+DROP INDEX IF EXISTS idx_go_user_email_lower;
+
+ALTER TABLE "go_user" ADD CONSTRAINT go_user_username_key UNIQUE (username);
+ALTER TABLE "go_user" ADD CONSTRAINT go_user_email_key UNIQUE (email);
+CREATE INDEX IF NOT EXISTS idx_users_email ON "go_user"(email);
@@ -0,0 +1,21 @@
+-- Make usernames and emails unique case-insensitively.
+--
+-- Run `go run . migrate check-collisions` first: creating the indexes below
+-- fails if two rows differ only by case.
+
+-- Normalize what is already stored so exact and lower() lookups agree. This
+-- mirrors utils.NormalizeUsername and utils.NormalizeEmail with the default
+-- policy (normalize() needs Postgres 13+); with EMAIL_STRIP_PLUS_TAG=true run
+-- `go run . migrate normalize` afterwards to apply the rest of the policy.
+UPDATE "go_user" SET
+    email = normalize(lower(normalize(trim(email), NFKC)), NFKC),
+    username = normalize(lower(normalize(trim(username), NFKC)), NFKC);
+
+-- Replace the case-sensitive constraints with functional unique indexes.
+-- The email index also serves the lower(email) lookup done during login.
+ALTER TABLE "go_user" DROP CONSTRAINT IF EXISTS go_user_username_key;
+ALTER TABLE "go_user" DROP CONSTRAINT IF EXISTS go_user_email_key;
+DROP INDEX IF EXISTS idx_users_email;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_go_user_username_lower ON "go_user"(lower(username));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_go_user_email_lower ON "go_user"(lower(email));
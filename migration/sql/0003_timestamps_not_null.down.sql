@@ -0,0 +1,2 @@
+ALTER TABLE "go_user" ALTER COLUMN created_at DROP NOT NULL;
+ALTER TABLE "go_user" ALTER COLUMN updated_at DROP NOT NULL;
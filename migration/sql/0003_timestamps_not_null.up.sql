@@ -0,0 +1,6 @@
+-- The User model declares created_at and updated_at as NOT NULL; align the table.
+UPDATE "go_user" SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+UPDATE "go_user" SET updated_at = created_at WHERE updated_at IS NULL;
+
+ALTER TABLE "go_user" ALTER COLUMN created_at SET NOT NULL;
+ALTER TABLE "go_user" ALTER COLUMN updated_at SET NOT NULL;
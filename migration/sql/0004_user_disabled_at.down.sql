@@ -0,0 +1 @@
+ALTER TABLE "go_user" DROP COLUMN IF EXISTS disabled_at;
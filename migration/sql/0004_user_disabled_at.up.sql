@@ -0,0 +1,2 @@
+-- disabled_at: set when an operator disables the account; disabled users cannot log in or refresh
+ALTER TABLE "go_user" ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP WITH TIME ZONE DEFAULT NULL;
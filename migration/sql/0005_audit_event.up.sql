@@ -0,0 +1,37 @@
+-- Append-only log of authentication and account events, kept for compliance.
+-- There is deliberately no foreign key to go_user: events outlive the users
+-- they describe.
+CREATE TABLE IF NOT EXISTS "audit_event" (
+    id UUID PRIMARY KEY,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    outcome VARCHAR(16) NOT NULL,
+    reason VARCHAR(64) NOT NULL DEFAULT '',
+    actor_id UUID,
+    target_id UUID,
+    target_email VARCHAR(255) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    request_id VARCHAR(128) NOT NULL DEFAULT ''
+);
+
+-- Listing is newest first, paginated on (occurred_at, id)
+CREATE INDEX IF NOT EXISTS idx_audit_event_occurred_at ON "audit_event"(occurred_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_event_actor_id ON "audit_event"(actor_id, occurred_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_event_target_id ON "audit_event"(target_id, occurred_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_event_action ON "audit_event"(action, occurred_at DESC);
+
+-- Reject any change to recorded events, whatever the client
+CREATE OR REPLACE FUNCTION audit_event_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_event is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_event_no_modify ON "audit_event";
+CREATE TRIGGER audit_event_no_modify BEFORE UPDATE OR DELETE ON "audit_event"
+    FOR EACH ROW EXECUTE FUNCTION audit_event_append_only();
+
+DROP TRIGGER IF EXISTS audit_event_no_truncate ON "audit_event";
+CREATE TRIGGER audit_event_no_truncate BEFORE TRUNCATE ON "audit_event"
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_event_append_only();
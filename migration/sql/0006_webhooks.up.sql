@@ -0,0 +1,41 @@
+-- Outbound webhooks: subscriptions, a transactional outbox of messages to
+-- deliver, and a log of every delivery attempt.
+CREATE TABLE IF NOT EXISTS "webhook_subscription" (
+    id UUID PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    event_types JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS "webhook_outbox" (
+    id UUID PRIMARY KEY,
+    subscription_id UUID NOT NULL REFERENCES "webhook_subscription"(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP WITH TIME ZONE
+);
+
+-- The delivery worker polls for due pending messages
+CREATE INDEX IF NOT EXISTS idx_webhook_outbox_due ON "webhook_outbox"(next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS "webhook_delivery" (
+    id UUID PRIMARY KEY,
+    message_id UUID NOT NULL REFERENCES "webhook_outbox"(id) ON DELETE CASCADE,
+    subscription_id UUID NOT NULL REFERENCES "webhook_subscription"(id) ON DELETE CASCADE,
+    event_type VARCHAR(64) NOT NULL,
+    attempt INTEGER NOT NULL,
+    status_code INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    attempted_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_subscription ON "webhook_delivery"(subscription_id, attempted_at DESC);
@@ -0,0 +1,10 @@
+-- Fails if users of different organizations share a username or email.
+DROP INDEX IF EXISTS idx_go_user_email_lower_lookup;
+DROP INDEX IF EXISTS idx_go_user_scope_email_lower;
+DROP INDEX IF EXISTS idx_go_user_scope_username_lower;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_go_user_username_lower ON "go_user"(lower(username));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_go_user_email_lower ON "go_user"(lower(email));
+ALTER TABLE "go_user" DROP COLUMN IF EXISTS uniqueness_scope;
+
+DROP TABLE IF EXISTS "organization_membership";
+DROP TABLE IF EXISTS "organization";
@@ -0,0 +1,41 @@
+-- Organizations (tenants) and the memberships giving users a role in them.
+CREATE TABLE IF NOT EXISTS "organization" (
+    id UUID PRIMARY KEY,
+    slug VARCHAR(63) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_organization_slug ON "organization"(slug);
+
+-- Single-tenant deployments keep working in the default organization
+INSERT INTO "organization" (id, slug, name)
+VALUES ('00000000-0000-0000-0000-000000000001', 'default', 'Default')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS "organization_membership" (
+    org_id UUID NOT NULL REFERENCES "organization"(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES "go_user"(id) ON DELETE CASCADE,
+    role VARCHAR(16) NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (org_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_membership_user ON "organization_membership"(user_id);
+
+INSERT INTO "organization_membership" (org_id, user_id, role)
+SELECT '00000000-0000-0000-0000-000000000001', id, 'member' FROM "go_user"
+ON CONFLICT DO NOTHING;
+
+-- Usernames and emails are unique within a scope: the nil UUID for users
+-- unique across all organizations, or the organization they registered in.
+-- Existing users stay globally unique.
+ALTER TABLE "go_user" ADD COLUMN IF NOT EXISTS uniqueness_scope UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000';
+
+DROP INDEX IF EXISTS idx_go_user_username_lower;
+DROP INDEX IF EXISTS idx_go_user_email_lower;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_go_user_scope_username_lower ON "go_user"(uniqueness_scope, lower(username));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_go_user_scope_email_lower ON "go_user"(uniqueness_scope, lower(email));
+-- Logins look emails up without knowing their scope
+CREATE INDEX IF NOT EXISTS idx_go_user_email_lower_lookup ON "go_user"(lower(email));
@@ -0,0 +1,19 @@
+-- Invitations to join an organization. Each is accepted with a signed token
+-- whose jti must match token_id, so resending one invalidates the old link.
+CREATE TABLE IF NOT EXISTS "invitation" (
+    id UUID PRIMARY KEY,
+    org_id UUID NOT NULL REFERENCES "organization"(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(16) NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+    invited_by UUID REFERENCES "go_user"(id) ON DELETE SET NULL,
+    token_id UUID NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    user_id UUID REFERENCES "go_user"(id) ON DELETE SET NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_invitation_org ON "invitation"(org_id, created_at DESC);
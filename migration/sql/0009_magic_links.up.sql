@@ -0,0 +1,14 @@
+-- Passwordless login links. Each is used with a signed token whose jti is
+-- the link's id, and logs in at most once: using it sets used_at.
+CREATE TABLE IF NOT EXISTS "magic_link" (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES "go_user"(id) ON DELETE CASCADE,
+    org_id UUID NOT NULL REFERENCES "organization"(id) ON DELETE CASCADE,
+    client_id VARCHAR(64) NOT NULL DEFAULT '',
+    remember_me BOOLEAN NOT NULL DEFAULT FALSE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_magic_link_user ON "magic_link"(user_id);
@@ -0,0 +1 @@
+ALTER TABLE "go_user" DROP COLUMN IF EXISTS email_verified_at;
@@ -0,0 +1,2 @@
+-- email_verified_at: set the first time the user proves they own their email, by logging in with a magic link
+ALTER TABLE "go_user" ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP WITH TIME ZONE DEFAULT NULL;